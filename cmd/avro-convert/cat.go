@@ -0,0 +1,230 @@
+package main
+
+import (
+	"avroparser/pkg/container"
+	"avroparser/pkg/expr"
+	"avroparser/pkg/output"
+	"avroparser/pkg/provider"
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// runCat writes records of container files or of datums read with the schema source in
+// output format, returns process exit code
+func runCat(args []string, input io.Reader, w io.Writer) int {
+	flags := flag.NewFlagSet("cat", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s cat [flags] [file...]
+
+Writes records of avro container files, or of avro datums if schema source is set, in
+output format. Standard input is read if no files are given.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	source := addSourceFlags(flags)
+	outputOptions := addOutputFlags(flags)
+	where := addWhereFlag(flags)
+	errorOptions := addErrorFlags(flags)
+	files := parseArgs(flags, args)
+
+	filter, err := newWhereFilter(*where)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	out := bufio.NewWriter(w)
+	writer, err := outputOptions.writer(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	policy, err := errorOptions.policy()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	exitCode := 0
+	if err = readRecords(source, filter, policy, files, input, writer); err == nil {
+		err = writer.End()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+	}
+	if err = policy.close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+	}
+	if err = out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write output: %v\n", err)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+func addWhereFlag(flags *flag.FlagSet) *string {
+	return flags.String("where", "", "read only records the expression is true for, like: amount > 10 and status in ('NEW', 'PAID') and created >= '2024-01-01'")
+}
+
+// readRecords begins the writer and passes it records of files or of input if there are no
+// files that match the filter. Files are read as container files if schema source is not
+// set, otherwise they are read as a single stream of datums. Records that can't be read are
+// passed to the error policy.
+func readRecords(source *sourceFlags, filter *whereFilter, policy *errorPolicy, files []string, input io.Reader, writer output.Writer) error {
+	if !source.isSet() {
+		if len(files) == 0 {
+			return readContainer("stdin", bufio.NewReader(input), true, filter, policy, writer)
+		}
+		for idx, fileName := range files {
+			if err := readContainerFile(fileName, idx == 0, filter, policy, writer); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	streamConverter, registryClient, err := source.streamConverter()
+	if err != nil {
+		return err
+	}
+	if source.static != nil {
+		if err = filter.check(source.static); err != nil {
+			return err
+		}
+	}
+	// records of other converters have fields of messages, like keys and headers
+	var header output.Record
+	if static, ok := streamConverter.(*provider.StaticFileSchema); ok {
+		header = output.Record{{Schema: static.Schema()}}
+	}
+	if err = writer.Begin(header); err != nil {
+		return err
+	}
+	name := "stdin"
+	if len(files) > 0 {
+		name = strings.Join(files, ",")
+		readers := make([]io.Reader, len(files))
+		for idx, fileName := range files {
+			f, err := os.Open(fileName)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			readers[idx] = f
+		}
+		input = io.MultiReader(readers...)
+	}
+	if err = readStream(name, streamConverter, bufio.NewReader(input), filter, policy, writer); err != nil {
+		return err
+	}
+	return source.exportSnapshot(registryClient)
+}
+
+func readContainerFile(fileName string, begin bool, filter *whereFilter, policy *errorPolicy, writer output.Writer) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readContainer(fileName, bufio.NewReader(f), begin, filter, policy, writer)
+}
+
+// readContainer passes records of the container to the writer, begin is set for the first
+// container and the writer is begun with its schema
+func readContainer(name string, r io.Reader, begin bool, filter *whereFilter, policy *errorPolicy, writer output.Writer) error {
+	reader, err := container.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w, set schema source to read avro datums", name, err)
+	}
+	dataSchema := reader.Header().Schema
+	compiled, err := filter.forSchema(dataSchema)
+	if err != nil {
+		return err
+	}
+	if begin {
+		if err = writer.Begin(output.Record{{Schema: dataSchema}}); err != nil {
+			return err
+		}
+	}
+	for {
+		value, err := reader.Next()
+		var recordErr *container.RecordError
+		if err == io.EOF {
+			return nil
+		} else if errors.As(err, &recordErr) {
+			if err = policy.handle(failure{Source: name, Index: recordErr.Index, Offset: recordErr.Offset, Count: recordErr.Count, Data: recordErr.Data}, recordErr.Err, !recordErr.Framing); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err = writeRecord(writer, compiled, output.Record{{Schema: dataSchema, Value: value}}); err != nil {
+			return err
+		}
+	}
+}
+
+// readStream reads datums of the stream until its end. Datums that can't be decoded are
+// skipped according to the error policy if the converter has read them completely.
+func readStream(name string, streamConverter provider.StreamConverter, input io.Reader, filter *whereFilter, policy *errorPolicy, writer output.Writer) error {
+	counter := provider.NewCountingReader(input)
+	var position provider.Counting = &counter
+	if counting, ok := streamConverter.(provider.Counting); ok {
+		// converter reads ahead, so it counts bytes of datums itself
+		position = counting
+	}
+	raw := bytes.Buffer{}
+	reader := io.TeeReader(&counter, &raw)
+	for index := int64(0); ; index++ {
+		offset := position.BytesRead()
+		raw.Reset()
+		data, err := streamConverter.Next(reader)
+		if nil != err {
+			if err == io.EOF || provider.IsEof(err) && position.BytesRead() == offset {
+				return nil
+			}
+			var decodeErr *provider.DecodeError
+			resumable := errors.As(err, &decodeErr)
+			record := failure{Source: name, Index: index, Offset: int64(offset), Count: 1, Data: raw.Bytes()}
+			if resumable {
+				record.Data = decodeErr.Data
+			}
+			if err = policy.handle(record, err, resumable); err != nil {
+				return err
+			}
+		} else if len(data) > 0 {
+			record := toRecord(data)
+			compiled, err := filter.forRecord(recordKey(data), record)
+			if err != nil {
+				return err
+			}
+			if err = writeRecord(writer, compiled, record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeRecord(writer output.Writer, compiled *expr.Compiled, record output.Record) error {
+	if !matchRecord(compiled, record) {
+		return nil
+	}
+	return writer.Record(record)
+}
+
+func toRecord(data []provider.DataChunk) output.Record {
+	record := make(output.Record, len(data))
+	for idx, chunk := range data {
+		record[idx] = output.Field{Name: chunk.Name(), Schema: chunk.Schema(), Value: chunk.Value()}
+	}
+	return record
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"avroparser/pkg/container"
+	"avroparser/pkg/provider"
+	"avroparser/pkg/registry"
+	"avroparser/pkg/schema"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	encodeOutputRaw       = "raw"
+	encodeOutputContainer = "container"
+	encodeOutputFramed    = "framed"
+	encodeOutputConfluent = "confluent"
+)
+
+// datumFlags are flags of commands writing avro binary data
+type datumFlags struct {
+	outputFormat     *string
+	codecName        *string
+	framing          *string
+	registry         *string
+	registryCache    *string
+	registrySnapshot *string
+	topic            *string
+	subjectStrategy  *string
+	key              *bool
+	autoRegister     *bool
+}
+
+func addDatumFlags(flags *flag.FlagSet) datumFlags {
+	return datumFlags{
+		outputFormat:     flags.String("output", encodeOutputRaw, "output format: raw for concatenated datums, container for avro object container file, framed for length-prefixed datums or confluent for datums in confluent wire format"),
+		codecName:        flags.String("codec", "null", "compression codec of container file: null, deflate or snappy"),
+		framing:          flags.String("framing", string(provider.FrameLengthVarint), "length prefix of framed datums: varint, be32 or le32"),
+		registry:         flags.String("registry", "", "confluent schema registry url to look up id of the schema for confluent output"),
+		registryCache:    flags.String("registry-cache", "", "directory to cache schemas fetched from schema registry"),
+		registrySnapshot: flags.String("registry-snapshot", "", "registry snapshot file to look up id of the schema in, registry is queried only if it is not found"),
+		topic:            flags.String("topic", "", "kafka topic the datums are written for, used by topic subject name strategies"),
+		subjectStrategy:  flags.String("subject-strategy", "topic", "subject name strategy to look up the schema under: topic, record or topic-record"),
+		key:              flags.Bool("key", false, "datums are message keys, subject of topic name strategy is <topic>-key instead of <topic>-value"),
+		autoRegister:     flags.Bool("auto-register", false, "register the schema under the subject if it is not registered"),
+	}
+}
+
+// datumWriter writes values in output format selected by datum flags
+type datumWriter struct {
+	Write func(value interface{}) error
+	Close func() error
+}
+
+func newDatumWriter(output io.Writer, options datumFlags, s schema.ItemSchema, schemaData []byte) (*datumWriter, error) {
+	writer := bufio.NewWriter(output)
+	switch *options.outputFormat {
+	case encodeOutputRaw:
+		return &datumWriter{
+			Write: func(value interface{}) error {
+				return s.Write(writer, value)
+			},
+			Close: writer.Flush,
+		}, nil
+	case encodeOutputFramed:
+		frameLength, err := provider.ParseFrameLength(*options.framing)
+		if err != nil {
+			return nil, err
+		}
+		buffer := bytes.Buffer{}
+		return &datumWriter{
+			Write: func(value interface{}) error {
+				buffer.Reset()
+				if err := s.Write(&buffer, value); err != nil {
+					return err
+				}
+				return provider.WriteFrame(writer, frameLength, buffer.Bytes())
+			},
+			Close: writer.Flush,
+		}, nil
+	case encodeOutputConfluent:
+		id, err := registrySchemaId(options, s, schemaData)
+		if err != nil {
+			return nil, err
+		}
+		encoder := provider.NewConfluentEncoder(s, id)
+		buffer := bytes.Buffer{}
+		return &datumWriter{
+			Write: func(value interface{}) error {
+				buffer.Reset()
+				if err := encoder.Encode(&buffer, value); err != nil {
+					return err
+				}
+				_, err := writer.Write(buffer.Bytes())
+				return err
+			},
+			Close: writer.Flush,
+		}, nil
+	case encodeOutputContainer:
+		codec, err := container.CodecByName(*options.codecName)
+		if err != nil {
+			return nil, err
+		}
+		containerWriter, err := container.NewWriter(writer, schemaData, codec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write container header: %w", err)
+		}
+		return &datumWriter{
+			Write: containerWriter.Append,
+			Close: func() error {
+				if err := containerWriter.Close(); err != nil {
+					return err
+				}
+				return writer.Flush()
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %s, expected one of raw, container, framed, confluent", *options.outputFormat)
+}
+
+// registrySchemaId returns id of the schema under subject of the subject name strategy
+func registrySchemaId(options datumFlags, s schema.ItemSchema, schemaData []byte) (uint32, error) {
+	if *options.registry == "" && *options.registrySnapshot == "" {
+		return 0, fmt.Errorf("confluent output requires -registry or -registry-snapshot")
+	}
+	if *options.registry != "" && !isUrl(*options.registry) {
+		return 0, fmt.Errorf("confluent output requires registry url, got %s", *options.registry)
+	}
+	strategy, err := registry.StrategyByName(*options.subjectStrategy)
+	if err != nil {
+		return 0, err
+	}
+	subject, err := strategy(*options.topic, *options.key, s)
+	if err != nil {
+		return 0, err
+	}
+	client, err := newRegistryClient(*options.registry, *options.registryCache, *options.registrySnapshot)
+	if err != nil {
+		return 0, err
+	}
+	return client.SchemaId(subject, string(schemaData), nil, *options.autoRegister)
+}
+
+// readSchemaFile returns schema and its json
+func readSchemaFile(fileName string) (schema.ItemSchema, []byte, error) {
+	schemaData, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	parsedSchema, err := schema.ParseSchemaJSON(schemaData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse schema %s: %w", fileName, err)
+	}
+	return parsedSchema, schemaData, nil
+}
+
+// runEncode reads newline-delimited json records from input and writes them in avro binary
+// encoding, returns process exit code
+func runEncode(args []string, input io.Reader, output io.Writer) int {
+	flags := flag.NewFlagSet("encode", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s encode -s schema.avsc [flags] < records.json\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	schemaFile := flags.String("s", "", "path to file with avro schema to encode records with")
+	avroJson := flags.Bool("avro-json", false, "input is in avro json encoding: unions as {\"type\": value}, bytes as ISO-8859-1 strings; otherwise union types are chosen by values and bytes are base64")
+	datumOptions := addDatumFlags(flags)
+	_ = flags.Parse(args)
+	if *schemaFile == "" || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	parsedSchema, schemaData, err := readSchemaFile(*schemaFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	writer, err := newDatumWriter(output, datumOptions, parsedSchema, schemaData)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	exitCode := 0
+	if err := encodeLines(input, parsedSchema, *avroJson, writer.Write); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write output: %v\n", err)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+// encodeLines converts each non-empty line of input and passes it to writeDatum, errors
+// are reported with line number and path of the field
+func encodeLines(input io.Reader, s schema.ItemSchema, avroJson bool, writeDatum func(interface{}) error) error {
+	lines := bufio.NewReader(input)
+	for line := 1; ; line++ {
+		data, err := lines.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read line %d: %w", line, err)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			var value interface{}
+			if decodeErr := decoder.Decode(&value); decodeErr != nil {
+				return fmt.Errorf("line %d: failed to parse json: %w", line, decodeErr)
+			}
+			if decoder.More() {
+				return fmt.Errorf("line %d: unexpected data after json value", line)
+			}
+			converted, convertErr := schema.FromJSON(s, value, avroJson)
+			if convertErr != nil {
+				var pathErr *schema.PathError
+				if errors.As(convertErr, &pathErr) && pathErr.Path != "" {
+					return fmt.Errorf("line %d: field %s: %w", line, pathErr.Path, pathErr.Err)
+				}
+				return fmt.Errorf("line %d: %w", line, convertErr)
+			}
+			if writeErr := writeDatum(converted); writeErr != nil {
+				return fmt.Errorf("line %d: failed to encode record: %w", line, writeErr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
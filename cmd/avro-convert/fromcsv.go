@@ -0,0 +1,301 @@
+package main
+
+import (
+	"avroparser/pkg/output"
+	"avroparser/pkg/schema"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// runFromCsv reads csv rows from input and writes them as avro records, columns are mapped
+// to record fields by header names with dotted paths for fields of nested records.
+// Bad rows are reported and skipped, exit code is 1 if there were any.
+func runFromCsv(args []string, input io.Reader, w io.Writer) int {
+	flags := flag.NewFlagSet("from-csv", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s from-csv -s schema.avsc [flags] < records.csv\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	schemaFile := flags.String("s", "", "path to file with avro record schema to encode rows with")
+	delimiter := flags.String("delimiter", ",", "column delimiter, \\t for tsv")
+	nullValue := flags.String("null", output.DefaultCSVNull, "cell value standing for null, missing values of fields that are not nullable are set to defaults; empty cells of nullable fields that are not strings are null too")
+	ignoreUnknown := flags.Bool("ignore-unknown-columns", false, "skip columns that don't match any field instead of failing")
+	datumOptions := addDatumFlags(flags)
+	_ = flags.Parse(args)
+	if *schemaFile == "" || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+	if *delimiter == `\t` {
+		*delimiter = "\t"
+	}
+	comma, size := utf8.DecodeRuneInString(*delimiter)
+	if size == 0 || size != len(*delimiter) {
+		fmt.Fprintf(os.Stderr, "delimiter should be a single character, got %q\n", *delimiter)
+		return 2
+	}
+
+	parsedSchema, schemaData, err := readSchemaFile(*schemaFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	recordSchema, ok := parsedSchema.(schema.AvroRecord)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "schema should be a record, got %s\n", schema.TypeName(parsedSchema))
+		return 1
+	}
+
+	reader := csv.NewReader(input)
+	reader.Comma = comma
+	header, err := reader.Read()
+	if err == io.EOF {
+		fmt.Fprintln(os.Stderr, "input has no header")
+		return 1
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read header: %v\n", err)
+		return 1
+	}
+	mapping, err := newCsvMapping(recordSchema, header, *nullValue, *ignoreUnknown)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	writer, err := newDatumWriter(w, datumOptions, parsedSchema, schemaData)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	exitCode := 0
+	rows, badRows := 0, 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		rows++
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", parseErr.StartLine, parseErr.Err)
+			badRows++
+			continue
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read input: %v\n", err)
+			exitCode = 1
+			break
+		}
+		value, column, err := mapping.record(recordSchema, "", row)
+		if err != nil {
+			line, _ := reader.FieldPos(column)
+			fmt.Fprintf(os.Stderr, "line %d: column %s: %v\n", line, header[column], err)
+			badRows++
+			continue
+		}
+		if err = writer.Write(value); err != nil {
+			line, _ := reader.FieldPos(0)
+			fmt.Fprintf(os.Stderr, "line %d: failed to encode record: %v\n", line, err)
+			exitCode = 1
+			break
+		}
+	}
+	if err := writer.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write output: %v\n", err)
+		exitCode = 1
+	}
+	if badRows > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d rows skipped\n", badRows, rows)
+		exitCode = 1
+	}
+	return exitCode
+}
+
+///////////////////////
+
+// csvMapping maps header columns to fields of the record and its nested records
+type csvMapping struct {
+	columns   map[string]int
+	nullValue string
+}
+
+func newCsvMapping(recordSchema schema.AvroRecord, header []string, nullValue string, ignoreUnknown bool) (*csvMapping, error) {
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\xef\xbb\xbf")
+	}
+	m := &csvMapping{columns: make(map[string]int, len(header)), nullValue: nullValue}
+	for idx, name := range header {
+		if _, found := m.columns[name]; found {
+			return nil, fmt.Errorf("column %s is repeated in header", name)
+		}
+		m.columns[name] = idx
+	}
+	known := make(map[string]bool)
+	var missing []string
+	m.check(recordSchema, "", known, &missing, nil)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("columns %s are missing and fields have no defaults", strings.Join(missing, ", "))
+	}
+	if !ignoreUnknown {
+		for _, name := range header {
+			if !known[name] {
+				return nil, fmt.Errorf("column %s doesn't match any field of %s", name, recordSchema.FullName())
+			}
+		}
+	}
+	return m, nil
+}
+
+// check collects paths of fields and fields that have neither columns nor defaults
+func (m *csvMapping) check(t schema.AvroRecord, prefix string, known map[string]bool, missing *[]string, parents []string) {
+	parents = append(parents, t.FullName())
+	for _, f := range t.Fields() {
+		path := joinFieldPath(prefix, f.Name())
+		known[path] = true
+		if _, found := m.columns[path]; found {
+			continue
+		}
+		// fields of nested record are read from columns only if there are any
+		nested, _ := nestedRecord(f.Type())
+		if nested != nil && m.hasColumns(path) && !containsName(parents, nested.FullName()) {
+			m.check(*nested, path, known, missing, parents)
+		} else if _, hasDefault := f.Default(); !hasDefault {
+			*missing = append(*missing, path)
+		}
+	}
+}
+
+func (m *csvMapping) hasColumns(prefix string) bool {
+	for name := range m.columns {
+		if strings.HasPrefix(name, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// record returns value of the record from the row, index of the column is returned with error
+func (m *csvMapping) record(t schema.AvroRecord, prefix string, row []string) (map[string]interface{}, int, error) {
+	result := make(map[string]interface{}, len(t.Fields()))
+	for _, f := range t.Fields() {
+		path := joinFieldPath(prefix, f.Name())
+		if idx, found := m.columns[path]; found {
+			value, err := m.cell(f, row[idx])
+			if err != nil {
+				return nil, idx, err
+			}
+			result[f.Name()] = value
+		} else if nested, nullable := nestedRecord(f.Type()); nested != nil && m.hasColumns(path) {
+			if nullable && m.allNull(path, row) {
+				result[f.Name()] = nil
+				continue
+			}
+			value, idx, err := m.record(*nested, path, row)
+			if err != nil {
+				return nil, idx, err
+			}
+			result[f.Name()] = value
+		} else {
+			// missing fields have defaults, they are checked with the header
+			value, err := f.DefaultValue()
+			if err != nil {
+				return nil, 0, fmt.Errorf("field %s: %w", path, err)
+			}
+			result[f.Name()] = value
+		}
+	}
+	return result, 0, nil
+}
+
+func (m *csvMapping) cell(f schema.AvroRecordField, text string) (interface{}, error) {
+	if text != m.nullValue {
+		value, err := schema.FromText(f.Type(), text)
+		if err != nil && text == "" && isNullable(f.Type()) {
+			return nil, nil
+		}
+		return value, err
+	}
+	if isNullable(f.Type()) {
+		return nil, nil
+	}
+	if _, isString := f.Type().(schema.AvroString); isString {
+		return text, nil
+	}
+	if _, hasDefault := f.Default(); hasDefault {
+		return f.DefaultValue()
+	}
+	return nil, fmt.Errorf("value is missing and field has no default")
+}
+
+// allNull checks whether all columns of nested record are null
+func (m *csvMapping) allNull(prefix string, row []string) bool {
+	for name, idx := range m.columns {
+		if strings.HasPrefix(name, prefix+".") && row[idx] != m.nullValue {
+			return false
+		}
+	}
+	return true
+}
+
+// nestedRecord returns record type of the field, either the type itself or the only
+// non-null type of a union
+func nestedRecord(s schema.ItemSchema) (*schema.AvroRecord, bool) {
+	if record, ok := s.(schema.AvroRecord); ok {
+		return &record, false
+	}
+	union, ok := s.(schema.AvroUnion)
+	if !ok {
+		return nil, false
+	}
+	var result *schema.AvroRecord
+	nullable := false
+	for _, element := range union.Elements() {
+		switch t := element.(type) {
+		case schema.AvroNull:
+			nullable = true
+		case schema.AvroRecord:
+			if result != nil {
+				return nil, false
+			}
+			result = &t
+		default:
+			return nil, false
+		}
+	}
+	return result, nullable
+}
+
+func isNullable(s schema.ItemSchema) bool {
+	switch t := s.(type) {
+	case schema.AvroNull:
+		return true
+	case schema.AvroUnion:
+		for _, element := range t.Elements() {
+			if _, isNull := element.(schema.AvroNull); isNull {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsName(names []string, name string) bool {
+	for _, item := range names {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"avroparser/pkg/container"
+	"avroparser/pkg/idl"
+	"avroparser/pkg/output"
+	"avroparser/pkg/schema"
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// runSchema writes schema of container file or schema file, returns process exit code
+func runSchema(args []string, w io.Writer) int {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s schema [flags] file
+
+Writes schema of avro container file, schema file (.avsc) or avro IDL file (.avdl).
+Schemas of IDL files are written as json with named types defined at their first use.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	canonical := flags.Bool("canonical", false, "write parsing canonical form of the schema")
+	typeName := flags.String("type", "", "full name of the type from avro IDL file, main schema of IDL file is used by default")
+	files := parseArgs(flags, args)
+	if len(files) != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	parsedSchema, schemaData, err := loadSchema(files[0], *typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *canonical || schemaData == nil {
+		fmt.Fprintln(w, schema.CanonicalForm(parsedSchema))
+		return 0
+	}
+	indented := bytes.Buffer{}
+	if err = json.Indent(&indented, bytes.TrimSpace(schemaData), "", "  "); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to format schema: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(w, indented.String())
+	return 0
+}
+
+// runFingerprint writes fingerprints of parsing canonical forms of schemas, returns process
+// exit code
+func runFingerprint(args []string, w io.Writer) int {
+	flags := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s fingerprint [flags] file [file...]
+
+Writes fingerprints of schemas of avro container files, schema files (.avsc) or avro IDL
+files (.avdl). Fingerprints are computed from parsing canonical form of the schema,
+rabin is CRC-64-AVRO used by single object encoding.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	algorithm := flags.String("algorithm", "all", "fingerprint algorithm: rabin, md5, sha256 or all")
+	typeName := flags.String("type", "", "full name of the type from avro IDL file, main schema of IDL file is used by default")
+	files := parseArgs(flags, args)
+	if len(files) == 0 {
+		flags.Usage()
+		return 2
+	}
+	fingerprints := map[string]func(canonical string) string{
+		"rabin": func(canonical string) string {
+			return fmt.Sprintf("%016x", schema.Crc64([]byte(canonical)))
+		},
+		"md5": func(canonical string) string {
+			sum := md5.Sum([]byte(canonical))
+			return hex.EncodeToString(sum[:])
+		},
+		"sha256": func(canonical string) string {
+			sum := sha256.Sum256([]byte(canonical))
+			return hex.EncodeToString(sum[:])
+		},
+	}
+	algorithms := []string{"rabin", "md5", "sha256"}
+	if *algorithm != "all" {
+		if _, found := fingerprints[*algorithm]; !found {
+			fmt.Fprintf(os.Stderr, "unknown algorithm %s, expected one of rabin, md5, sha256, all\n", *algorithm)
+			return 2
+		}
+		algorithms = []string{*algorithm}
+	}
+
+	exitCode := 0
+	for _, fileName := range files {
+		parsedSchema, _, err := loadSchema(fileName, *typeName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+		canonical := schema.CanonicalForm(parsedSchema)
+		for _, name := range algorithms {
+			if len(algorithms) == 1 {
+				fmt.Fprintf(w, "%s  %s\n", fingerprints[name](canonical), fileName)
+			} else {
+				fmt.Fprintf(w, "%s  %s  %s\n", name, fingerprints[name](canonical), fileName)
+			}
+		}
+	}
+	return exitCode
+}
+
+// runMeta writes header metadata of container files, returns process exit code
+func runMeta(args []string, w io.Writer) int {
+	flags := flag.NewFlagSet("meta", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s meta [flags] file [file...]
+
+Writes metadata of avro container file headers, values that are not valid utf-8 are
+written as hex.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	asJson := flags.Bool("json", false, "write metadata of each file as json object")
+	files := parseArgs(flags, args)
+	if len(files) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	exitCode := 0
+	for _, fileName := range files {
+		header, err := readHeaderFile(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+		keys := make([]string, 0, len(header.Meta))
+		values := make(map[string]string, len(header.Meta))
+		for key, value := range header.Meta {
+			keys = append(keys, key)
+			if utf8.Valid(value) {
+				values[key] = string(value)
+			} else {
+				values[key] = hex.EncodeToString(value)
+			}
+		}
+		sort.Strings(keys)
+		if *asJson {
+			data, _ := json.Marshal(map[string]interface{}{"file": fileName, "meta": values, "sync": hex.EncodeToString(header.Sync[:])})
+			fmt.Fprintln(w, string(data))
+			continue
+		}
+		if len(files) > 1 {
+			fmt.Fprintf(w, "%s:\n", fileName)
+		}
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s: %s\n", key, strings.TrimSpace(values[key]))
+		}
+		fmt.Fprintf(w, "sync: %s\n", hex.EncodeToString(header.Sync[:]))
+	}
+	return exitCode
+}
+
+// runCount writes number of records of container files or datums read with the schema
+// source, returns process exit code
+func runCount(args []string, input io.Reader, w io.Writer) int {
+	flags := flag.NewFlagSet("count", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s count [flags] [file...]
+
+Writes number of records of avro container files, or of avro datums if schema source is
+set. Standard input is read if no files are given. Records are decoded, so records that
+can't be read are handled with the error policy. With -blocks numbers of records in block
+headers of container files are summed without decoding.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	source := addSourceFlags(flags)
+	where := addWhereFlag(flags)
+	errorOptions := addErrorFlags(flags)
+	blocks := flags.Bool("blocks", false, "count records of container files by block headers without decoding them")
+	files := parseArgs(flags, args)
+
+	if *blocks && (source.isSet() || *where != "" || errorOptions.isSet()) {
+		fmt.Fprintln(os.Stderr, "-blocks can't be used with schema source, -where, -on-error or -dead-letter as records aren't decoded")
+		return 2
+	}
+	filter, err := newWhereFilter(*where)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	counter := &countWriter{}
+	if *blocks {
+		if len(files) == 0 {
+			err = countBlocks("stdin", bufio.NewReader(input), counter)
+		}
+		for _, fileName := range files {
+			if err = countBlocksFile(fileName, counter); err != nil {
+				break
+			}
+		}
+	} else {
+		policy, policyErr := errorOptions.policy()
+		if policyErr != nil {
+			fmt.Fprintln(os.Stderr, policyErr)
+			return 2
+		}
+		err = readRecords(source, filter, policy, files, input, counter)
+		if closeErr := policy.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintln(w, counter.count)
+	return 0
+}
+
+// countWriter counts records instead of writing them
+type countWriter struct {
+	count int64
+}
+
+func (c *countWriter) Begin(output.Record) error {
+	return nil
+}
+
+func (c *countWriter) Record(output.Record) error {
+	c.count++
+	return nil
+}
+
+func (c *countWriter) End() error {
+	return nil
+}
+
+func countBlocksFile(fileName string, counter *countWriter) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return countBlocks(fileName, bufio.NewReader(f), counter)
+}
+
+// countBlocks sums record counts of container blocks
+func countBlocks(name string, r io.Reader, counter *countWriter) error {
+	if _, err := container.ReadHeader(r); err != nil {
+		return fmt.Errorf("%s: %w, set schema source to count avro datums", name, err)
+	}
+	for {
+		block, err := container.ReadBlock(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		counter.count += block.Count
+	}
+}
+
+///////////////////////
+
+func readHeaderFile(fileName string) (*container.Header, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	header, err := container.ReadHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return header, nil
+}
+
+// loadSchema reads schema of container file, IDL file or schema file along with its json
+func loadSchema(fileName, typeName string) (schema.ItemSchema, []byte, error) {
+	if strings.HasSuffix(fileName, ".avdl") {
+		document, err := idl.ParseFile(fileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsedSchema := document.Schema
+		if typeName == "" && parsedSchema == nil {
+			return nil, nil, fmt.Errorf("%s has no main schema, set type", fileName)
+		} else if typeName != "" {
+			var found bool
+			if parsedSchema, found = document.NamedType(typeName); !found {
+				return nil, nil, fmt.Errorf("type %s is not declared in %s", typeName, fileName)
+			}
+		}
+		jsonSchema, _ := document.SchemaJSON(typeName)
+		schemaData := bytes.Buffer{}
+		if err = encodeSchemaJSON(&schemaData, jsonSchema); err != nil {
+			return nil, nil, fmt.Errorf("failed to write schema of %s: %w", fileName, err)
+		}
+		return parsedSchema, schemaData.Bytes(), nil
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	if magic, _ := reader.Peek(4); bytes.Equal(magic, []byte("Obj\x01")) {
+		header, err := container.ReadHeader(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		return header.Schema, header.Meta[container.MetaSchema], nil
+	}
+	return readSchemaFile(fileName)
+}
+
+// order of attributes of types and fields in written schemas, other attributes follow
+// in alphabetical order
+var (
+	typeAttributes  = []string{"type", "name", "namespace", "doc", "aliases", "size", "symbols", "default", "items", "values", "fields"}
+	fieldAttributes = []string{"name", "type", "doc", "default", "order", "aliases"}
+)
+
+// encodeSchemaJSON writes json schema with attributes in the order they are usually written
+func encodeSchemaJSON(buffer *bytes.Buffer, jsonSchema interface{}) error {
+	switch t := jsonSchema.(type) {
+	case []interface{}:
+		buffer.WriteByte('[')
+		for idx, item := range t {
+			if idx > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := encodeSchemaJSON(buffer, item); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+		return nil
+	case map[string]interface{}:
+		return encodeObject(buffer, t, typeAttributes, func(key string, value interface{}) error {
+			switch key {
+			case "type", "items", "values":
+				return encodeSchemaJSON(buffer, value)
+			case "fields":
+				fields, ok := value.([]interface{})
+				if !ok {
+					return encodeJSON(buffer, value)
+				}
+				buffer.WriteByte('[')
+				for idx, field := range fields {
+					if idx > 0 {
+						buffer.WriteByte(',')
+					}
+					if err := encodeField(buffer, field); err != nil {
+						return err
+					}
+				}
+				buffer.WriteByte(']')
+				return nil
+			}
+			return encodeJSON(buffer, value)
+		})
+	}
+	return encodeJSON(buffer, jsonSchema)
+}
+
+func encodeField(buffer *bytes.Buffer, field interface{}) error {
+	fieldMap, ok := field.(map[string]interface{})
+	if !ok {
+		return encodeJSON(buffer, field)
+	}
+	return encodeObject(buffer, fieldMap, fieldAttributes, func(key string, value interface{}) error {
+		if key == "type" {
+			return encodeSchemaJSON(buffer, value)
+		}
+		return encodeJSON(buffer, value)
+	})
+}
+
+// encodeObject writes attributes in the order followed by other attributes sorted by name
+func encodeObject(buffer *bytes.Buffer, object map[string]interface{}, order []string, encodeValue func(key string, value interface{}) error) error {
+	keys := make([]string, 0, len(object))
+	known := make(map[string]bool, len(order))
+	for _, key := range order {
+		known[key] = true
+		if _, found := object[key]; found {
+			keys = append(keys, key)
+		}
+	}
+	others := make([]string, 0, len(object))
+	for key := range object {
+		if !known[key] {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	buffer.WriteByte('{')
+	for idx, key := range append(keys, others...) {
+		if idx > 0 {
+			buffer.WriteByte(',')
+		}
+		if err := encodeJSON(buffer, key); err != nil {
+			return err
+		}
+		buffer.WriteByte(':')
+		if err := encodeValue(key, object[key]); err != nil {
+			return err
+		}
+	}
+	buffer.WriteByte('}')
+	return nil
+}
+
+// encodeJSON writes value as json without escaping html characters, as docs are written
+// as they are
+func encodeJSON(buffer *bytes.Buffer, value interface{}) error {
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	// encoder ends value with new line
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
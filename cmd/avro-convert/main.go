@@ -1,71 +1,118 @@
 package main
 
 import (
-	"avroparser/pkg/provider"
-	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
-func main() {
-	staticSchema := flag.String("s", "", "path to file with avro schema for source data")
-	flag.Parse()
-
-	var streamConverter provider.StreamConverter
-	var err error
+// Version and GitHead are set by linker flags of release builds
+var (
+	Version = "snapshot"
+	GitHead = ""
+)
 
-	if *staticSchema != "" {
-		streamConverter, err = provider.NewStaticFileStreamConverter(*staticSchema)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		panic("stream converter / schema provider is not set")
-	}
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
 
-	var input io.Reader
-	input = os.Stdin
+var commands = []command{
+	{"cat", "write records of container files or avro datums as json, csv, parquet, msgpack or cbor", func(args []string) int {
+		return runCat(args, os.Stdin, os.Stdout)
+	}},
+	{"query", "run SQL select over records of container file", func(args []string) int {
+		return runQuery(args, os.Stdout)
+	}},
+	{"count", "count records of container files or avro datums", func(args []string) int {
+		return runCount(args, os.Stdin, os.Stdout)
+	}},
+	{"encode", "encode json records to avro datums or container file", func(args []string) int {
+		return runEncode(args, os.Stdin, os.Stdout)
+	}},
+	{"from-csv", "encode csv rows to avro datums or container file", func(args []string) int {
+		return runFromCsv(args, os.Stdin, os.Stdout)
+	}},
+	{"schema", "write schema of container file, schema file or IDL file", func(args []string) int {
+		return runSchema(args, os.Stdout)
+	}},
+	{"fingerprint", "write fingerprints of schemas", func(args []string) int {
+		return runFingerprint(args, os.Stdout)
+	}},
+	{"meta", "write header metadata of container files", func(args []string) int {
+		return runMeta(args, os.Stdout)
+	}},
+	{"verify", "check integrity of container files", func(args []string) int {
+		return runVerify(args, os.Stdout)
+	}},
+}
 
-	var output io.Writer
-	output = os.Stdout
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	hasData := true
-	for hasData {
-		wrapper := provider.NewCountingReader(input)
-		data, err := streamConverter.Next(&wrapper)
-		if nil != err {
-			if provider.IsEof(err) && wrapper.ReadBytes() == 0 {
-				hasData = false
-			} else {
-				panic(err)
-			}
-		} else {
-			displayData(data, output)
+// run dispatches arguments to the command, flags without command are flags of cat as
+// avro-convert used to have the only mode of cat
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		if len(args) > 1 && args[0] == "help" {
+			return run([]string{args[1], "-help"})
 		}
+		usage(os.Stdout)
+		return 0
+	case "-version", "--version", "version":
+		fmt.Println(versionString())
+		return 0
 	}
-}
-
-func displayData(data []provider.DataChunk, output io.Writer) {
-	if len(data) == 0 {
-		return
+	if strings.HasPrefix(args[0], "-") {
+		return runCat(args, os.Stdin, os.Stdout)
 	}
-	var toDisplay interface{}
-	if len(data) == 1 && data[0].Name() == "" {
-		toDisplay = data[0].Value()
-	} else {
-		mp := make(map[string]interface{})
-		for _, ch := range data {
-			mp[ch.Name()] = ch.Value()
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
 		}
-		toDisplay = mp
 	}
-	dataBytes, err := json.Marshal(toDisplay)
-	if err != nil {
-		panic(err)
+	fmt.Fprintf(os.Stderr, "unknown command %s\n\n", args[0])
+	usage(os.Stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun %s <command> -help for flags of the command, %s -version for version.\n", os.Args[0], os.Args[0])
+}
+
+func versionString() string {
+	if GitHead == "" {
+		return "avro-convert " + Version
 	}
-	_, err = output.Write(dataBytes)
-	if err != nil {
-		panic(err)
+	return fmt.Sprintf("avro-convert %s (%s)", Version, GitHead)
+}
+
+// parseArgs parses flags placed before and after positional arguments and returns the
+// positional arguments, arguments after -- are never parsed as flags. Flag set should exit
+// on errors.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = flags.Parse(args)
+		if parsed := len(args) - flags.NArg(); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, flags.Args()...)
+		} else if flags.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
 	}
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"avroparser/pkg/output"
+	"avroparser/pkg/parquet"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// outputFlags are flags of commands writing records in one of output formats
+type outputFlags struct {
+	format         *string
+	parquetCodec   *string
+	csvNull        *string
+	csvExplode     *bool
+	jsonArray      *bool
+	jsonPretty     *bool
+	avroJson       *bool
+	longsAsStrings *bool
+	sortKeys       *bool
+}
+
+func addOutputFlags(flags *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		format:         flags.String("output", "json", "output format: json, csv, tsv, parquet, msgpack or cbor"),
+		parquetCodec:   flags.String("parquet-codec", "snappy", "compression of parquet pages: uncompressed, snappy or gzip"),
+		csvNull:        flags.String("csv-null", output.DefaultCSVNull, "value written to csv and tsv cells for nulls"),
+		csvExplode:     flags.Bool("csv-explode", false, "write a csv row for each array item and map entry instead of writing arrays and maps as json"),
+		jsonArray:      flags.Bool("json-array", false, "write all records as single json array instead of newline-delimited json"),
+		jsonPretty:     flags.Bool("json-pretty", false, "pretty-print json records"),
+		avroJson:       flags.Bool("avro-json", false, "write records in avro json encoding: unions as {\"type\": value}, bytes as ISO-8859-1 strings"),
+		longsAsStrings: flags.Bool("longs-as-strings", false, "write long values as json strings to avoid precision loss in javascript"),
+		sortKeys:       flags.Bool("sort-keys", false, "sort record fields by name instead of schema order"),
+	}
+}
+
+func (f *outputFlags) writer(w io.Writer) (output.Writer, error) {
+	return newOutputWriter(*f.format, w, output.JSONOptions{
+		Array:          *f.jsonArray,
+		Pretty:         *f.jsonPretty,
+		AvroEncoding:   *f.avroJson,
+		LongsAsStrings: *f.longsAsStrings,
+		SortKeys:       *f.sortKeys,
+	}, output.CSVOptions{Null: *f.csvNull, Explode: *f.csvExplode}, *f.parquetCodec)
+}
+
+// newOutputWriter creates writer of output format, options of other formats are ignored
+func newOutputWriter(format string, w io.Writer, jsonOptions output.JSONOptions, csvOptions output.CSVOptions, parquetCodec string) (output.Writer, error) {
+	switch format {
+	case "json":
+		return output.NewJSONWriter(w, jsonOptions), nil
+	case "csv":
+		return output.NewCSVWriter(w, csvOptions), nil
+	case "tsv":
+		csvOptions.Delimiter = '\t'
+		return output.NewCSVWriter(w, csvOptions), nil
+	case "parquet":
+		return output.NewParquetWriter(w, parquet.Options{Codec: parquetCodec}), nil
+	case "msgpack":
+		return output.NewMessagePackWriter(w), nil
+	case "cbor":
+		return output.NewCBORWriter(w), nil
+	}
+	return nil, fmt.Errorf("unknown output format %s, expected one of json, csv, tsv, parquet, msgpack, cbor", format)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	onErrorFail = "fail"
+	onErrorSkip = "skip"
+	onErrorLog  = "log"
+)
+
+// errorFlags are flags of commands reading records, they select what happens to records
+// that can't be read
+type errorFlags struct {
+	onError    *string
+	deadLetter *string
+}
+
+func addErrorFlags(flags *flag.FlagSet) *errorFlags {
+	return &errorFlags{
+		onError:    flags.String("on-error", onErrorFail, "what to do with records that can't be read: fail, skip or log to stderr and skip"),
+		deadLetter: flags.String("dead-letter", "", "file to write raw data of records that can't be read to, with record index, byte offset and error as json lines"),
+	}
+}
+
+// isSet checks whether error policy differs from failing without dead letter file
+func (f *errorFlags) isSet() bool {
+	return *f.onError != onErrorFail || *f.deadLetter != ""
+}
+
+func (f *errorFlags) policy() (*errorPolicy, error) {
+	switch *f.onError {
+	case onErrorFail, onErrorSkip, onErrorLog:
+	default:
+		return nil, fmt.Errorf("unknown error policy %s, expected one of fail, skip, log", *f.onError)
+	}
+	policy := &errorPolicy{mode: *f.onError, log: os.Stderr}
+	if *f.deadLetter != "" {
+		file, err := os.Create(*f.deadLetter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dead letter file: %w", err)
+		}
+		policy.deadLetterFile = file
+		policy.deadLetter = bufio.NewWriter(file)
+	}
+	return policy, nil
+}
+
+///////////////////////
+
+// failure is a record that can't be read, as written to dead letter file
+type failure struct {
+	Source string `json:"source"`
+	// Index is index of the record in the source
+	Index int64 `json:"index"`
+	// Offset is byte offset in the source where reading of the record started, for container
+	// files it is offset of the block
+	Offset int64 `json:"offset"`
+	// Count is number of records lost, records of container block after the failed one can't
+	// be read either
+	Count int64  `json:"count"`
+	Error string `json:"error"`
+	Data  []byte `json:"data"`
+}
+
+type errorPolicy struct {
+	mode           string
+	log            io.Writer
+	deadLetterFile *os.File
+	deadLetter     *bufio.Writer
+	skipped        int64
+}
+
+// handle writes the failure to dead letter file and returns nil if reading should continue
+// with the next record, records are skipped only if reading can resume after them
+func (p *errorPolicy) handle(f failure, err error, resumable bool) error {
+	f.Error = err.Error()
+	if p.deadLetter != nil {
+		if data, marshalErr := json.Marshal(f); marshalErr != nil {
+			return marshalErr
+		} else if _, writeErr := p.deadLetter.Write(append(data, '\n')); writeErr != nil {
+			return fmt.Errorf("failed to write dead letter file: %w", writeErr)
+		}
+	}
+	err = fmt.Errorf("%s: record %d at offset %d: %w", f.Source, f.Index, f.Offset, err)
+	if p.mode == onErrorFail {
+		return err
+	} else if !resumable {
+		return fmt.Errorf("%w, reading can't resume as position of the next record is unknown", err)
+	}
+	p.skipped += f.Count
+	if p.mode == onErrorLog {
+		if f.Count > 1 {
+			fmt.Fprintf(p.log, "skipped %d records: %v\n", f.Count, err)
+		} else {
+			fmt.Fprintf(p.log, "skipped record: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// close flushes dead letter file and reports number of skipped records if they are logged
+func (p *errorPolicy) close() error {
+	if p.mode == onErrorLog && p.skipped > 0 {
+		fmt.Fprintf(p.log, "records skipped: %d\n", p.skipped)
+	}
+	if p.deadLetterFile == nil {
+		return nil
+	}
+	if err := p.deadLetter.Flush(); err != nil {
+		p.deadLetterFile.Close()
+		return fmt.Errorf("failed to write dead letter file: %w", err)
+	}
+	return p.deadLetterFile.Close()
+}
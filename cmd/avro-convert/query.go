@@ -0,0 +1,77 @@
+package main
+
+import (
+	"avroparser/pkg/container"
+	"avroparser/pkg/query"
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runQuery runs select statement over records of avro container file and returns process
+// exit code
+func runQuery(args []string, w io.Writer) int {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), `Usage: %s query [flags] "SELECT ... FROM file.avro [WHERE ...] [GROUP BY ...] [ORDER BY ... [DESC]] [LIMIT n]"
+
+Record fields are columns, nested fields are selected with paths like customer.address.city,
+items[0].sku or attributes['key']. Aggregate functions are count(*), count(x),
+count(distinct x), sum, min, max and avg.
+
+`, os.Args[0])
+		flags.PrintDefaults()
+	}
+	outputOptions := addOutputFlags(flags)
+	arguments := parseArgs(flags, args)
+	if len(arguments) != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	q, err := query.Parse(arguments[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse query: %v\n", err)
+		return 2
+	}
+	f, err := os.Open(q.From)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	reader, err := container.NewReader(bufio.NewReader(f))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", q.From, err)
+		return 1
+	}
+	plan, err := query.NewPlan(q, reader.Header().Schema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid query: %v\n", err)
+		return 2
+	}
+	out := bufio.NewWriter(w)
+	writer, err := outputOptions.writer(out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	exitCode := 0
+	if err = writer.Begin(plan.Header()); err == nil {
+		if err = plan.Run(reader.Next, writer.Record); err == nil {
+			err = writer.End()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "query failed: %v\n", err)
+		exitCode = 1
+	}
+	if err = out.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write output: %v\n", err)
+		exitCode = 1
+	}
+	return exitCode
+}
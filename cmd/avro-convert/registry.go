@@ -0,0 +1,43 @@
+package main
+
+import (
+	"avroparser/pkg/registry"
+	"os"
+)
+
+// newRegistryClient creates client for registry url with optional disk cache, snapshot is
+// imported into the caches if set, so that client can work without registry
+func newRegistryClient(url, cacheDir, snapshot string) (*registry.Client, error) {
+	caches := []registry.Cache{registry.NewMemoryCache()}
+	if cacheDir != "" {
+		diskCache, err := registry.NewDiskCache(cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, diskCache)
+	}
+	client := registry.NewClient(url, nil, caches...)
+	if snapshot != "" {
+		f, err := os.Open(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err = client.Import(f); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+func exportSnapshot(client *registry.Client, fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err = client.Export(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
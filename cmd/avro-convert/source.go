@@ -0,0 +1,212 @@
+package main
+
+import (
+	"avroparser/pkg/idl"
+	"avroparser/pkg/protocol"
+	"avroparser/pkg/provider"
+	"avroparser/pkg/registry"
+	"avroparser/pkg/schema"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var errNoSchemaSource = errors.New("schema source is not set, use -s, -registry, -registry-snapshot, -single-object, -glue-registry or -protocol")
+
+// sourceFlags are flags of commands reading avro datums, they select where schemas of
+// datums come from and how datums are wrapped in the stream
+type sourceFlags struct {
+	staticSchema           *string
+	typeName               *string
+	schemaDir              *string
+	registryLocation       *string
+	registryCache          *string
+	registrySnapshot       *string
+	exportRegistrySnapshot *string
+	singleObject           *string
+	glueRegistry           *string
+	protocolFile           *string
+	messageName            *string
+	messagePart            *string
+	framing                *string
+	keySchema              *string
+	headersSchema          *string
+	kafkaSegment           *bool
+	kcat                   *bool
+	kcatBase64             *bool
+	// static is schema of every datum if it is known before data is read
+	static schema.ItemSchema
+}
+
+func addSourceFlags(flags *flag.FlagSet) *sourceFlags {
+	return &sourceFlags{
+		staticSchema:           flags.String("s", "", "path to file with avro schema (.avsc) or avro IDL (.avdl) for source data"),
+		typeName:               flags.String("type", "", "full name of the type from avro IDL file set with -s to read data with, main schema of IDL file is used by default"),
+		schemaDir:              flags.String("schema-dir", "", "directory with avro schemas that can be referenced from schema set with -s"),
+		registryLocation:       flags.String("registry", "", "confluent schema registry url or directory with <id>.avsc files for data in confluent wire format, cache and snapshot flags apply only to url"),
+		registryCache:          flags.String("registry-cache", "", "directory to cache schemas fetched from schema registry"),
+		registrySnapshot:       flags.String("registry-snapshot", "", "registry snapshot file to read schemas for data in confluent wire format from, registry is queried only for missing schemas"),
+		exportRegistrySnapshot: flags.String("export-registry-snapshot", "", "file to export all schemas fetched from registry to after conversion"),
+		singleObject:           flags.String("single-object", "", "directory with avro schemas for data in single object encoding"),
+		glueRegistry:           flags.String("glue-registry", "", "directory with avro schemas named <schema version uuid>.avsc for data in glue schema registry format"),
+		protocolFile:           flags.String("protocol", "", "path to avro protocol (.avpr or .avdl) file for rpc payloads of message set with -message"),
+		messageName:            flags.String("message", "", "name of protocol message to read payloads of"),
+		messagePart:            flags.String("message-part", "request", "part of protocol message to read: request, response or error"),
+		framing:                flags.String("framing", "", "length prefix of each datum in the stream: varint, be32 or le32"),
+		keySchema:              flags.String("key-schema", "", "path to file with avro schema for message keys, message key is expected before value"),
+		headersSchema:          flags.String("headers-schema", "", "path to file with avro schema for message headers, headers are expected after key and value read with -key-schema"),
+		kafkaSegment:           flags.Bool("kafka-segment", false, "input is kafka log segment file, record values are read with the schema source and keys with -key-schema"),
+		kcat:                   flags.Bool("kcat", false, "input is line-delimited json produced by kcat -J, payloads are read with the schema source and keys with -key-schema"),
+		kcatBase64:             flags.Bool("kcat-base64", false, "keys and payloads of kcat json are base64 encoded"),
+	}
+}
+
+// isSet checks whether any schema source is set
+func (f *sourceFlags) isSet() bool {
+	return *f.protocolFile != "" || *f.staticSchema != "" || *f.registryLocation != "" || *f.registrySnapshot != "" ||
+		*f.singleObject != "" || *f.glueRegistry != ""
+}
+
+// streamConverter creates converter of the schema source with wrappers of messages and
+// frames, registry client is returned if schemas are fetched from registry
+func (f *sourceFlags) streamConverter() (provider.StreamConverter, *registry.Client, error) {
+	var streamConverter provider.StreamConverter
+	var registryClient *registry.Client
+	var err error
+
+	if *f.protocolFile != "" {
+		if streamConverter, err = newMessageStreamConverter(*f.protocolFile, *f.messageName, *f.messagePart); err != nil {
+			return nil, nil, err
+		}
+	} else if strings.HasSuffix(*f.staticSchema, ".avdl") {
+		document, err := idl.ParseFile(*f.staticSchema)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsedSchema := document.Schema
+		if *f.typeName != "" {
+			var found bool
+			if parsedSchema, found = document.NamedType(*f.typeName); !found {
+				return nil, nil, fmt.Errorf("type %s is not declared in %s", *f.typeName, *f.staticSchema)
+			}
+		} else if parsedSchema == nil {
+			return nil, nil, fmt.Errorf("%s has no main schema, set type to read data with", *f.staticSchema)
+		}
+		streamConverter = provider.NewStaticStreamConverter(parsedSchema)
+	} else if *f.staticSchema != "" && *f.schemaDir != "" {
+		schemaProvider, err := provider.NewDirectorySchemaProvider(*f.schemaDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		schemaData, err := ioutil.ReadFile(*f.staticSchema)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsedSchema, err := schemaProvider.Parse(schemaData)
+		if err != nil {
+			return nil, nil, err
+		}
+		streamConverter = provider.NewStaticStreamConverter(parsedSchema)
+	} else if *f.staticSchema != "" {
+		if streamConverter, err = provider.NewStaticFileStreamConverter(*f.staticSchema); err != nil {
+			return nil, nil, err
+		}
+	} else if *f.registryLocation != "" && !isUrl(*f.registryLocation) {
+		if *f.registryCache != "" || *f.registrySnapshot != "" || *f.exportRegistrySnapshot != "" {
+			return nil, nil, fmt.Errorf("-registry-cache, -registry-snapshot and -export-registry-snapshot require registry url, got %s", *f.registryLocation)
+		}
+		streamConverter = provider.NewConfluentStreamConverter(provider.NewDirectorySchemaRegistry(*f.registryLocation))
+	} else if *f.registryLocation != "" || *f.registrySnapshot != "" {
+		if registryClient, err = newRegistryClient(*f.registryLocation, *f.registryCache, *f.registrySnapshot); err != nil {
+			return nil, nil, err
+		}
+		streamConverter = provider.NewConfluentStreamConverter(registryClient)
+	} else if *f.singleObject != "" {
+		store, err := provider.NewDirectorySchemaStore(*f.singleObject)
+		if err != nil {
+			return nil, nil, err
+		}
+		streamConverter = provider.NewSingleObjectStreamConverter(store)
+	} else if *f.glueRegistry != "" {
+		streamConverter = provider.NewGlueStreamConverter(provider.NewDirectoryGlueSchemaRegistry(*f.glueRegistry))
+	} else {
+		return nil, nil, errNoSchemaSource
+	}
+	if static, ok := streamConverter.(*provider.StaticFileSchema); ok && !*f.kafkaSegment && !*f.kcat && *f.keySchema == "" {
+		f.static = static.Schema()
+	}
+
+	if *f.headersSchema != "" && (*f.kafkaSegment || *f.kcat) {
+		// headers of kafka records are pairs of keys and values rather than a datum
+		return nil, nil, fmt.Errorf("-headers-schema can't be used with -kafka-segment or -kcat, headers of kafka records are written as they are")
+	} else if *f.headersSchema != "" && *f.keySchema == "" {
+		return nil, nil, fmt.Errorf("-headers-schema requires -key-schema, headers are read after key and value")
+	}
+	if *f.kafkaSegment || *f.kcat {
+		var keyConverter provider.StreamConverter
+		if *f.keySchema != "" {
+			if keyConverter, err = provider.NewStaticFileStreamConverter(*f.keySchema); err != nil {
+				return nil, nil, err
+			}
+		}
+		if *f.kafkaSegment {
+			streamConverter = provider.NewKafkaSegmentStreamConverter(keyConverter, streamConverter)
+		} else {
+			streamConverter = provider.NewKcatStreamConverter(keyConverter, streamConverter, *f.kcatBase64)
+		}
+	} else if *f.keySchema != "" {
+		keyConverter, err := provider.NewStaticFileStreamConverter(*f.keySchema)
+		if err != nil {
+			return nil, nil, err
+		}
+		var headersConverter provider.StreamConverter
+		if *f.headersSchema != "" {
+			if headersConverter, err = provider.NewStaticFileStreamConverter(*f.headersSchema); err != nil {
+				return nil, nil, err
+			}
+		}
+		streamConverter = provider.NewKeyValueStreamConverter(keyConverter, streamConverter, headersConverter)
+	}
+	if *f.framing != "" {
+		frameLength, err := provider.ParseFrameLength(*f.framing)
+		if err != nil {
+			return nil, nil, err
+		}
+		streamConverter = provider.NewFramedStreamConverter(frameLength, streamConverter)
+	}
+	return streamConverter, registryClient, nil
+}
+
+// exportSnapshot writes schemas fetched by registry client if snapshot export is requested
+func (f *sourceFlags) exportSnapshot(registryClient *registry.Client) error {
+	if registryClient == nil || *f.exportRegistrySnapshot == "" {
+		return nil
+	}
+	return exportSnapshot(registryClient, *f.exportRegistrySnapshot)
+}
+
+func newMessageStreamConverter(protocolFile, messageName, part string) (provider.StreamConverter, error) {
+	parsedProtocol, err := protocol.ParseFile(protocolFile)
+	if err != nil {
+		return nil, err
+	}
+	message, found := parsedProtocol.Message(messageName)
+	if !found {
+		return nil, fmt.Errorf("message %q is not declared in protocol %s, messages are %v", messageName, parsedProtocol.FullName(), parsedProtocol.MessageNames())
+	}
+	switch part {
+	case "request":
+		return provider.NewStaticStreamConverter(message.RequestSchema()), nil
+	case "response":
+		return provider.NewStaticStreamConverter(message.Response), nil
+	case "error":
+		return provider.NewStaticStreamConverter(message.Errors), nil
+	}
+	return nil, fmt.Errorf("unknown message part %q, expected request, response or error", part)
+}
+
+func isUrl(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
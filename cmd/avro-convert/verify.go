@@ -0,0 +1,51 @@
+package main
+
+import (
+	"avroparser/pkg/container"
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runVerify checks container files passed as arguments and returns process exit code
+func runVerify(args []string, output io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s verify file.avro [file.avro...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	files := parseArgs(flags, args)
+	if len(files) == 0 {
+		flags.Usage()
+		return 2
+	}
+	exitCode := 0
+	for _, fileName := range files {
+		if !verifyFile(fileName, output) {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+func verifyFile(fileName string, output io.Writer) bool {
+	f, err := os.Open(fileName)
+	if err != nil {
+		fmt.Fprintf(output, "%s: FAILED: %v\n", fileName, err)
+		return false
+	}
+	defer f.Close()
+	report := container.Verify(bufio.NewReader(f))
+	if report.Ok() {
+		fmt.Fprintf(output, "%s: OK: %d blocks, %d records\n", fileName, report.Blocks, report.Records)
+		return true
+	}
+	fmt.Fprintf(output, "%s: FAILED: %d problems in %d blocks (%d records decoded)\n",
+		fileName, len(report.Problems), report.Blocks, report.Records)
+	for _, problem := range report.Problems {
+		fmt.Fprintf(output, "  %s\n", problem)
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"avroparser/pkg/expr"
+	"avroparser/pkg/output"
+	"avroparser/pkg/provider"
+	"avroparser/pkg/schema"
+	"fmt"
+	"strings"
+)
+
+// whereFilter selects records with -where expression. Expression is type-checked for each
+// distinct set of record schemas as data read with schema registry may have many schemas.
+// Expression is compiled once for container files and schemas known before data is read.
+type whereFilter struct {
+	expression expr.Expr
+	compiled   map[string]*expr.Compiled
+}
+
+// newWhereFilter parses the expression, filter of empty expression is nil and matches
+// every record
+func newWhereFilter(text string) (*whereFilter, error) {
+	if text == "" {
+		return nil, nil
+	}
+	expression, err := expr.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse where expression: %w", err)
+	}
+	return &whereFilter{expression: expression, compiled: map[string]*expr.Compiled{}}, nil
+}
+
+// check compiles expression for records of the schema before data is read
+func (w *whereFilter) check(s schema.ItemSchema) error {
+	_, err := w.forSchema(s)
+	return err
+}
+
+// forSchema returns expression compiled for records of the schema, like records of container
+// file, it is nil for nil filter
+func (w *whereFilter) forSchema(s schema.ItemSchema) (*expr.Compiled, error) {
+	if w == nil {
+		return nil, nil
+	}
+	return w.compile(output.Record{{Schema: s}})
+}
+
+// forRecord returns expression compiled for the record, compiled expressions are cached by
+// the key identifying names and schemas of record fields. It is nil for nil filter.
+func (w *whereFilter) forRecord(key string, record output.Record) (*expr.Compiled, error) {
+	if w == nil {
+		return nil, nil
+	}
+	if compiled, found := w.compiled[key]; found {
+		return compiled, nil
+	}
+	compiled, err := w.compile(record)
+	if err != nil {
+		return nil, err
+	}
+	w.compiled[key] = compiled
+	return compiled, nil
+}
+
+// compile returns expression compiled for the scope of the record, fields of record of
+// single unnamed field are columns, named fields are columns otherwise
+func (w *whereFilter) compile(record output.Record) (*expr.Compiled, error) {
+	var scope expr.Scope
+	if len(record) == 1 && record[0].Name == "" {
+		var err error
+		if scope, err = expr.RecordScope(record[0].Schema); err != nil {
+			return nil, fmt.Errorf("where expression can be used only for records: %w", err)
+		}
+	} else {
+		for _, field := range record {
+			scope = append(scope, expr.NewColumn(field.Name, field.Schema))
+		}
+	}
+	compiled, err := expr.CompileFilter(w.expression, scope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression: %w", err)
+	}
+	return compiled, nil
+}
+
+// matchRecord checks whether the record matches compiled filter, every record matches nil
+// filter
+func matchRecord(compiled *expr.Compiled, record output.Record) bool {
+	if compiled == nil {
+		return true
+	}
+	if len(record) == 1 && record[0].Name == "" {
+		row, _ := record[0].Value.(map[string]interface{})
+		return compiled.Matches(row)
+	}
+	row := make(map[string]interface{}, len(record))
+	for _, field := range record {
+		row[field.Name] = field.Value
+	}
+	return compiled.Matches(row)
+}
+
+// recordKey identifies names and schemas of chunks, schemas without id are identified by
+// their canonical forms
+func recordKey(data []provider.DataChunk) string {
+	var key strings.Builder
+	for _, chunk := range data {
+		key.WriteString(chunk.Name())
+		key.WriteByte(0)
+		if id := chunk.SchemaId(); id != "" {
+			key.WriteString(id)
+		} else if chunk.Schema() != nil {
+			key.WriteString(schema.CanonicalForm(chunk.Schema()))
+		}
+		key.WriteByte(0)
+	}
+	return key.String()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	directory := flag.String("dir", "registry", "directory to keep schemas and subjects in")
+	listen := flag.String("listen", ":8081", "address to listen on")
+	compatibility := flag.String("compatibility", CompatibilityBackward, "default compatibility level")
+	flag.Parse()
+
+	if !isCompatibilityLevel(*compatibility) {
+		log.Fatalf("unknown compatibility level %s", *compatibility)
+	}
+	s, err := openStore(*directory, *compatibility)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving schema registry from %s on %s", *directory, *listen)
+	log.Fatal(http.ListenAndServe(*listen, newServer(s)))
+}
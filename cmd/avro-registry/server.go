@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+type server struct {
+	store *store
+}
+
+func newServer(s *store) *server {
+	return &server{store: s}
+}
+
+type schemaRequest struct {
+	Schema     string            `json:"schema"`
+	SchemaType string            `json:"schemaType,omitempty"`
+	References []schemaReference `json:"references,omitempty"`
+}
+
+type configRequest struct {
+	Compatibility string `json:"compatibility"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if registryErr, ok := err.(registryError); ok {
+		writeJSON(w, registryErr.status, errorResponse{ErrorCode: registryErr.code, Message: registryErr.message})
+	} else {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{ErrorCode: 50001, Message: err.Error()})
+	}
+}
+
+func readSchemaRequest(r *http.Request) (schemaRequest, error) {
+	request := schemaRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return request, registryError{http.StatusBadRequest, 400, fmt.Sprintf("failed to parse request: %v", err)}
+	}
+	if request.SchemaType != "" && request.SchemaType != "AVRO" {
+		return request, registryError{http.StatusUnprocessableEntity, 42201, fmt.Sprintf("schema type %s is not supported", request.SchemaType)}
+	}
+	for _, reference := range request.References {
+		if reference.Name == "" || reference.Subject == "" || reference.Version <= 0 {
+			return request, registryError{http.StatusUnprocessableEntity, 42201, fmt.Sprintf("reference %q should have name, subject and version", reference.Name)}
+		}
+	}
+	return request, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for idx, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[idx] = unescaped
+		}
+	}
+	result, err := s.route(r, segments)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if raw, ok := result.(rawSchema); ok {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write([]byte(raw))
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+type rawSchema string
+
+var errNotFound = registryError{http.StatusNotFound, 404, "HTTP 404 Not Found"}
+var errMethodNotAllowed = registryError{http.StatusMethodNotAllowed, 405, "HTTP 405 Method Not Allowed"}
+
+func (s *server) route(r *http.Request, segments []string) (interface{}, error) {
+	permanent := r.URL.Query().Get("permanent") == "true"
+	switch {
+	case len(segments) == 1 && segments[0] == "subjects":
+		if r.Method != http.MethodGet {
+			return nil, errMethodNotAllowed
+		}
+		return s.store.Subjects(), nil
+	case len(segments) == 2 && segments[0] == "subjects":
+		switch r.Method {
+		case http.MethodPost:
+			request, err := readSchemaRequest(r)
+			if err != nil {
+				return nil, err
+			}
+			return s.store.Lookup(segments[1], request.Schema, request.References)
+		case http.MethodDelete:
+			return s.store.DeleteSubject(segments[1], permanent)
+		}
+		return nil, errMethodNotAllowed
+	case len(segments) == 3 && segments[0] == "subjects" && segments[2] == "versions":
+		switch r.Method {
+		case http.MethodGet:
+			return s.store.Versions(segments[1])
+		case http.MethodPost:
+			request, err := readSchemaRequest(r)
+			if err != nil {
+				return nil, err
+			}
+			id, err := s.store.Register(segments[1], request.Schema, request.References)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]int{"id": id}, nil
+		}
+		return nil, errMethodNotAllowed
+	case len(segments) == 4 && segments[0] == "subjects" && segments[2] == "versions":
+		switch r.Method {
+		case http.MethodGet:
+			return s.store.Version(segments[1], segments[3])
+		case http.MethodDelete:
+			return s.store.DeleteVersion(segments[1], segments[3], permanent)
+		}
+		return nil, errMethodNotAllowed
+	case len(segments) == 5 && segments[0] == "subjects" && segments[2] == "versions" && segments[4] == "schema":
+		if r.Method != http.MethodGet {
+			return nil, errMethodNotAllowed
+		}
+		version, err := s.store.Version(segments[1], segments[3])
+		if err != nil {
+			return nil, err
+		}
+		return rawSchema(version.Schema), nil
+	case len(segments) == 5 && segments[0] == "subjects" && segments[2] == "versions" && segments[4] == "referencedby":
+		if r.Method != http.MethodGet {
+			return nil, errMethodNotAllowed
+		}
+		return s.store.ReferencedBy(segments[1], segments[3])
+	case len(segments) >= 3 && segments[0] == "schemas" && segments[1] == "ids":
+		if r.Method != http.MethodGet {
+			return nil, errMethodNotAllowed
+		}
+		id, err := strconv.Atoi(segments[2])
+		if err != nil {
+			return nil, errSchemaNotFound()
+		}
+		if len(segments) == 3 {
+			schemaText, references, err := s.store.SchemaById(id)
+			if err != nil {
+				return nil, err
+			}
+			return schemaRequest{Schema: schemaText, References: references}, nil
+		} else if len(segments) == 4 && segments[3] == "schema" {
+			schemaText, _, err := s.store.SchemaById(id)
+			return rawSchema(schemaText), err
+		} else if len(segments) == 4 && segments[3] == "versions" {
+			return s.store.SchemaVersions(id)
+		}
+		return nil, errNotFound
+	case len(segments) == 2 && segments[0] == "schemas" && segments[1] == "types":
+		return []string{"AVRO"}, nil
+	case len(segments) >= 3 && len(segments) <= 5 && segments[0] == "compatibility" && segments[1] == "subjects":
+		if r.Method != http.MethodPost || (len(segments) > 3 && segments[3] != "versions") {
+			return nil, errNotFound
+		}
+		request, err := readSchemaRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		version := ""
+		if len(segments) == 5 {
+			version = segments[4]
+		}
+		err = s.store.TestCompatibility(segments[2], version, request.Schema, request.References)
+		if registryErr, ok := err.(registryError); ok && registryErr.code != 409 {
+			return nil, err
+		}
+		return map[string]bool{"is_compatible": err == nil}, nil
+	case len(segments) <= 2 && segments[0] == "config":
+		subject := ""
+		if len(segments) == 2 {
+			subject = segments[1]
+		}
+		switch r.Method {
+		case http.MethodGet:
+			level, err := s.store.Compatibility(subject)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"compatibilityLevel": level}, nil
+		case http.MethodPut:
+			request := configRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				return nil, registryError{http.StatusBadRequest, 400, fmt.Sprintf("failed to parse request: %v", err)}
+			}
+			if err := s.store.SetCompatibility(subject, request.Compatibility); err != nil {
+				return nil, err
+			}
+			return request, nil
+		case http.MethodDelete:
+			if subject == "" {
+				return nil, errMethodNotAllowed
+			}
+			level, err := s.store.Compatibility(subject)
+			if err != nil {
+				return nil, err
+			}
+			return configRequest{Compatibility: level}, s.store.SetCompatibility(subject, "")
+		}
+		return nil, errMethodNotAllowed
+	}
+	return nil, errNotFound
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"avroparser/pkg/schema"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	CompatibilityNone               = "NONE"
+	CompatibilityBackward           = "BACKWARD"
+	CompatibilityBackwardTransitive = "BACKWARD_TRANSITIVE"
+	CompatibilityForward            = "FORWARD"
+	CompatibilityForwardTransitive  = "FORWARD_TRANSITIVE"
+	CompatibilityFull               = "FULL"
+	CompatibilityFullTransitive     = "FULL_TRANSITIVE"
+)
+
+func isCompatibilityLevel(level string) bool {
+	switch level {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityBackwardTransitive, CompatibilityForward,
+		CompatibilityForwardTransitive, CompatibilityFull, CompatibilityFullTransitive:
+		return true
+	}
+	return false
+}
+
+type registryError struct {
+	status  int
+	code    int
+	message string
+}
+
+func (e registryError) Error() string {
+	return e.message
+}
+
+func errSubjectNotFound(subject string) error {
+	return registryError{http.StatusNotFound, 40401, fmt.Sprintf("Subject '%s' not found.", subject)}
+}
+
+func errVersionNotFound(version string) error {
+	return registryError{http.StatusNotFound, 40402, fmt.Sprintf("Version %s not found.", version)}
+}
+
+func errSchemaNotFound() error {
+	return registryError{http.StatusNotFound, 40403, "Schema not found"}
+}
+
+func errInvalidSchema(err error) error {
+	return registryError{http.StatusUnprocessableEntity, 42201, fmt.Sprintf("Invalid schema: %v", err)}
+}
+
+func errInvalidVersion(version string) error {
+	return registryError{http.StatusUnprocessableEntity, 42202, fmt.Sprintf("The specified version '%s' is not a valid version id.", version)}
+}
+
+func errInvalidCompatibility(level string) error {
+	return registryError{http.StatusUnprocessableEntity, 42203, fmt.Sprintf("Invalid compatibility level %s", level)}
+}
+
+func errReferenceExists(subject string, version int) error {
+	return registryError{http.StatusUnprocessableEntity, 42206, fmt.Sprintf("One or more references exist to the schema {subject=%s,version=%d}.", subject, version)}
+}
+
+func errIncompatible(err error) error {
+	return registryError{http.StatusConflict, 409, fmt.Sprintf("Schema being registered is incompatible with an earlier schema: %v", err)}
+}
+
+type subjectVersion struct {
+	Version int  `json:"version"`
+	Id      int  `json:"id"`
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+type subjectState struct {
+	Versions      []subjectVersion `json:"versions"`
+	Compatibility string           `json:"compatibility,omitempty"`
+}
+
+func (s *subjectState) active() []subjectVersion {
+	result := make([]subjectVersion, 0, len(s.Versions))
+	for _, v := range s.Versions {
+		if !v.Deleted {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// schemaReference is named type of the schema defined in schema of the subject version
+type schemaReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type registryConfig struct {
+	Compatibility string `json:"compatibilityLevel"`
+}
+
+// store keeps schemas as <id>.avsc files in the directory, so that the directory can be
+// used as offline registry, references of schemas are kept in <id>.references.json files
+// and subjects are kept in subjects directory
+type store struct {
+	lock       sync.Mutex
+	directory  string
+	config     registryConfig
+	schemas    map[int]string
+	references map[int][]schemaReference
+	parsed     map[int]schema.ItemSchema
+	ids        map[string]int
+	subjects   map[string]*subjectState
+	nextId     int
+}
+
+func openStore(directory string, compatibility string) (*store, error) {
+	s := &store{
+		directory:  directory,
+		config:     registryConfig{Compatibility: compatibility},
+		schemas:    make(map[int]string),
+		references: make(map[int][]schemaReference),
+		parsed:     make(map[int]schema.ItemSchema),
+		ids:        make(map[string]int),
+		subjects:   make(map[string]*subjectState),
+		nextId:     1,
+	}
+	if err := os.MkdirAll(filepath.Join(directory, "subjects"), 0755); err != nil {
+		return nil, err
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(directory, "config.json")); err == nil {
+		if err = json.Unmarshal(data, &s.config); err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	schemaFiles, err := filepath.Glob(filepath.Join(directory, "*.avsc"))
+	if err != nil {
+		return nil, err
+	}
+	for _, fileName := range schemaFiles {
+		id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(fileName), ".avsc"))
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		normalized, jsonSchema, err := normalizeSchema(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load schema from %s: %w", fileName, err)
+		}
+		var references []schemaReference
+		if data, err = ioutil.ReadFile(s.referencesPath(id)); err == nil {
+			if err = json.Unmarshal(data, &references); err != nil {
+				return nil, fmt.Errorf("failed to read references of schema %d: %w", id, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		// schemas with references are parsed when they are used, as referenced schemas may
+		// be loaded after them
+		var parsed schema.ItemSchema
+		if len(references) == 0 {
+			if parsed, err = schema.ParseSchema(jsonSchema); err != nil {
+				return nil, fmt.Errorf("failed to load schema from %s: %w", fileName, err)
+			}
+		}
+		s.addSchema(id, normalized, references, parsed)
+	}
+	subjectFiles, err := filepath.Glob(filepath.Join(directory, "subjects", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, fileName := range subjectFiles {
+		subject, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(fileName), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid subject file name %s: %w", fileName, err)
+		}
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		state := &subjectState{}
+		if err = json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("failed to read subject from %s: %w", fileName, err)
+		}
+		s.subjects[subject] = state
+	}
+	return s, nil
+}
+
+// normalizeSchema returns schema in compact json form used to find same schemas along with
+// its json value
+func normalizeSchema(schemaText string) (string, interface{}, error) {
+	var jsonSchema interface{}
+	if err := json.Unmarshal([]byte(schemaText), &jsonSchema); err != nil {
+		return "", nil, err
+	}
+	normalized, err := json.Marshal(jsonSchema)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(normalized), jsonSchema, nil
+}
+
+// schemaKey identifies schema by its normalized form and references, as the same schema
+// with other references is another schema
+func schemaKey(normalized string, references []schemaReference) string {
+	if len(references) == 0 {
+		return normalized
+	}
+	data, _ := json.Marshal(references)
+	return normalized + string(data)
+}
+
+func (s *store) referencesPath(id int) string {
+	return filepath.Join(s.directory, fmt.Sprintf("%d.references.json", id))
+}
+
+// addSchema adds schema, parsed schema may be nil if schema has references
+func (s *store) addSchema(id int, normalized string, references []schemaReference, parsed schema.ItemSchema) {
+	s.schemas[id] = normalized
+	if len(references) > 0 {
+		s.references[id] = references
+	}
+	if parsed != nil {
+		s.parsed[id] = parsed
+	}
+	s.ids[schemaKey(normalized, references)] = id
+	if id >= s.nextId {
+		s.nextId = id + 1
+	}
+}
+
+// parseSchema parses schema after all schemas it references, so named types from them are known
+func (s *store) parseSchema(jsonSchema interface{}, references []schemaReference) (schema.ItemSchema, error) {
+	parser := schema.NewParser()
+	if err := s.parseReferences(parser, references, make(map[int]bool)); err != nil {
+		return nil, err
+	}
+	roots, err := parser.Parse(jsonSchema)
+	if err != nil {
+		return nil, err
+	}
+	return roots[0], nil
+}
+
+func (s *store) parseReferences(parser *schema.Parser, references []schemaReference, parsed map[int]bool) error {
+	for _, reference := range references {
+		id, err := s.referencedId(reference)
+		if err != nil {
+			return fmt.Errorf("failed to resolve reference %s: %w", reference.Name, err)
+		}
+		if parsed[id] {
+			continue
+		}
+		parsed[id] = true
+		if err = s.parseReferences(parser, s.references[id], parsed); err != nil {
+			return err
+		}
+		if _, err = parser.ParseJSON([]byte(s.schemas[id])); err != nil {
+			return fmt.Errorf("failed to parse referenced schema %s: %w", reference.Name, err)
+		}
+	}
+	return nil
+}
+
+// referencedId returns schema id of referenced subject version, soft deleted versions are
+// found too as schemas can't be changed after registration
+func (s *store) referencedId(reference schemaReference) (int, error) {
+	if state, found := s.subjects[reference.Subject]; found {
+		for _, v := range state.Versions {
+			if v.Version == reference.Version {
+				return v.Id, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("version %d of subject %s not found", reference.Version, reference.Subject)
+}
+
+// parsedSchema returns parsed schema of the id, schemas with references are parsed on first use
+func (s *store) parsedSchema(id int) (schema.ItemSchema, error) {
+	if parsed, found := s.parsed[id]; found {
+		return parsed, nil
+	}
+	var jsonSchema interface{}
+	if err := json.Unmarshal([]byte(s.schemas[id]), &jsonSchema); err != nil {
+		return nil, err
+	}
+	parsed, err := s.parseSchema(jsonSchema, s.references[id])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %d: %w", id, err)
+	}
+	s.parsed[id] = parsed
+	return parsed, nil
+}
+
+// referencedBy returns ids of schemas of subject versions that reference the version
+func (s *store) referencedBy(subject string, version int) []int {
+	used := make(map[int]bool)
+	for _, state := range s.subjects {
+		for _, v := range state.Versions {
+			used[v.Id] = true
+		}
+	}
+	result := make([]int, 0)
+	for id, references := range s.references {
+		for _, reference := range references {
+			if used[id] && reference.Subject == subject && reference.Version == version {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+func writeFileAtomic(fileName string, data []byte) error {
+	tmp := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
+
+func (s *store) saveSubject(subject string) error {
+	fileName := filepath.Join(s.directory, "subjects", url.PathEscape(subject)+".json")
+	state, found := s.subjects[subject]
+	if !found {
+		return os.Remove(fileName)
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, data)
+}
+
+func (s *store) saveConfig() error {
+	data, err := json.Marshal(s.config)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.directory, "config.json"), data)
+}
+
+func (s *store) Subjects() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]string, 0, len(s.subjects))
+	for subject, state := range s.subjects {
+		if len(state.active()) > 0 {
+			result = append(result, subject)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (s *store) subject(subject string) (*subjectState, error) {
+	state, found := s.subjects[subject]
+	if !found || len(state.active()) == 0 {
+		return nil, errSubjectNotFound(subject)
+	}
+	return state, nil
+}
+
+func (s *store) Versions(subject string) ([]int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, err := s.subject(subject)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int, 0)
+	for _, v := range state.active() {
+		result = append(result, v.Version)
+	}
+	return result, nil
+}
+
+func (s *store) version(subject, version string) (subjectVersion, error) {
+	state, err := s.subject(subject)
+	if err != nil {
+		return subjectVersion{}, err
+	}
+	active := state.active()
+	if version == "latest" || version == "-1" {
+		return active[len(active)-1], nil
+	}
+	number, err := strconv.Atoi(version)
+	if err != nil || number <= 0 {
+		return subjectVersion{}, errInvalidVersion(version)
+	}
+	for _, v := range active {
+		if v.Version == number {
+			return v, nil
+		}
+	}
+	return subjectVersion{}, errVersionNotFound(version)
+}
+
+type schemaVersion struct {
+	Subject    string            `json:"subject"`
+	Version    int               `json:"version"`
+	Id         int               `json:"id"`
+	Schema     string            `json:"schema"`
+	References []schemaReference `json:"references,omitempty"`
+}
+
+func (s *store) Version(subject, version string) (schemaVersion, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, err := s.version(subject, version)
+	if err != nil {
+		return schemaVersion{}, err
+	}
+	return schemaVersion{Subject: subject, Version: v.Version, Id: v.Id, Schema: s.schemas[v.Id], References: s.references[v.Id]}, nil
+}
+
+// ReferencedBy returns ids of schemas that reference the version of the subject
+func (s *store) ReferencedBy(subject, version string) ([]int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, err := s.version(subject, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.referencedBy(subject, v.Version), nil
+}
+
+func (s *store) SchemaById(id int) (string, []schemaReference, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if schemaText, found := s.schemas[id]; found {
+		return schemaText, s.references[id], nil
+	}
+	return "", nil, errSchemaNotFound()
+}
+
+type subjectVersionRef struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+func (s *store) SchemaVersions(id int) ([]subjectVersionRef, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, found := s.schemas[id]; !found {
+		return nil, errSchemaNotFound()
+	}
+	result := make([]subjectVersionRef, 0)
+	for subject, state := range s.subjects {
+		for _, v := range state.active() {
+			if v.Id == id {
+				result = append(result, subjectVersionRef{Subject: subject, Version: v.Version})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Subject < result[j].Subject })
+	return result, nil
+}
+
+// Lookup finds version of the subject with the same schema and references
+func (s *store) Lookup(subject, schemaText string, references []schemaReference) (schemaVersion, error) {
+	normalized, _, err := normalizeSchema(schemaText)
+	if err != nil {
+		return schemaVersion{}, errInvalidSchema(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, err := s.subject(subject)
+	if err != nil {
+		return schemaVersion{}, err
+	}
+	if id, found := s.ids[schemaKey(normalized, references)]; found {
+		for _, v := range state.active() {
+			if v.Id == id {
+				return schemaVersion{Subject: subject, Version: v.Version, Id: id, Schema: normalized, References: s.references[id]}, nil
+			}
+		}
+	}
+	return schemaVersion{}, errSchemaNotFound()
+}
+
+func (s *store) compatibilityLevel(subject string) string {
+	if state, found := s.subjects[subject]; found && state.Compatibility != "" {
+		return state.Compatibility
+	}
+	return s.config.Compatibility
+}
+
+// checkCompatibility checks new schema against versions of the subject according to compatibility level
+func (s *store) checkCompatibility(subject string, newSchema schema.ItemSchema, versions []subjectVersion) error {
+	level := s.compatibilityLevel(subject)
+	if level == CompatibilityNone || len(versions) == 0 {
+		return nil
+	}
+	if !strings.HasSuffix(level, "_TRANSITIVE") {
+		versions = versions[len(versions)-1:]
+	}
+	for _, v := range versions {
+		existing, err := s.parsedSchema(v.Id)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(level, CompatibilityBackward) || strings.HasPrefix(level, CompatibilityFull) {
+			if err := schema.CheckCompatibility(newSchema, existing); err != nil {
+				return fmt.Errorf("new schema can't read data written with version %d: %w", v.Version, err)
+			}
+		}
+		if strings.HasPrefix(level, CompatibilityForward) || strings.HasPrefix(level, CompatibilityFull) {
+			if err := schema.CheckCompatibility(existing, newSchema); err != nil {
+				return fmt.Errorf("version %d can't read data written with new schema: %w", v.Version, err)
+			}
+		}
+	}
+	return nil
+}
+
+// TestCompatibility checks schema against the version of the subject, version "" means all
+// versions relevant for compatibility level of the subject
+func (s *store) TestCompatibility(subject, version, schemaText string, references []schemaReference) error {
+	_, jsonSchema, err := normalizeSchema(schemaText)
+	if err != nil {
+		return errInvalidSchema(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	parsed, err := s.parseSchema(jsonSchema, references)
+	if err != nil {
+		return errInvalidSchema(err)
+	}
+	if version == "" {
+		if state, found := s.subjects[subject]; found {
+			return s.checkCompatibility(subject, parsed, state.active())
+		}
+		return nil
+	}
+	v, err := s.version(subject, version)
+	if err != nil {
+		return err
+	}
+	return s.checkCompatibility(subject, parsed, []subjectVersion{v})
+}
+
+// Register adds schema as new version of the subject and returns its id. Id of existing
+// version is returned if the subject already has the same schema and references.
+func (s *store) Register(subject, schemaText string, references []schemaReference) (int, error) {
+	normalized, jsonSchema, err := normalizeSchema(schemaText)
+	if err != nil {
+		return 0, errInvalidSchema(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	parsed, err := s.parseSchema(jsonSchema, references)
+	if err != nil {
+		return 0, errInvalidSchema(err)
+	}
+	state, found := s.subjects[subject]
+	if !found {
+		state = &subjectState{Versions: make([]subjectVersion, 0)}
+	}
+	id, known := s.ids[schemaKey(normalized, references)]
+	if known {
+		for _, v := range state.active() {
+			if v.Id == id {
+				return id, nil
+			}
+		}
+	}
+	if err = s.checkCompatibility(subject, parsed, state.active()); err != nil {
+		return 0, errIncompatible(err)
+	}
+	if !known {
+		id = s.nextId
+		// references are written first, so that schema file is never loaded without them
+		if len(references) > 0 {
+			data, err := json.Marshal(references)
+			if err != nil {
+				return 0, err
+			}
+			if err = writeFileAtomic(s.referencesPath(id), data); err != nil {
+				return 0, err
+			}
+		}
+		if err = writeFileAtomic(filepath.Join(s.directory, fmt.Sprintf("%d.avsc", id)), []byte(normalized)); err != nil {
+			return 0, err
+		}
+		s.addSchema(id, normalized, references, parsed)
+	}
+	version := 1
+	if len(state.Versions) > 0 {
+		version = state.Versions[len(state.Versions)-1].Version + 1
+	}
+	state.Versions = append(state.Versions, subjectVersion{Version: version, Id: id})
+	s.subjects[subject] = state
+	return id, s.saveSubject(subject)
+}
+
+func (s *store) DeleteSubject(subject string, permanent bool) ([]int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, found := s.subjects[subject]
+	if !found {
+		return nil, errSubjectNotFound(subject)
+	}
+	for _, v := range state.Versions {
+		if permanent || !v.Deleted {
+			if len(s.referencedBy(subject, v.Version)) > 0 {
+				return nil, errReferenceExists(subject, v.Version)
+			}
+		}
+	}
+	result := make([]int, 0)
+	for idx := range state.Versions {
+		if permanent || !state.Versions[idx].Deleted {
+			result = append(result, state.Versions[idx].Version)
+		}
+		state.Versions[idx].Deleted = true
+	}
+	if permanent {
+		delete(s.subjects, subject)
+	} else if len(result) == 0 {
+		return nil, errSubjectNotFound(subject)
+	}
+	return result, s.saveSubject(subject)
+}
+
+func (s *store) DeleteVersion(subject, version string, permanent bool) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, err := s.version(subject, version)
+	if err != nil {
+		return 0, err
+	}
+	if len(s.referencedBy(subject, v.Version)) > 0 {
+		return 0, errReferenceExists(subject, v.Version)
+	}
+	state := s.subjects[subject]
+	for idx := range state.Versions {
+		if state.Versions[idx].Version == v.Version {
+			if permanent {
+				state.Versions = append(state.Versions[:idx], state.Versions[idx+1:]...)
+			} else {
+				state.Versions[idx].Deleted = true
+			}
+			break
+		}
+	}
+	return v.Version, s.saveSubject(subject)
+}
+
+// Compatibility returns compatibility level of the subject or global one if subject is empty
+func (s *store) Compatibility(subject string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if subject == "" {
+		return s.config.Compatibility, nil
+	}
+	if state, found := s.subjects[subject]; found && state.Compatibility != "" {
+		return state.Compatibility, nil
+	}
+	return "", errSubjectNotFound(subject)
+}
+
+// SetCompatibility sets compatibility level of the subject or global one if subject is empty,
+// empty level removes subject level
+func (s *store) SetCompatibility(subject, level string) error {
+	if level != "" && !isCompatibilityLevel(level) {
+		return errInvalidCompatibility(level)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if subject == "" {
+		if level == "" {
+			return errInvalidCompatibility(level)
+		}
+		s.config.Compatibility = level
+		return s.saveConfig()
+	}
+	state, found := s.subjects[subject]
+	if !found {
+		if level == "" {
+			return errSubjectNotFound(subject)
+		}
+		state = &subjectState{Versions: make([]subjectVersion, 0)}
+		s.subjects[subject] = state
+	}
+	state.Compatibility = level
+	return s.saveSubject(subject)
+}
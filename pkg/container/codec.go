@@ -0,0 +1,115 @@
+package container
+
+import (
+	"avroparser/pkg/snappy"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+type Codec interface {
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var codecs = map[string]Codec{
+	"null":    nullCodec{},
+	"deflate": deflateCodec{},
+	"snappy":  snappyCodec{},
+}
+
+func CodecByName(name string) (Codec, error) {
+	if name == "" {
+		return nullCodec{}, nil
+	}
+	if codec, found := codecs[name]; found {
+		return codec, nil
+	}
+	return nil, fmt.Errorf("codec %s is not supported", name)
+}
+
+///////////////////////
+
+type nullCodec struct {
+}
+
+func (c nullCodec) Name() string {
+	return "null"
+}
+
+func (c nullCodec) Compress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (c nullCodec) Decompress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+///////////////////////
+
+type deflateCodec struct {
+}
+
+func (c deflateCodec) Name() string {
+	return "deflate"
+}
+
+func (c deflateCodec) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	w, err := flate.NewWriter(&buffer, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c deflateCodec) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	result, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inflate block: %w", err)
+	}
+	return result, nil
+}
+
+///////////////////////
+
+// snappyCodec compresses each block separately and appends big-endian crc32 of uncompressed data
+type snappyCodec struct {
+}
+
+func (c snappyCodec) Name() string {
+	return "snappy"
+}
+
+func (c snappyCodec) Compress(data []byte) ([]byte, error) {
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(data))
+	return append(snappy.Encode(data), checksum...), nil
+}
+
+func (c snappyCodec) Decompress(data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("snappy block is too short (%d bytes) to contain checksum", len(data))
+	}
+	result, err := snappy.Decode(data[:len(data)-4])
+	if err != nil {
+		return nil, err
+	}
+	expected := binary.BigEndian.Uint32(data[len(data)-4:])
+	if actual := crc32.ChecksumIEEE(result); actual != expected {
+		return nil, fmt.Errorf("snappy block checksum mismatch: expected %08x, actual %08x", expected, actual)
+	}
+	return result, nil
+}
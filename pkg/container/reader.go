@@ -0,0 +1,222 @@
+package container
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	SyncSize = 16
+	// MaxBlockSize is the largest size of block data, as in other avro implementations
+	MaxBlockSize = math.MaxInt32
+	// MaxBlockRecords is the largest number of records in a block of schema whose values
+	// may take no bytes, count of other blocks is limited by size of their data
+	MaxBlockRecords = 1 << 24
+
+	preallocatedBlockSize = 1 << 20
+
+	MetaSchema = "avro.schema"
+	MetaCodec  = "avro.codec"
+)
+
+var magic = []byte{'O', 'b', 'j', 1}
+
+var metaSchema, _ = schema.ParseSchemaJSON([]byte(`{"type": "map", "values": "bytes"}`))
+
+type Header struct {
+	Meta   map[string][]byte
+	Sync   [SyncSize]byte
+	Schema schema.ItemSchema
+	Codec  Codec
+}
+
+type Block struct {
+	Count int64
+	// Data is kept compressed with the codec of the file
+	Data []byte
+	Sync [SyncSize]byte
+}
+
+func ReadHeader(r io.Reader) (*Header, error) {
+	fileMagic := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, fileMagic); err != nil {
+		return nil, fmt.Errorf("failed to read magic: %w", err)
+	}
+	if !bytes.Equal(fileMagic, magic) {
+		return nil, fmt.Errorf("not an avro container file, magic is %v", fileMagic)
+	}
+	meta, err := metaSchema.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header metadata: %w", err)
+	}
+	header := Header{Meta: make(map[string][]byte)}
+	for key, value := range meta.(map[string]interface{}) {
+		header.Meta[key] = value.([]byte)
+	}
+	if _, err = io.ReadFull(r, header.Sync[:]); err != nil {
+		return nil, fmt.Errorf("failed to read sync marker: %w", err)
+	}
+	if schemaData, found := header.Meta[MetaSchema]; !found {
+		return nil, fmt.Errorf("schema is missing in header metadata")
+	} else if header.Schema, err = schema.ParseSchemaJSON(schemaData); err != nil {
+		return nil, fmt.Errorf("failed to parse schema from header: %w", err)
+	}
+	if header.Codec, err = CodecByName(string(header.Meta[MetaCodec])); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+func ReadBlock(r io.Reader) (*Block, error) {
+	block := Block{}
+	var err error
+	if block.Count, err = readLong(r); errors.Is(err, io.EOF) {
+		// clean end of file between blocks
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read block record count: %w", err)
+	}
+	size, err := readLong(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read block size: %w", err)
+	}
+	if block.Count < 0 || size < 0 {
+		return nil, fmt.Errorf("block has negative record count %d or size %d", block.Count, size)
+	}
+	if size > MaxBlockSize {
+		return nil, fmt.Errorf("block size %d exceeds maximum block size %d", size, MaxBlockSize)
+	}
+	if size > preallocatedBlockSize {
+		// size of corrupt block may be large, so memory is allocated as data is read
+		buffer := bytes.Buffer{}
+		if _, err = io.CopyN(&buffer, r, size); err == io.EOF {
+			return nil, fmt.Errorf("failed to read block data of size %d: %w", size, io.ErrUnexpectedEOF)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read block data of size %d: %w", size, err)
+		}
+		block.Data = buffer.Bytes()
+	} else {
+		block.Data = make([]byte, size)
+		if _, err = io.ReadFull(r, block.Data); err != nil {
+			return nil, fmt.Errorf("failed to read block data of size %d: %w", size, err)
+		}
+	}
+	if _, err = io.ReadFull(r, block.Sync[:]); err != nil {
+		return nil, fmt.Errorf("failed to read block sync marker: %w", err)
+	}
+	return &block, nil
+}
+
+// checkCount checks that decompressed data of the block can hold its records, minSize is
+// the smallest size of record of the file schema
+func checkCount(count int64, data []byte, minSize int) error {
+	if minSize > 0 && count > int64(len(data)/minSize) {
+		return fmt.Errorf("block record count %d exceeds %d records that fit into %d bytes of block data", count, len(data)/minSize, len(data))
+	} else if minSize == 0 && count > MaxBlockRecords {
+		return fmt.Errorf("block record count %d exceeds maximum record count %d", count, MaxBlockRecords)
+	}
+	return nil
+}
+
+func readLong(r io.Reader) (int64, error) {
+	value, err := schema.AvroLong{}.Read(r)
+	if err != nil {
+		return 0, err
+	}
+	return value.(int64), nil
+}
+
+// RecordError is returned by Reader when records of a block can't be read. Records of a
+// block aren't delimited, so the failed record and the rest of the block are skipped and
+// reading continues with the next block, unless framing of the block is broken.
+type RecordError struct {
+	// Index is index of the first skipped record in the file
+	Index int64
+	// Count is number of skipped records
+	Count int64
+	// Offset is offset of the block in the file
+	Offset int64
+	// Data is undecoded data of the block starting with the failed record
+	Data []byte
+	// Framing is set if the block itself can't be read, position of the next block is
+	// unknown then and the file can't be read further
+	Framing bool
+	Err     error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d of block at offset %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+///////////////////////
+
+type Reader struct {
+	r           *offsetReader
+	header      *Header
+	minSize     int
+	data        []byte
+	block       *bytes.Reader
+	blockOffset int64
+	remaining   int64
+	index       int64
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &offsetReader{r: r}
+	header, err := ReadHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: reader, header: header, minSize: schema.MinSize(header.Schema)}, nil
+}
+
+func (r *Reader) Header() *Header {
+	return r.header
+}
+
+// Next returns next datum from the file, io.EOF is returned when there are no more blocks.
+// RecordError is returned if the datum or its block can't be decoded.
+func (r *Reader) Next() (interface{}, error) {
+	for r.remaining == 0 {
+		r.blockOffset = r.r.offset
+		block, err := ReadBlock(r.r)
+		if err == io.EOF {
+			return nil, err
+		} else if err != nil {
+			return nil, &RecordError{Index: r.index, Offset: r.blockOffset, Framing: true, Err: err}
+		}
+		if block.Sync != r.header.Sync {
+			err = fmt.Errorf("block sync marker %x doesn't match header sync marker %x", block.Sync, r.header.Sync)
+			return nil, &RecordError{Index: r.index, Count: block.Count, Offset: r.blockOffset, Data: block.Data, Framing: true, Err: err}
+		}
+		data, err := r.header.Codec.Decompress(block.Data)
+		if err == nil {
+			err = checkCount(block.Count, data, r.minSize)
+		}
+		if err != nil {
+			r.index += block.Count
+			return nil, &RecordError{Index: r.index - block.Count, Count: block.Count, Offset: r.blockOffset, Data: block.Data, Err: err}
+		}
+		r.data, r.block = data, bytes.NewReader(data)
+		r.remaining = block.Count
+	}
+	start := len(r.data) - r.block.Len()
+	value, err := r.header.Schema.Read(r.block)
+	if err != nil {
+		err = &RecordError{Index: r.index, Count: r.remaining, Offset: r.blockOffset, Data: r.data[start:], Err: err}
+		r.index += r.remaining
+		r.remaining = 0
+		return nil, err
+	}
+	r.index++
+	r.remaining--
+	return value, nil
+}
@@ -0,0 +1,93 @@
+package container
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// writeContainer writes values into container file without compression
+func writeContainer(t *testing.T, schemaData string, values ...interface{}) []byte {
+	t.Helper()
+	buffer := bytes.Buffer{}
+	writer, err := NewWriter(&buffer, []byte(schemaData), nullCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range values {
+		if err = writer.Append(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// setBlockCount replaces record count of the first block, the count is expected to be
+// a single byte varint
+func setBlockCount(t *testing.T, data []byte, count int64) []byte {
+	t.Helper()
+	sync := data[len(data)-SyncSize:]
+	start := bytes.Index(data, sync) + SyncSize
+	varint := bytes.Buffer{}
+	if err := (schema.AvroLong{}).Write(&varint, count); err != nil {
+		t.Fatal(err)
+	}
+	result := append([]byte{}, data[:start]...)
+	result = append(result, varint.Bytes()...)
+	return append(result, data[start+1:]...)
+}
+
+func TestBlockCountIsLimited(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		values []interface{}
+		count  int64
+	}{
+		{"empty records", `{"type": "record", "name": "E", "fields": []}`, []interface{}{map[string]interface{}{}, map[string]interface{}{}}, 1 << 62},
+		{"longs", `"long"`, []interface{}{int64(1), int64(2)}, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := setBlockCount(t, writeContainer(t, test.schema, test.values...), test.count)
+
+			report := Verify(bytes.NewReader(data))
+			if len(report.Problems) != 1 || report.Problems[0].Block != 0 || report.Records != 0 {
+				t.Fatalf("expected problem with block 0, got %+v", report)
+			}
+
+			reader, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var recordErr *RecordError
+			if _, err = reader.Next(); !errors.As(err, &recordErr) || recordErr.Framing || recordErr.Count != test.count {
+				t.Fatalf("expected record error for %d records, got %v", test.count, err)
+			}
+			if _, err = reader.Next(); err != io.EOF {
+				t.Fatalf("expected end of file after skipped block, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadRecords(t *testing.T) {
+	data := writeContainer(t, `"long"`, int64(1), int64(2))
+	if report := Verify(bytes.NewReader(data)); !report.Ok() || report.Records != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	reader, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []int64{1, 2} {
+		if value, err := reader.Next(); err != nil || value != expected {
+			t.Fatalf("expected %d, got %v, %v", expected, value, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package container
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+type VerifyProblem struct {
+	// Block is the index of the block with the problem, -1 for header problems
+	Block   int
+	Offset  int64
+	Message string
+}
+
+func (p VerifyProblem) String() string {
+	if p.Block < 0 {
+		return fmt.Sprintf("header at offset %d: %s", p.Offset, p.Message)
+	}
+	return fmt.Sprintf("block %d at offset %d: %s", p.Block, p.Offset, p.Message)
+}
+
+type VerifyReport struct {
+	Blocks   int
+	Records  int64
+	Problems []VerifyProblem
+}
+
+func (r *VerifyReport) Ok() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) addProblem(block int, offset int64, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, VerifyProblem{Block: block, Offset: offset, Message: fmt.Sprintf(format, args...)})
+}
+
+type offsetReader struct {
+	r      io.Reader
+	offset int64
+}
+
+func (o *offsetReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+// Verify walks the whole container file and checks header, framing of every block,
+// codec checksums and that declared number of records is decoded from each block using
+// exactly the declared number of bytes. Framing problems stop verification, problems with
+// block contents are reported and verification continues with the next block.
+func Verify(r io.Reader) *VerifyReport {
+	report := &VerifyReport{}
+	reader := &offsetReader{r: r}
+	header, err := ReadHeader(reader)
+	if err != nil {
+		report.addProblem(-1, reader.offset, "%v", err)
+		return report
+	}
+	minSize := schema.MinSize(header.Schema)
+	for blockIdx := 0; ; blockIdx++ {
+		offset := reader.offset
+		block, err := ReadBlock(reader)
+		if err == io.EOF {
+			return report
+		} else if err != nil {
+			report.addProblem(blockIdx, offset, "%v", err)
+			return report
+		}
+		report.Blocks++
+		if block.Sync != header.Sync {
+			report.addProblem(blockIdx, offset, "sync marker %x doesn't match header sync marker %x", block.Sync, header.Sync)
+			return report
+		}
+		data, err := header.Codec.Decompress(block.Data)
+		if err != nil {
+			report.addProblem(blockIdx, offset, "failed to decompress with codec %s: %v", header.Codec.Name(), err)
+			continue
+		}
+		if err = checkCount(block.Count, data, minSize); err != nil {
+			report.addProblem(blockIdx, offset, "%v", err)
+			continue
+		}
+		contents := bytes.NewReader(data)
+		for idx := int64(0); idx < block.Count; idx++ {
+			position := int64(len(data)) - int64(contents.Len())
+			if _, err = header.Schema.Read(contents); err != nil {
+				report.addProblem(blockIdx, offset, "record %d of %d at block byte %d is not decodable: %v", idx, block.Count, position, err)
+				break
+			}
+			report.Records++
+		}
+		if err == nil && contents.Len() != 0 {
+			report.addProblem(blockIdx, offset, "%d bytes left in block after decoding %d records", contents.Len(), block.Count)
+		}
+	}
+}
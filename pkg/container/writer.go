@@ -0,0 +1,126 @@
+package container
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+const DefaultBlockSize = 64 * 1024
+
+type Writer struct {
+	w      io.Writer
+	header *Header
+	buffer bytes.Buffer
+	count  int64
+	// BlockSize is the size of uncompressed data after which block is flushed
+	BlockSize int
+}
+
+func NewWriter(w io.Writer, schemaData []byte, codec Codec) (*Writer, error) {
+	parsedSchema, err := schema.ParseSchemaJSON(schemaData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %w", err)
+	}
+	header := Header{
+		Meta: map[string][]byte{
+			MetaSchema: schemaData,
+			MetaCodec:  []byte(codec.Name()),
+		},
+		Schema: parsedSchema,
+		Codec:  codec,
+	}
+	if _, err = rand.Read(header.Sync[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate sync marker: %w", err)
+	}
+	if err = writeHeader(w, &header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, header: &header, BlockSize: DefaultBlockSize}, nil
+}
+
+// NewAppendWriter reads header of existing container file and positions writer at the end
+// of it, so that new blocks are written with the codec and sync marker of the file.
+// Data written with dataSchema should be readable with the schema of the file.
+func NewAppendWriter(f io.ReadWriteSeeker, dataSchema schema.ItemSchema) (*Writer, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header, err := ReadHeader(f)
+	if err != nil {
+		return nil, err
+	}
+	if dataSchema != nil {
+		if err = schema.CheckCompatibility(header.Schema, dataSchema); err != nil {
+			return nil, fmt.Errorf("schema is incompatible with schema of container file: %w", err)
+		}
+	}
+	if _, err = f.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	return &Writer{w: f, header: header, BlockSize: DefaultBlockSize}, nil
+}
+
+func writeHeader(w io.Writer, header *Header) error {
+	meta := make(map[string]interface{})
+	for key, value := range header.Meta {
+		meta[key] = value
+	}
+	var buffer bytes.Buffer
+	buffer.Write(magic)
+	if err := metaSchema.Write(&buffer, meta); err != nil {
+		return fmt.Errorf("failed to write header metadata: %w", err)
+	}
+	buffer.Write(header.Sync[:])
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+func (w *Writer) Header() *Header {
+	return w.header
+}
+
+func (w *Writer) Append(value interface{}) error {
+	size := w.buffer.Len()
+	if err := w.header.Schema.Write(&w.buffer, value); err != nil {
+		w.buffer.Truncate(size)
+		return err
+	}
+	w.count++
+	if w.buffer.Len() >= w.BlockSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+func (w *Writer) Flush() error {
+	if w.count == 0 {
+		return nil
+	}
+	data, err := w.header.Codec.Compress(w.buffer.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to compress block: %w", err)
+	}
+	var block bytes.Buffer
+	if err = (schema.AvroLong{}).Write(&block, w.count); err != nil {
+		return err
+	}
+	if err = (schema.AvroLong{}).Write(&block, int64(len(data))); err != nil {
+		return err
+	}
+	block.Write(data)
+	block.Write(w.header.Sync[:])
+	if _, err = w.w.Write(block.Bytes()); err != nil {
+		return fmt.Errorf("failed to write block: %w", err)
+	}
+	w.buffer.Reset()
+	w.count = 0
+	return nil
+}
+
+// Close flushes pending records, underlying writer is not closed
+func (w *Writer) Close() error {
+	return w.Flush()
+}
@@ -0,0 +1,155 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a node of parsed expression
+type Expr interface {
+	String() string
+}
+
+// Literal is null, boolean, int64, float64 or string constant
+type Literal struct {
+	Value interface{}
+}
+
+func (l *Literal) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return "?"
+}
+
+// Segment is a field name or map key, or an index of array element
+type Segment struct {
+	Name    string
+	Index   int
+	IsIndex bool
+}
+
+// Path selects column of the scope with the first segment and parts of its value with
+// the rest
+type Path struct {
+	Segments []Segment
+}
+
+func (p *Path) String() string {
+	var result strings.Builder
+	for idx, segment := range p.Segments {
+		switch {
+		case segment.IsIndex:
+			result.WriteString("[" + strconv.Itoa(segment.Index) + "]")
+		case !isPlainName(segment.Name):
+			if idx > 0 {
+				result.WriteString(".")
+			}
+			result.WriteString("`" + strings.ReplaceAll(segment.Name, "`", "``") + "`")
+		default:
+			if idx > 0 {
+				result.WriteString(".")
+			}
+			result.WriteString(segment.Name)
+		}
+	}
+	return result.String()
+}
+
+// Unary is "not" or "-" applied to the operand
+type Unary struct {
+	Op string
+	X  Expr
+}
+
+func (u *Unary) String() string {
+	if u.Op == "not" {
+		return "not " + u.X.String()
+	}
+	return u.Op + u.X.String()
+}
+
+// Binary is a logical, comparison, arithmetic or match operator, operators are in lower
+// case and "==" and "<>" are read as "=" and "!="
+type Binary struct {
+	Op          string
+	Left, Right Expr
+}
+
+func (b *Binary) String() string {
+	return "(" + b.Left.String() + " " + b.Op + " " + b.Right.String() + ")"
+}
+
+type In struct {
+	X    Expr
+	List []Expr
+	Not  bool
+}
+
+func (i *In) String() string {
+	items := make([]string, len(i.List))
+	for idx, item := range i.List {
+		items[idx] = item.String()
+	}
+	op := " in ("
+	if i.Not {
+		op = " not in ("
+	}
+	return i.X.String() + op + strings.Join(items, ", ") + ")"
+}
+
+type IsNull struct {
+	X   Expr
+	Not bool
+}
+
+func (i *IsNull) String() string {
+	if i.Not {
+		return i.X.String() + " is not null"
+	}
+	return i.X.String() + " is null"
+}
+
+// Call is a function call, Star is set for count(*) and Distinct for count(distinct x)
+type Call struct {
+	Name     string
+	Args     []Expr
+	Star     bool
+	Distinct bool
+}
+
+func (c *Call) String() string {
+	if c.Star {
+		return c.Name + "(*)"
+	}
+	args := make([]string, len(c.Args))
+	for idx, arg := range c.Args {
+		args[idx] = arg.String()
+	}
+	if c.Distinct {
+		return c.Name + "(distinct " + strings.Join(args, ", ") + ")"
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func isPlainName(name string) bool {
+	if name == "" || keywords[strings.ToLower(name)] {
+		return false
+	}
+	for idx, r := range name {
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && (idx == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
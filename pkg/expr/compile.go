@@ -0,0 +1,755 @@
+package expr
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Column is a named value available to expressions, Schema is set for columns of
+// decoded avro values and Type is derived from it
+type Column struct {
+	Name   string
+	Type   Type
+	Schema schema.ItemSchema
+}
+
+func NewColumn(name string, s schema.ItemSchema) Column {
+	return Column{Name: name, Type: TypeOf(s), Schema: s}
+}
+
+// Scope is a set of columns, rows of the scope are maps of column names to values
+type Scope []Column
+
+// RecordScope returns scope of record fields for rows that are decoded records
+func RecordScope(s schema.ItemSchema) (Scope, error) {
+	record, ok := s.(schema.AvroRecord)
+	if !ok {
+		return nil, fmt.Errorf("expected record schema, got %s", schema.TypeName(s))
+	}
+	scope := make(Scope, len(record.Fields()))
+	for idx, f := range record.Fields() {
+		scope[idx] = NewColumn(f.Name(), f.Type())
+	}
+	return scope, nil
+}
+
+// Compiled is a type-checked expression
+type Compiled struct {
+	Type Type
+	// Schema is avro schema of paths to decoded avro values
+	Schema schema.ItemSchema
+	eval   func(row map[string]interface{}) interface{}
+	raw    func(row map[string]interface{}) interface{}
+	// constant is set for literals
+	constant bool
+}
+
+// Eval evaluates expression for the row, null is returned if value is unknown
+func (c *Compiled) Eval(row map[string]interface{}) interface{} {
+	return c.eval(row)
+}
+
+// Raw returns decoded avro value of a path with Schema, raw values are not converted to
+// values of expressions, so dates stay days and decimals stay bytes
+func (c *Compiled) Raw(row map[string]interface{}) interface{} {
+	return c.raw(row)
+}
+
+// Matches checks whether expression is true for the row, null is not true
+func (c *Compiled) Matches(row map[string]interface{}) bool {
+	result, ok := c.eval(row).(bool)
+	return ok && result
+}
+
+// Compile checks types of the expression in the scope
+func Compile(e Expr, scope Scope) (*Compiled, error) {
+	return (&compiler{scope: scope}).compile(e)
+}
+
+// CompileFilter compiles expression that should be boolean
+func CompileFilter(e Expr, scope Scope) (*Compiled, error) {
+	compiled, err := Compile(e, scope)
+	if err != nil {
+		return nil, err
+	}
+	if !compiled.Type.is(KindBool) {
+		return nil, fmt.Errorf("filter should be a condition, got %s of type %s", e, compiled.Type)
+	}
+	return compiled, nil
+}
+
+// is checks whether value of the type may be of the kind
+func (t Type) is(kinds ...Kind) bool {
+	if t.Kind == KindAny || t.Kind == KindNull {
+		return true
+	}
+	for _, kind := range kinds {
+		if t.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type compiler struct {
+	scope Scope
+}
+
+func (c *compiler) compile(e Expr) (*Compiled, error) {
+	switch n := e.(type) {
+	case *Literal:
+		return literal(n.Value), nil
+	case *Path:
+		return c.path(n)
+	case *Unary:
+		return c.unary(n)
+	case *Binary:
+		switch n.Op {
+		case "and", "or":
+			return c.logical(n)
+		case "=", "!=", "<", "<=", ">", ">=":
+			return c.comparison(n)
+		case "like", "~", "!~":
+			return c.match(n)
+		}
+		return c.arithmetic(n)
+	case *In:
+		return c.in(n)
+	case *IsNull:
+		x, err := c.compile(n.X)
+		if err != nil {
+			return nil, err
+		}
+		not := n.Not
+		return &Compiled{Type: Type{Kind: KindBool}, eval: func(row map[string]interface{}) interface{} {
+			return (x.eval(row) == nil) != not
+		}}, nil
+	case *Call:
+		return c.call(n)
+	}
+	return nil, fmt.Errorf("unsupported expression %s", e)
+}
+
+func literal(value interface{}) *Compiled {
+	t := Type{Kind: KindNull}
+	switch value.(type) {
+	case bool:
+		t.Kind = KindBool
+	case int64:
+		t.Kind = KindInt
+	case float64:
+		t.Kind = KindFloat
+	case string:
+		t.Kind = KindString
+	case time.Time:
+		t.Kind = KindTimestamp
+	}
+	return &Compiled{Type: t, constant: true, eval: func(map[string]interface{}) interface{} {
+		return value
+	}}
+}
+
+func (c *compiler) path(p *Path) (*Compiled, error) {
+	name := p.Segments[0].Name
+	var column *Column
+	for idx := range c.scope {
+		if c.scope[idx].Name == name {
+			column = &c.scope[idx]
+			break
+		}
+	}
+	if column == nil {
+		names := make([]string, len(c.scope))
+		for idx, col := range c.scope {
+			names[idx] = col.Name
+		}
+		return nil, fmt.Errorf("unknown field %s, fields are %s", name, strings.Join(names, ", "))
+	}
+
+	t, rawSchema := column.Type, column.Schema
+	var steps []func(value interface{}) interface{}
+	for idx, segment := range p.Segments[1:] {
+		prefix := &Path{Segments: p.Segments[:idx+1]}
+		step, child, err := selector(prefix, t, segment)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		t, rawSchema = TypeOf(child), child
+	}
+	raw := func(row map[string]interface{}) interface{} {
+		value := row[name]
+		for _, step := range steps {
+			if value == nil {
+				return nil
+			}
+			value = step(value)
+		}
+		return value
+	}
+	convert := converter(t)
+	return &Compiled{Type: t, Schema: rawSchema, raw: raw, eval: func(row map[string]interface{}) interface{} {
+		if value := raw(row); value != nil {
+			return convert(value)
+		}
+		return nil
+	}}, nil
+}
+
+// selector returns function selecting field, map value or array item of values of the type
+// and schema of selected values
+func selector(prefix *Path, t Type, segment Segment) (func(value interface{}) interface{}, schema.ItemSchema, error) {
+	switch t.Kind {
+	case KindRecord:
+		record := t.Schema.(schema.AvroRecord)
+		if segment.IsIndex {
+			return nil, nil, fmt.Errorf("%s is a record %s, its fields are selected by name", prefix, record.FullName())
+		}
+		f, found := record.Field(segment.Name)
+		if !found {
+			names := make([]string, len(record.Fields()))
+			for idx, field := range record.Fields() {
+				names[idx] = field.Name()
+			}
+			return nil, nil, fmt.Errorf("record %s of %s has no field %s, fields are %s", record.FullName(), prefix, segment.Name, strings.Join(names, ", "))
+		}
+		name := segment.Name
+		return func(value interface{}) interface{} {
+			if m, ok := value.(map[string]interface{}); ok {
+				return m[name]
+			}
+			return nil
+		}, f.Type(), nil
+	case KindMap:
+		if segment.IsIndex {
+			return nil, nil, fmt.Errorf("%s is a map, its values are selected by string keys", prefix)
+		}
+		key := segment.Name
+		return func(value interface{}) interface{} {
+			if m, ok := value.(map[string]interface{}); ok {
+				return m[key]
+			}
+			return nil
+		}, t.Schema.(schema.AvroMap).Values(), nil
+	case KindArray:
+		if !segment.IsIndex {
+			return nil, nil, fmt.Errorf("%s is an array, its items are selected by index", prefix)
+		}
+		index := segment.Index
+		return func(value interface{}) interface{} {
+			if items, ok := value.([]interface{}); ok && index < len(items) {
+				return items[index]
+			}
+			return nil
+		}, t.Schema.(schema.AvroArray).Items(), nil
+	case KindAny:
+		return func(value interface{}) interface{} {
+			switch v := schema.Untagged(value).(type) {
+			case map[string]interface{}:
+				if !segment.IsIndex {
+					return v[segment.Name]
+				}
+			case []interface{}:
+				if segment.IsIndex && segment.Index < len(v) {
+					return v[segment.Index]
+				}
+			}
+			return nil
+		}, nil, nil
+	}
+	return nil, nil, fmt.Errorf("%s of type %s has no fields or items", prefix, t)
+}
+
+func (c *compiler) unary(u *Unary) (*Compiled, error) {
+	x, err := c.compile(u.X)
+	if err != nil {
+		return nil, err
+	}
+	if u.Op == "not" {
+		if !x.Type.is(KindBool) {
+			return nil, fmt.Errorf("not expects a condition, got %s of type %s", u.X, x.Type)
+		}
+		return &Compiled{Type: Type{Kind: KindBool}, eval: func(row map[string]interface{}) interface{} {
+			if value, ok := x.eval(row).(bool); ok {
+				return !value
+			}
+			return nil
+		}}, nil
+	}
+	if !x.Type.is(KindInt, KindFloat) {
+		return nil, fmt.Errorf("minus expects a number, got %s of type %s", u.X, x.Type)
+	}
+	return &Compiled{Type: Type{Kind: x.Type.Kind}, eval: func(row map[string]interface{}) interface{} {
+		switch value := x.eval(row).(type) {
+		case int64:
+			return -value
+		case float64:
+			return -value
+		}
+		return nil
+	}}, nil
+}
+
+// logical evaluates and and or with three-valued logic: null and false is false,
+// null or true is true, other operations with null are null
+func (c *compiler) logical(b *Binary) (*Compiled, error) {
+	left, right, err := c.operands(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, operand := range []struct {
+		e Expr
+		t Type
+	}{{b.Left, left.Type}, {b.Right, right.Type}} {
+		if !operand.t.is(KindBool) {
+			return nil, fmt.Errorf("%s expects conditions, got %s of type %s", b.Op, operand.e, operand.t)
+		}
+	}
+	// decisive is the value of operand that decides the result
+	decisive := b.Op == "or"
+	return &Compiled{Type: Type{Kind: KindBool}, eval: func(row map[string]interface{}) interface{} {
+		l, lok := left.eval(row).(bool)
+		if lok && l == decisive {
+			return decisive
+		}
+		r, rok := right.eval(row).(bool)
+		if rok && r == decisive {
+			return decisive
+		}
+		if lok && rok {
+			return !decisive
+		}
+		return nil
+	}}, nil
+}
+
+func (c *compiler) operands(b *Binary) (*Compiled, *Compiled, error) {
+	left, err := c.compile(b.Left)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := c.compile(b.Right)
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+func (c *compiler) comparison(b *Binary) (*Compiled, error) {
+	left, right, err := c.operands(b)
+	if err != nil {
+		return nil, err
+	}
+	if left, right, err = coerce(b.Left, left, b.Right, right); err != nil {
+		return nil, err
+	}
+	var test func(result int) bool
+	switch b.Op {
+	case "=":
+		test = func(result int) bool { return result == 0 }
+	case "!=":
+		test = func(result int) bool { return result != 0 }
+	case "<":
+		test = func(result int) bool { return result < 0 }
+	case "<=":
+		test = func(result int) bool { return result <= 0 }
+	case ">":
+		test = func(result int) bool { return result > 0 }
+	default:
+		test = func(result int) bool { return result >= 0 }
+	}
+	equality := b.Op == "=" || b.Op == "!="
+	return &Compiled{Type: Type{Kind: KindBool}, eval: func(row map[string]interface{}) interface{} {
+		l, r := left.eval(row), right.eval(row)
+		if l == nil || r == nil {
+			return nil
+		}
+		result, ok := Compare(l, r)
+		if !ok {
+			if equality {
+				// values of different types are never equal
+				return b.Op == "!="
+			}
+			return nil
+		}
+		return test(result)
+	}}, nil
+}
+
+// coerce checks that operands can be compared, string literals compared with timestamps
+// are converted to timestamps and strings compared with enums are checked to be symbols
+func coerce(leftExpr Expr, left *Compiled, rightExpr Expr, right *Compiled) (*Compiled, *Compiled, error) {
+	var err error
+	if left, err = coerceLiteral(rightExpr, right.Type, leftExpr, left); err != nil {
+		return nil, nil, err
+	}
+	if right, err = coerceLiteral(leftExpr, left.Type, rightExpr, right); err != nil {
+		return nil, nil, err
+	}
+	if !comparable(left.Type, right.Type) {
+		return nil, nil, fmt.Errorf("%s of type %s can't be compared with %s of type %s", leftExpr, left.Type, rightExpr, right.Type)
+	}
+	return left, right, nil
+}
+
+func coerceLiteral(otherExpr Expr, other Type, e Expr, compiled *Compiled) (*Compiled, error) {
+	if !compiled.constant || compiled.Type.Kind != KindString {
+		return compiled, nil
+	}
+	text := compiled.eval(nil).(string)
+	if other.Kind == KindTimestamp {
+		timestamp, ok := schema.ParseTimestamp(text)
+		if !ok {
+			return nil, fmt.Errorf("%s compared with %s of type %s is not a timestamp, expected RFC 3339 timestamp like 2006-01-02T15:04:05Z or date like 2006-01-02", e, otherExpr, other)
+		}
+		return literal(timestamp), nil
+	}
+	if enum, ok := other.Schema.(schema.AvroEnum); ok {
+		for _, symbol := range enum.Symbols() {
+			if symbol == text {
+				return compiled, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a symbol of enum %s of %s, symbols are %s", e, enum.FullName(), otherExpr, strings.Join(enum.Symbols(), ", "))
+	}
+	return compiled, nil
+}
+
+func (c *compiler) in(n *In) (*Compiled, error) {
+	x, err := c.compile(n.X)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*Compiled, len(n.List))
+	for idx, item := range n.List {
+		if items[idx], err = c.compile(item); err != nil {
+			return nil, err
+		}
+		if _, items[idx], err = coerce(n.X, x, item, items[idx]); err != nil {
+			return nil, err
+		}
+	}
+	not := n.Not
+	return &Compiled{Type: Type{Kind: KindBool}, eval: func(row map[string]interface{}) interface{} {
+		value := x.eval(row)
+		if value == nil {
+			return nil
+		}
+		hasNull := false
+		for _, item := range items {
+			itemValue := item.eval(row)
+			if itemValue == nil {
+				hasNull = true
+			} else if equal(value, itemValue) {
+				return !not
+			}
+		}
+		if hasNull {
+			return nil
+		}
+		return not
+	}}, nil
+}
+
+// match compiles like with % and _ wildcards and ~ and !~ with regular expressions,
+// patterns should be string literals
+func (c *compiler) match(b *Binary) (*Compiled, error) {
+	left, right, err := c.operands(b)
+	if err != nil {
+		return nil, err
+	}
+	if !left.Type.is(KindString) {
+		return nil, fmt.Errorf("%s expects a string, got %s of type %s", b.Op, b.Left, left.Type)
+	}
+	if !right.constant || right.Type.Kind != KindString {
+		return nil, fmt.Errorf("pattern of %s should be a string literal, got %s", b.Op, b.Right)
+	}
+	pattern := right.eval(nil).(string)
+	if b.Op == "like" {
+		pattern = likePattern(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %s: %w", b.Right, err)
+	}
+	expected := b.Op != "!~"
+	return &Compiled{Type: Type{Kind: KindBool}, eval: func(row map[string]interface{}) interface{} {
+		if value, ok := left.eval(row).(string); ok {
+			return re.MatchString(value) == expected
+		}
+		return nil
+	}}, nil
+}
+
+func likePattern(pattern string) string {
+	var result strings.Builder
+	result.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			result.WriteString(".*")
+		case '_':
+			result.WriteString(".")
+		default:
+			result.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	result.WriteString("$")
+	return result.String()
+}
+
+// arithmetic compiles +, -, * and % of numbers, int operands give int results except
+// for division that is always a float
+func (c *compiler) arithmetic(b *Binary) (*Compiled, error) {
+	left, right, err := c.operands(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, operand := range []struct {
+		e Expr
+		t Type
+	}{{b.Left, left.Type}, {b.Right, right.Type}} {
+		if !operand.t.is(KindInt, KindFloat) {
+			return nil, fmt.Errorf("%s expects numbers, got %s of type %s", b.Op, operand.e, operand.t)
+		}
+	}
+	t := Type{Kind: KindFloat}
+	if b.Op != "/" && left.Type.Kind == KindInt && right.Type.Kind == KindInt {
+		t.Kind = KindInt
+	} else if b.Op != "/" && (left.Type.Kind == KindAny || right.Type.Kind == KindAny) {
+		t.Kind = KindAny
+	}
+	op := b.Op
+	return &Compiled{Type: t, eval: func(row map[string]interface{}) interface{} {
+		return arithmetic(op, left.eval(row), right.eval(row))
+	}}, nil
+}
+
+func arithmetic(op string, a, b interface{}) interface{} {
+	if x, ok := a.(int64); ok && op != "/" {
+		if y, ok := b.(int64); ok {
+			switch op {
+			case "+":
+				return x + y
+			case "-":
+				return x - y
+			case "*":
+				return x * y
+			case "%":
+				if y == 0 {
+					return nil
+				}
+				return x % y
+			}
+		}
+	}
+	x, xok := toFloat(a)
+	y, yok := toFloat(b)
+	if !xok || !yok {
+		return nil
+	}
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			return nil
+		}
+		return x / y
+	}
+	if y == 0 {
+		return nil
+	}
+	return math.Mod(x, y)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// function is a scalar function, check returns type of result for types of arguments
+type function struct {
+	check func(args []Type) (Type, error)
+	eval  func(args []interface{}) interface{}
+}
+
+var functions = map[string]function{
+	"lower": {stringArgs(1, KindString), func(args []interface{}) interface{} {
+		return mapString(args[0], strings.ToLower)
+	}},
+	"upper": {stringArgs(1, KindString), func(args []interface{}) interface{} {
+		return mapString(args[0], strings.ToUpper)
+	}},
+	"startswith": {stringArgs(2, KindBool), func(args []interface{}) interface{} {
+		return testStrings(args, strings.HasPrefix)
+	}},
+	"endswith": {stringArgs(2, KindBool), func(args []interface{}) interface{} {
+		return testStrings(args, strings.HasSuffix)
+	}},
+	"contains": {stringArgs(2, KindBool), func(args []interface{}) interface{} {
+		return testStrings(args, strings.Contains)
+	}},
+	"length": {func(args []Type) (Type, error) {
+		if len(args) != 1 || !args[0].is(KindString, KindBytes, KindArray, KindMap) {
+			return Type{}, fmt.Errorf("expects a string, bytes, array or map")
+		}
+		return Type{Kind: KindInt}, nil
+	}, func(args []interface{}) interface{} {
+		switch v := args[0].(type) {
+		case string:
+			return int64(utf8.RuneCountInString(v))
+		case []byte:
+			return int64(len(v))
+		case []interface{}:
+			return int64(len(v))
+		case map[string]interface{}:
+			return int64(len(v))
+		}
+		return nil
+	}},
+	"abs": {func(args []Type) (Type, error) {
+		if len(args) != 1 || !args[0].is(KindInt, KindFloat) {
+			return Type{}, fmt.Errorf("expects a number")
+		}
+		return Type{Kind: args[0].Kind}, nil
+	}, func(args []interface{}) interface{} {
+		switch v := args[0].(type) {
+		case int64:
+			if v < 0 {
+				return -v
+			}
+			return v
+		case float64:
+			return math.Abs(v)
+		}
+		return nil
+	}},
+	"coalesce": {func(args []Type) (Type, error) {
+		if len(args) == 0 {
+			return Type{}, fmt.Errorf("expects at least one value")
+		}
+		result := Type{Kind: KindNull}
+		for _, arg := range args {
+			if result.Kind == KindNull {
+				result = arg
+			} else if arg.Kind != KindNull && arg.Kind != result.Kind {
+				return Type{Kind: KindAny}, nil
+			}
+		}
+		return result, nil
+	}, func(args []interface{}) interface{} {
+		for _, arg := range args {
+			if arg != nil {
+				return arg
+			}
+		}
+		return nil
+	}},
+}
+
+// aggregates are functions of query results that can't be used in filters
+var aggregates = map[string]bool{"count": true, "sum": true, "min": true, "max": true, "avg": true}
+
+// IsAggregate checks whether function name is a name of aggregate function
+func IsAggregate(name string) bool {
+	return aggregates[name]
+}
+
+func (c *compiler) call(n *Call) (*Compiled, error) {
+	if aggregates[n.Name] {
+		return nil, fmt.Errorf("aggregate function %s can't be used here", n)
+	}
+	f, found := functions[n.Name]
+	if !found {
+		names := make([]string, 0, len(functions))
+		for name := range functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown function %s, functions are %s", n.Name, strings.Join(names, ", "))
+	}
+	if n.Star || n.Distinct {
+		return nil, fmt.Errorf("%s: * and distinct are allowed only in aggregate functions", n)
+	}
+	args := make([]*Compiled, len(n.Args))
+	types := make([]Type, len(n.Args))
+	for idx, arg := range n.Args {
+		var err error
+		if args[idx], err = c.compile(arg); err != nil {
+			return nil, err
+		}
+		types[idx] = args[idx].Type
+	}
+	t, err := f.check(types)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s %s, got %s", n, n.Name, err.Error(), typeList(types))
+	}
+	strict := n.Name != "coalesce"
+	return &Compiled{Type: t, eval: func(row map[string]interface{}) interface{} {
+		values := make([]interface{}, len(args))
+		for idx, arg := range args {
+			values[idx] = arg.eval(row)
+			if values[idx] == nil && strict {
+				return nil
+			}
+		}
+		return f.eval(values)
+	}}, nil
+}
+
+func typeList(types []Type) string {
+	if len(types) == 0 {
+		return "no arguments"
+	}
+	names := make([]string, len(types))
+	for idx, t := range types {
+		names[idx] = t.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+func stringArgs(count int, result Kind) func(args []Type) (Type, error) {
+	return func(args []Type) (Type, error) {
+		if len(args) != count {
+			return Type{}, fmt.Errorf("expects %d string arguments", count)
+		}
+		for _, arg := range args {
+			if !arg.is(KindString) {
+				return Type{}, fmt.Errorf("expects %d string arguments", count)
+			}
+		}
+		return Type{Kind: result}, nil
+	}
+}
+
+func mapString(value interface{}, f func(string) string) interface{} {
+	if s, ok := value.(string); ok {
+		return f(s)
+	}
+	return nil
+}
+
+func testStrings(args []interface{}, f func(s, pattern string) bool) interface{} {
+	s, ok := args[0].(string)
+	pattern, patternOk := args[1].(string)
+	if !ok || !patternOk {
+		return nil
+	}
+	return f(s, pattern)
+}
@@ -0,0 +1,157 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	// TokenIdent is a name or a keyword, names quoted with backticks are never keywords
+	TokenIdent
+	TokenQuotedIdent
+	TokenNumber
+	TokenString
+	TokenOperator
+)
+
+// Token is a lexeme of expression, Pos is byte offset of the token in the source text
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  int
+	End  int
+}
+
+// IsKeyword checks whether token is an unquoted name equal to keyword ignoring case
+func (t Token) IsKeyword(keyword string) bool {
+	return t.Kind == TokenIdent && strings.EqualFold(t.Text, keyword)
+}
+
+func (t Token) IsOperator(operator string) bool {
+	return t.Kind == TokenOperator && t.Text == operator
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case TokenEOF:
+		return "end of expression"
+	case TokenString:
+		return fmt.Sprintf("string %q", t.Text)
+	case TokenQuotedIdent:
+		return "`" + t.Text + "`"
+	}
+	return fmt.Sprintf("%q", t.Text)
+}
+
+var operators = []string{"==", "!=", "<>", "<=", ">=", "!~", "=", "<", ">", "~", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", "."}
+
+// Tokenize splits text to tokens, strings are quoted with single or double quotes and
+// names with backticks, quotes are escaped by doubling them or with backslash
+func Tokenize(text string) ([]Token, error) {
+	var tokens []Token
+	pos := 0
+	for pos < len(text) {
+		r, size := utf8.DecodeRuneInString(text[pos:])
+		if unicode.IsSpace(r) {
+			pos += size
+			continue
+		}
+		start := pos
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			value, end, err := readQuoted(text, pos)
+			if err != nil {
+				return nil, err
+			}
+			kind := TokenString
+			if r == '`' {
+				kind = TokenQuotedIdent
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: value, Pos: start, End: end})
+			pos = end
+		case r >= '0' && r <= '9':
+			pos = readNumber(text, pos)
+			tokens = append(tokens, Token{Kind: TokenNumber, Text: text[start:pos], Pos: start, End: pos})
+		case r == '_' || r == '$' || unicode.IsLetter(r):
+			for pos < len(text) {
+				r, size = utf8.DecodeRuneInString(text[pos:])
+				if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				pos += size
+			}
+			tokens = append(tokens, Token{Kind: TokenIdent, Text: text[start:pos], Pos: start, End: pos})
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(text[pos:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", r, pos)
+			}
+			pos += len(operator)
+			tokens = append(tokens, Token{Kind: TokenOperator, Text: operator, Pos: start, End: pos})
+		}
+	}
+	return append(tokens, Token{Kind: TokenEOF, Pos: len(text), End: len(text)}), nil
+}
+
+func readQuoted(text string, pos int) (string, int, error) {
+	quote := text[pos]
+	var value strings.Builder
+	for idx := pos + 1; idx < len(text); idx++ {
+		c := text[idx]
+		switch {
+		case c == '\\' && idx+1 < len(text):
+			idx++
+			switch text[idx] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			default:
+				value.WriteByte(text[idx])
+			}
+		case c == quote && idx+1 < len(text) && text[idx+1] == quote:
+			value.WriteByte(quote)
+			idx++
+		case c == quote:
+			return value.String(), idx + 1, nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %c quote at %d", quote, pos)
+}
+
+func readNumber(text string, pos int) int {
+	digits := func() {
+		for pos < len(text) && text[pos] >= '0' && text[pos] <= '9' {
+			pos++
+		}
+	}
+	digits()
+	if pos+1 < len(text) && text[pos] == '.' && text[pos+1] >= '0' && text[pos+1] <= '9' {
+		pos++
+		digits()
+	}
+	if pos < len(text) && (text[pos] == 'e' || text[pos] == 'E') {
+		next := pos + 1
+		if next < len(text) && (text[next] == '+' || text[next] == '-') {
+			next++
+		}
+		if next < len(text) && text[next] >= '0' && text[next] <= '9' {
+			pos = next
+			digits()
+		}
+	}
+	return pos
+}
@@ -0,0 +1,357 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var keywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true, "like": true,
+	"null": true, "true": true, "false": true, "distinct": true,
+}
+
+var comparisons = map[string]string{
+	"=": "=", "==": "=", "!=": "!=", "<>": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">=", "~": "~", "!~": "!~",
+}
+
+// Parser reads expressions from tokens, it is used by itself for filters and by query
+// parser for parts of statements
+type Parser struct {
+	tokens   []Token
+	pos      int
+	reserved map[string]bool
+}
+
+// NewParser creates parser of tokens, reserved words can't be used as unquoted names
+// in addition to keywords of expressions
+func NewParser(tokens []Token, reserved ...string) *Parser {
+	p := &Parser{tokens: tokens, reserved: map[string]bool{}}
+	for _, word := range reserved {
+		p.reserved[strings.ToLower(word)] = true
+	}
+	return p
+}
+
+// Parse parses text that should contain a single expression
+func Parse(text string) (Expr, error) {
+	tokens, err := Tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := NewParser(tokens)
+	e, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.Peek(); token.Kind != TokenEOF {
+		return nil, p.Unexpected()
+	}
+	return e, nil
+}
+
+func (p *Parser) Peek() Token {
+	return p.tokens[p.pos]
+}
+
+func (p *Parser) Next() Token {
+	token := p.tokens[p.pos]
+	if token.Kind != TokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// AcceptKeyword skips the next token if it is the keyword
+func (p *Parser) AcceptKeyword(keyword string) bool {
+	if p.Peek().IsKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// AcceptOperator skips the next token if it is the operator
+func (p *Parser) AcceptOperator(operator string) bool {
+	if p.Peek().IsOperator(operator) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *Parser) ExpectKeyword(keyword string) error {
+	if !p.AcceptKeyword(keyword) {
+		return fmt.Errorf("expected %s, got %s at %d", strings.ToUpper(keyword), p.Peek(), p.Peek().Pos)
+	}
+	return nil
+}
+
+func (p *Parser) ExpectOperator(operator string) error {
+	if !p.AcceptOperator(operator) {
+		return fmt.Errorf("expected %q, got %s at %d", operator, p.Peek(), p.Peek().Pos)
+	}
+	return nil
+}
+
+// Unexpected returns error about the next token
+func (p *Parser) Unexpected() error {
+	return fmt.Errorf("unexpected %s at %d", p.Peek(), p.Peek().Pos)
+}
+
+// IsReserved checks whether token is an unquoted keyword or reserved word
+func (p *Parser) IsReserved(token Token) bool {
+	name := strings.ToLower(token.Text)
+	return token.Kind == TokenIdent && (keywords[name] || p.reserved[name])
+}
+
+// ParseName reads a plain or quoted name that is not reserved
+func (p *Parser) ParseName() (string, error) {
+	token := p.Peek()
+	if (token.Kind != TokenIdent && token.Kind != TokenQuotedIdent) || p.IsReserved(token) {
+		return "", fmt.Errorf("expected name, got %s at %d", token, token.Pos)
+	}
+	p.pos++
+	return token.Text, nil
+}
+
+// ParseExpr reads expression, operators from the lowest precedence are: or, and, not,
+// comparisons, additive and multiplicative operators
+func (p *Parser) ParseExpr() (Expr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.AcceptKeyword("or") {
+		var right Expr
+		if right, err = p.parseAnd(); err == nil {
+			left = &Binary{Op: "or", Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *Parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	for err == nil && p.AcceptKeyword("and") {
+		var right Expr
+		if right, err = p.parseNot(); err == nil {
+			left = &Binary{Op: "and", Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *Parser) parseNot() (Expr, error) {
+	if p.AcceptKeyword("not") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: "not", X: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *Parser) parseComparison() (Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	token := p.Peek()
+	if op, found := comparisons[token.Text]; found && token.Kind == TokenOperator {
+		p.pos++
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: op, Left: left, Right: right}, nil
+	}
+	if p.AcceptKeyword("is") {
+		not := p.AcceptKeyword("not")
+		if err := p.ExpectKeyword("null"); err != nil {
+			return nil, err
+		}
+		return &IsNull{X: left, Not: not}, nil
+	}
+	not := false
+	if token.IsKeyword("not") {
+		if next := p.tokens[p.pos+1]; next.IsKeyword("in") || next.IsKeyword("like") {
+			p.pos++
+			not = true
+		}
+	}
+	if p.AcceptKeyword("in") {
+		if err := p.ExpectOperator("("); err != nil {
+			return nil, err
+		}
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &In{X: left, List: list, Not: not}, nil
+	}
+	if p.AcceptKeyword("like") {
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		var e Expr = &Binary{Op: "like", Left: left, Right: right}
+		if not {
+			e = &Unary{Op: "not", X: e}
+		}
+		return e, nil
+	}
+	return left, nil
+}
+
+// parseList reads comma separated expressions up to the closing parenthesis
+func (p *Parser) parseList() ([]Expr, error) {
+	var list []Expr
+	if p.AcceptOperator(")") {
+		return list, nil
+	}
+	for {
+		item, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		if p.AcceptOperator(")") {
+			return list, nil
+		}
+		if err = p.ExpectOperator(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Parser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	for err == nil && (p.Peek().IsOperator("+") || p.Peek().IsOperator("-")) {
+		op := p.Next().Text
+		var right Expr
+		if right, err = p.parseMultiplicative(); err == nil {
+			left = &Binary{Op: op, Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *Parser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	for err == nil && (p.Peek().IsOperator("*") || p.Peek().IsOperator("/") || p.Peek().IsOperator("%")) {
+		op := p.Next().Text
+		var right Expr
+		if right, err = p.parseUnary(); err == nil {
+			left = &Binary{Op: op, Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *Parser) parseUnary() (Expr, error) {
+	if !p.AcceptOperator("-") {
+		return p.parsePrimary()
+	}
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if literal, ok := x.(*Literal); ok {
+		switch v := literal.Value.(type) {
+		case int64:
+			return &Literal{Value: -v}, nil
+		case float64:
+			return &Literal{Value: -v}, nil
+		}
+	}
+	return &Unary{Op: "-", X: x}, nil
+}
+
+func (p *Parser) parsePrimary() (Expr, error) {
+	token := p.Peek()
+	switch {
+	case token.Kind == TokenNumber:
+		p.pos++
+		if i, err := strconv.ParseInt(token.Text, 10, 64); err == nil {
+			return &Literal{Value: i}, nil
+		}
+		f, err := strconv.ParseFloat(token.Text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at %d", token.Text, token.Pos)
+		}
+		return &Literal{Value: f}, nil
+	case token.Kind == TokenString:
+		p.pos++
+		return &Literal{Value: token.Text}, nil
+	case token.IsKeyword("null"):
+		p.pos++
+		return &Literal{}, nil
+	case token.IsKeyword("true"), token.IsKeyword("false"):
+		p.pos++
+		return &Literal{Value: token.IsKeyword("true")}, nil
+	case token.IsOperator("("):
+		p.pos++
+		e, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.ExpectOperator(")")
+	case token.Kind == TokenIdent && !p.IsReserved(token) && p.tokens[p.pos+1].IsOperator("("):
+		p.pos += 2
+		return p.parseCall(strings.ToLower(token.Text))
+	case token.Kind == TokenIdent || token.Kind == TokenQuotedIdent:
+		return p.parsePath()
+	}
+	return nil, p.Unexpected()
+}
+
+func (p *Parser) parseCall(name string) (Expr, error) {
+	call := &Call{Name: name}
+	if p.AcceptOperator("*") {
+		call.Star = true
+		return call, p.ExpectOperator(")")
+	}
+	call.Distinct = p.AcceptKeyword("distinct")
+	args, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	call.Args = args
+	return call, nil
+}
+
+// parsePath reads name followed by .name, [index] and ['key'] selectors
+func (p *Parser) parsePath() (Expr, error) {
+	name, err := p.ParseName()
+	if err != nil {
+		return nil, err
+	}
+	path := &Path{Segments: []Segment{{Name: name}}}
+	for {
+		if p.AcceptOperator(".") {
+			token := p.Next()
+			if token.Kind != TokenIdent && token.Kind != TokenQuotedIdent {
+				return nil, fmt.Errorf("expected field name after %s, got %s at %d", path, token, token.Pos)
+			}
+			path.Segments = append(path.Segments, Segment{Name: token.Text})
+		} else if p.AcceptOperator("[") {
+			token := p.Next()
+			switch token.Kind {
+			case TokenString:
+				path.Segments = append(path.Segments, Segment{Name: token.Text})
+			case TokenNumber:
+				index, err := strconv.Atoi(token.Text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %s at %d", token.Text, token.Pos)
+				}
+				path.Segments = append(path.Segments, Segment{Index: index, IsIndex: true})
+			default:
+				return nil, fmt.Errorf("expected index or key in brackets after %s, got %s at %d", path, token, token.Pos)
+			}
+			if err := p.ExpectOperator("]"); err != nil {
+				return nil, err
+			}
+		} else {
+			return path, nil
+		}
+	}
+}
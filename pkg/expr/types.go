@@ -0,0 +1,121 @@
+package expr
+
+import (
+	"avroparser/pkg/schema"
+)
+
+type Kind int
+
+const (
+	// KindAny is a type known only at runtime, like a type of union value or a value
+	// without schema
+	KindAny Kind = iota
+	KindNull
+	KindBool
+	KindInt
+	KindFloat
+	KindString
+	KindBytes
+	KindTimestamp
+	KindArray
+	KindMap
+	KindRecord
+)
+
+var kindNames = map[Kind]string{
+	KindAny: "any", KindNull: "null", KindBool: "boolean", KindInt: "int", KindFloat: "float",
+	KindString: "string", KindBytes: "bytes", KindTimestamp: "timestamp", KindArray: "array",
+	KindMap: "map", KindRecord: "record",
+}
+
+// Type is a type of expression value, Schema is set for values read from avro data
+type Type struct {
+	Kind   Kind
+	Schema schema.ItemSchema
+}
+
+func (t Type) String() string {
+	if t.Schema != nil {
+		if logical := schema.LogicalTypeOf(t.Schema); logical != nil {
+			return kindNames[t.Kind] + " (" + logical.Name + ")"
+		}
+		switch t.Schema.(type) {
+		case schema.AvroRecord, schema.AvroEnum, schema.AvroFixed:
+			return kindNames[t.Kind] + " (" + schema.TypeName(t.Schema) + ")"
+		}
+	}
+	return kindNames[t.Kind]
+}
+
+// TypeOf returns type of values decoded with the schema, nullable union has type of its
+// non-null element. Ints and longs are ints, floats, doubles and decimals are floats,
+// enums are strings and fixed are bytes. Dates and timestamps are timestamps.
+func TypeOf(s schema.ItemSchema) Type {
+	if s == nil {
+		return Type{Kind: KindAny}
+	}
+	if union, ok := s.(schema.AvroUnion); ok {
+		var nonNull []schema.ItemSchema
+		for _, element := range union.Elements() {
+			if _, isNull := element.(schema.AvroNull); !isNull {
+				nonNull = append(nonNull, element)
+			}
+		}
+		switch len(nonNull) {
+		case 0:
+			return Type{Kind: KindNull, Schema: s}
+		case 1:
+			return TypeOf(nonNull[0])
+		}
+		return Type{Kind: KindAny, Schema: s}
+	}
+	t := Type{Schema: s}
+	if logical := schema.LogicalTypeOf(s); logical != nil {
+		switch logical.Name {
+		case schema.LogicalDate, schema.LogicalTimestampMillis, schema.LogicalTimestampMicros, schema.LogicalTimestampNanos,
+			schema.LogicalLocalTimestampMillis, schema.LogicalLocalTimestampMicros, schema.LogicalLocalTimestampNanos:
+			t.Kind = KindTimestamp
+			return t
+		case schema.LogicalDecimal:
+			t.Kind = KindFloat
+			return t
+		}
+	}
+	switch s.(type) {
+	case schema.AvroNull:
+		t.Kind = KindNull
+	case schema.AvroBoolean:
+		t.Kind = KindBool
+	case schema.AvroInt, schema.AvroLong:
+		t.Kind = KindInt
+	case schema.AvroFloat, schema.AvroDouble:
+		t.Kind = KindFloat
+	case schema.AvroString, schema.AvroEnum:
+		t.Kind = KindString
+	case schema.AvroBytes, schema.AvroFixed:
+		t.Kind = KindBytes
+	case schema.AvroArray:
+		t.Kind = KindArray
+	case schema.AvroMap:
+		t.Kind = KindMap
+	case schema.AvroRecord:
+		t.Kind = KindRecord
+	}
+	return t
+}
+
+func (t Type) numeric() bool {
+	return t.Kind == KindInt || t.Kind == KindFloat
+}
+
+// comparable checks whether values of types can be compared, values of any type are
+// compared at runtime
+func comparable(a, b Type) bool {
+	switch {
+	case a.Kind == KindAny || b.Kind == KindAny || a.Kind == KindNull || b.Kind == KindNull:
+		return true
+	case a.numeric() && b.numeric():
+		return true
+	}
+	return a.Kind == b.Kind && a.Kind != KindArray && a.Kind != KindMap && a.Kind != KindRecord
+}
@@ -0,0 +1,199 @@
+package expr
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// converter returns function converting decoded avro values of the type to values
+// of expressions: int64, float64, string, []byte, bool, time.Time, arrays and maps
+func converter(t Type) func(value interface{}) interface{} {
+	switch t.Kind {
+	case KindInt, KindFloat:
+		if logical := schema.LogicalTypeOf(t.Schema); logical != nil && logical.Name == schema.LogicalDecimal {
+			scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(logical.Scale)), nil)
+			return func(value interface{}) interface{} {
+				if data, ok := value.([]byte); ok {
+					return decimalFloat(data, scale)
+				}
+				return Normalize(value)
+			}
+		}
+		return Normalize
+	case KindTimestamp:
+		logical := schema.LogicalTypeOf(t.Schema)
+		return func(value interface{}) interface{} {
+			return timestamp(logical, value)
+		}
+	case KindAny:
+		if union, ok := t.Schema.(schema.AvroUnion); ok {
+			elements := union.Elements()
+			converters := make([]func(interface{}) interface{}, len(elements))
+			for idx, element := range elements {
+				converters[idx] = converter(TypeOf(element))
+			}
+			return func(value interface{}) interface{} {
+				if value == nil {
+					return nil
+				}
+				if idx, untagged := union.Branch(value); idx >= 0 {
+					return converters[idx](untagged)
+				}
+				return Normalize(value)
+			}
+		}
+		return Normalize
+	}
+	return func(value interface{}) interface{} {
+		return value
+	}
+}
+
+// Normalize converts ints to int64, floats to float64 and json numbers to one of them,
+// tagged union values are untagged
+func Normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case schema.UnionValue:
+		return Normalize(v.Value)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	}
+	return value
+}
+
+func timestamp(logical *schema.LogicalType, value interface{}) interface{} {
+	var v int64
+	switch n := value.(type) {
+	case int32:
+		v = int64(n)
+	case int64:
+		v = n
+	default:
+		return Normalize(value)
+	}
+	if logical == nil {
+		return v
+	}
+	switch logical.Name {
+	case schema.LogicalDate:
+		return time.Unix(v*24*60*60, 0).UTC()
+	case schema.LogicalTimestampMillis, schema.LogicalLocalTimestampMillis:
+		return time.UnixMilli(v).UTC()
+	case schema.LogicalTimestampMicros, schema.LogicalLocalTimestampMicros:
+		return time.UnixMicro(v).UTC()
+	}
+	return time.Unix(0, v).UTC()
+}
+
+// decimalFloat converts big-endian two's complement unscaled value to float
+func decimalFloat(data []byte, scale *big.Int) float64 {
+	unscaled := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+	f, _ := new(big.Rat).SetFrac(unscaled, scale).Float64()
+	return f
+}
+
+// Compare compares values of expressions, ok is false if values are null or of types
+// that can't be compared. Strings are compared with timestamps as RFC 3339 timestamps.
+func Compare(a, b interface{}) (result int, ok bool) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return compareInts(x, y), true
+		case float64:
+			return compareFloats(float64(x), y), true
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return compareFloats(x, float64(y)), true
+		case float64:
+			return compareFloats(x, y), true
+		}
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), true
+		case time.Time:
+			if t, parsed := schema.ParseTimestamp(x); parsed {
+				return compareTimes(t, y), true
+			}
+		}
+	case bool:
+		if y, isBool := b.(bool); isBool {
+			if x == y {
+				return 0, true
+			} else if y {
+				return -1, true
+			}
+			return 1, true
+		}
+	case []byte:
+		if y, isBytes := b.([]byte); isBytes {
+			return bytes.Compare(x, y), true
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return compareTimes(x, y), true
+		case string:
+			if t, parsed := schema.ParseTimestamp(y); parsed {
+				return compareTimes(x, t), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func compareInts(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	if a.Before(b) {
+		return -1
+	} else if a.After(b) {
+		return 1
+	}
+	return 0
+}
+
+// equal checks equality of non-null values, values of types that can't be compared
+// are not equal
+func equal(a, b interface{}) bool {
+	result, ok := Compare(a, b)
+	return ok && result == 0
+}
@@ -0,0 +1,203 @@
+package idl
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+	"io/ioutil"
+)
+
+// Document is parsed avro IDL protocol or schema file with all its imports
+type Document struct {
+	// Protocol is json definition of the protocol in .avpr format, nil for schema files
+	Protocol map[string]interface{}
+	// Types are named types declared in the file and imported files in declaration order
+	Types []schema.ItemSchema
+	// Schema is main schema declared in schema file with schema keyword
+	Schema schema.ItemSchema
+	parser *schema.Parser
+	// schemaJSON is json of the main schema, definitions are json of named types by full name
+	schemaJSON  interface{}
+	definitions map[string]definition
+}
+
+// NamedType returns declared named type by its full name
+func (d *Document) NamedType(name string) (schema.ItemSchema, bool) {
+	return d.parser.NamedType(name)
+}
+
+func ParseFile(fileName string) (*Document, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data), fileName)
+}
+
+// Parse parses IDL source, imports are resolved relative to directory of fileName
+func Parse(source string, fileName string) (*Document, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fileName, err)
+	}
+	s := &state{
+		types:    make([]interface{}, 0),
+		declared: make(map[string]bool),
+		messages: make(map[string]interface{}),
+		imported: make(map[string]bool),
+	}
+	p := parser{state: s, file: fileName, tokens: tokens}
+	protocol, mainSchema, err := p.parseFile()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := s.resolveRefs(s.types)
+	if err != nil {
+		return nil, err
+	}
+	types := resolved.([]interface{})
+	document := &Document{parser: schema.NewParser(), definitions: make(map[string]definition)}
+	collectDefinitions(types, "", document.definitions)
+	jsonSchemas := types
+	if mainSchema != nil {
+		if document.schemaJSON, err = s.resolveRefs(mainSchema); err != nil {
+			return nil, err
+		}
+		jsonSchemas = append(append(make([]interface{}, 0, len(types)+1), types...), document.schemaJSON)
+	}
+	roots, err := document.parser.Parse(jsonSchemas...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	document.Types = roots[:len(types)]
+	if mainSchema != nil {
+		document.Schema = roots[len(types)]
+	}
+	if protocol != nil {
+		protocol["types"] = types
+		messages := make(map[string]interface{})
+		for _, name := range s.messageOrder {
+			if messages[name], err = s.resolveRefs(s.messages[name]); err != nil {
+				return nil, err
+			}
+		}
+		protocol["messages"] = messages
+		document.Protocol = protocol
+	}
+	return document, nil
+}
+
+///////////////////////
+
+// definition is json of named type with namespace of the type it is declared in
+type definition struct {
+	json      map[string]interface{}
+	namespace string
+}
+
+// SchemaJSON returns json of the main schema if name is empty or of the named type with
+// the full name. Named types are defined at their first use, so the json can be parsed
+// alone, docs, defaults, aliases and other properties are kept as declared.
+func (d *Document) SchemaJSON(name string) (interface{}, bool) {
+	value := d.schemaJSON
+	if name != "" {
+		if _, found := d.definitions[name]; !found {
+			return nil, false
+		}
+		value = name
+	}
+	if value == nil {
+		return nil, false
+	}
+	return inline(value, "", d.definitions, make(map[string]bool)), true
+}
+
+func collectDefinitions(jsonSchema interface{}, namespace string, definitions map[string]definition) {
+	switch t := jsonSchema.(type) {
+	case []interface{}:
+		for _, item := range t {
+			collectDefinitions(item, namespace, definitions)
+		}
+	case map[string]interface{}:
+		switch t["type"] {
+		case "record", "error", "enum", "fixed":
+			fullName, inner := declaredName(t, namespace)
+			definitions[fullName] = definition{json: t, namespace: namespace}
+			fields, _ := t["fields"].([]interface{})
+			for _, field := range fields {
+				if fieldMap, ok := field.(map[string]interface{}); ok {
+					collectDefinitions(fieldMap["type"], inner, definitions)
+				}
+			}
+		case "array":
+			collectDefinitions(t["items"], namespace, definitions)
+		case "map":
+			collectDefinitions(t["values"], namespace, definitions)
+		default:
+			collectDefinitions(t["type"], namespace, definitions)
+		}
+	}
+}
+
+// inline copies json schema replacing names of types that are not defined yet with their
+// definitions, namespace is the one of enclosing type
+func inline(jsonSchema interface{}, namespace string, definitions map[string]definition, defined map[string]bool) interface{} {
+	switch t := jsonSchema.(type) {
+	case string:
+		named, found := definitions[t]
+		if !found || defined[t] {
+			return t
+		}
+		copied := copyObject(named.json)
+		if _, found = copied["namespace"]; !found && named.namespace != namespace {
+			copied["namespace"] = named.namespace
+		}
+		return inline(copied, namespace, definitions, defined)
+	case []interface{}:
+		result := make([]interface{}, len(t))
+		for idx, item := range t {
+			result[idx] = inline(item, namespace, definitions, defined)
+		}
+		return result
+	case map[string]interface{}:
+		result := copyObject(t)
+		switch t["type"] {
+		case "record", "error", "enum", "fixed":
+			fullName, inner := declaredName(t, namespace)
+			if defined[fullName] {
+				return fullName
+			}
+			defined[fullName] = true
+			if fields, ok := t["fields"].([]interface{}); ok {
+				copiedFields := make([]interface{}, len(fields))
+				for idx, field := range fields {
+					copiedFields[idx] = field
+					if fieldMap, ok := field.(map[string]interface{}); ok {
+						copiedField := copyObject(fieldMap)
+						copiedField["type"] = inline(fieldMap["type"], inner, definitions, defined)
+						copiedFields[idx] = copiedField
+					}
+				}
+				result["fields"] = copiedFields
+			}
+		case "array":
+			result["items"] = inline(t["items"], namespace, definitions, defined)
+		case "map":
+			result["values"] = inline(t["values"], namespace, definitions, defined)
+		default:
+			if itemType, found := t["type"]; found {
+				result["type"] = inline(itemType, namespace, definitions, defined)
+			}
+		}
+		return result
+	}
+	return jsonSchema
+}
+
+func copyObject(object map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(object)+1)
+	for key, value := range object {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,175 @@
+package idl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// doc is the contents of doc comment preceding the token
+	doc  string
+	line int
+	col  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+type lexer struct {
+	data []rune
+	pos  int
+	line int
+	col  int
+}
+
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) advance() rune {
+	r := l.data[l.pos]
+	l.pos++
+	if r == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	return r
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '_' || r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func tokenize(source string) ([]token, error) {
+	l := lexer{data: []rune(source), line: 1, col: 1}
+	tokens := make([]token, 0)
+	doc := ""
+	for {
+		// skip whitespace and comments, remembering last doc comment
+		for l.pos < len(l.data) {
+			if unicode.IsSpace(l.peek(0)) {
+				l.advance()
+			} else if l.peek(0) == '/' && l.peek(1) == '/' {
+				for l.pos < len(l.data) && l.peek(0) != '\n' {
+					l.advance()
+				}
+			} else if l.peek(0) == '/' && l.peek(1) == '*' {
+				line, col := l.line, l.col
+				isDoc := l.peek(2) == '*' && l.peek(3) != '/'
+				start := l.pos + 2
+				for l.pos < len(l.data) && !(l.peek(0) == '*' && l.peek(1) == '/' && l.pos >= start) {
+					l.advance()
+				}
+				if l.pos >= len(l.data) {
+					return nil, fmt.Errorf("%d:%d: unterminated comment", line, col)
+				}
+				if isDoc {
+					doc = cleanDoc(string(l.data[start+1 : l.pos]))
+				}
+				l.advance()
+				l.advance()
+			} else {
+				break
+			}
+		}
+		if l.pos >= len(l.data) {
+			tokens = append(tokens, token{kind: tokenEOF, line: l.line, col: l.col})
+			return tokens, nil
+		}
+		t := token{line: l.line, col: l.col, doc: doc}
+		doc = ""
+		r := l.peek(0)
+		switch {
+		case isIdentStart(r):
+			start := l.pos
+			for l.pos < len(l.data) && isIdentPart(l.peek(0)) {
+				l.advance()
+			}
+			t.kind = tokenIdent
+			t.text = string(l.data[start:l.pos])
+		case r == '`':
+			l.advance()
+			start := l.pos
+			for l.pos < len(l.data) && l.peek(0) != '`' {
+				l.advance()
+			}
+			if l.pos >= len(l.data) {
+				return nil, fmt.Errorf("%d:%d: unterminated quoted identifier", t.line, t.col)
+			}
+			t.kind = tokenIdent
+			t.text = string(l.data[start:l.pos])
+			l.advance()
+		case r == '"':
+			start := l.pos
+			l.advance()
+			for l.pos < len(l.data) && l.peek(0) != '"' {
+				if l.advance() == '\\' && l.pos < len(l.data) {
+					l.advance()
+				}
+			}
+			if l.pos >= len(l.data) {
+				return nil, fmt.Errorf("%d:%d: unterminated string", t.line, t.col)
+			}
+			l.advance()
+			if err := json.Unmarshal([]byte(string(l.data[start:l.pos])), &t.text); err != nil {
+				return nil, fmt.Errorf("%d:%d: invalid string: %w", t.line, t.col, err)
+			}
+			t.kind = tokenString
+		case r == '-' || unicode.IsDigit(r):
+			start := l.pos
+			l.advance()
+			for l.pos < len(l.data) && (unicode.IsDigit(l.peek(0)) || strings.ContainsRune(".eE+-", l.peek(0))) {
+				l.advance()
+			}
+			t.kind = tokenNumber
+			t.text = string(l.data[start:l.pos])
+		case strings.ContainsRune("{}()<>[];,=@:?", r):
+			l.advance()
+			t.kind = tokenPunct
+			t.text = string(r)
+		default:
+			return nil, fmt.Errorf("%d:%d: unexpected character '%c'", t.line, t.col, r)
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// cleanDoc removes leading asterisks and indentation from doc comment lines
+func cleanDoc(doc string) string {
+	lines := strings.Split(doc, "\n")
+	for idx, line := range lines {
+		line = strings.TrimSpace(line)
+		if idx > 0 {
+			line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		}
+		lines[idx] = line
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,867 @@
+package idl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// typeRef is a reference to named type, replaced with the full name once all types are known
+type typeRef struct {
+	name string
+	// namespace of enclosing type and of the file where the reference is used
+	namespace     string
+	fileNamespace string
+	// file, line and col locate the reference for errors
+	file      string
+	line, col int
+}
+
+// state is shared between the file and files imported from it
+type state struct {
+	types        []interface{}
+	declared     map[string]bool
+	messages     map[string]interface{}
+	messageOrder []string
+	imported     map[string]bool
+}
+
+type parser struct {
+	state  *state
+	file   string
+	tokens []token
+	pos    int
+	// namespace of the protocol or schema file and of enclosing named type
+	fileNamespace string
+	namespace     string
+}
+
+var logicalTypes = map[string]map[string]interface{}{
+	"date":               {"type": "int", "logicalType": "date"},
+	"time_ms":            {"type": "int", "logicalType": "time-millis"},
+	"timestamp_ms":       {"type": "long", "logicalType": "timestamp-millis"},
+	"local_timestamp_ms": {"type": "long", "logicalType": "local-timestamp-millis"},
+	"uuid":               {"type": "string", "logicalType": "uuid"},
+}
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true,
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d:%d: %s", p.file, t.line, t.col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) typeRef(t token) typeRef {
+	return typeRef{name: t.text, namespace: p.namespace, fileNamespace: p.fileNamespace, file: p.file, line: t.line, col: t.col}
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && t.text == text
+}
+
+func (p *parser) expectPunct(text string) error {
+	if t := p.next(); t.kind != tokenPunct || t.text != text {
+		return p.errorf(t, "expected '%s', found %s", text, t)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (token, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return t, p.errorf(t, "expected identifier, found %s", t)
+	}
+	return t, nil
+}
+
+func (p *parser) expectString() (string, error) {
+	t := p.next()
+	if t.kind != tokenString {
+		return "", p.errorf(t, "expected string, found %s", t)
+	}
+	return t.text, nil
+}
+
+func (p *parser) expectInt() (int, error) {
+	t := p.next()
+	if t.kind != tokenNumber {
+		return 0, p.errorf(t, "expected number, found %s", t)
+	}
+	value, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, p.errorf(t, "expected integer, found %s", t)
+	}
+	return value, nil
+}
+
+// readJSON reads json value used in defaults and annotations
+func (p *parser) readJSON() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.text, nil
+	case tokenNumber:
+		var value interface{}
+		if err := json.Unmarshal([]byte(t.text), &value); err != nil {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		return value, nil
+	case tokenIdent:
+		switch t.text {
+		case "null":
+			return nil, nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	case tokenPunct:
+		if t.text == "[" {
+			result := make([]interface{}, 0)
+			for !p.isPunct("]") {
+				if len(result) > 0 {
+					if err := p.expectPunct(","); err != nil {
+						return nil, err
+					}
+				}
+				value, err := p.readJSON()
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, value)
+			}
+			p.next()
+			return result, nil
+		} else if t.text == "{" {
+			result := make(map[string]interface{})
+			for !p.isPunct("}") {
+				if len(result) > 0 {
+					if err := p.expectPunct(","); err != nil {
+						return nil, err
+					}
+				}
+				key, err := p.expectString()
+				if err != nil {
+					return nil, err
+				}
+				if err = p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				if result[key], err = p.readJSON(); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+			return result, nil
+		}
+	}
+	return nil, p.errorf(t, "expected json value, found %s", t)
+}
+
+type annotation struct {
+	name  string
+	value interface{}
+}
+
+func (p *parser) readAnnotations() ([]annotation, error) {
+	result := make([]annotation, 0)
+	for p.isPunct("@") {
+		p.next()
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		value, err := p.readJSON()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		result = append(result, annotation{name: name.text, value: value})
+	}
+	return result, nil
+}
+
+// parseFile parses protocol or schema file, returning json definition of the protocol
+// or main schema of the schema file if it is declared
+func (p *parser) parseFile() (map[string]interface{}, interface{}, error) {
+	start := p.pos
+	doc := p.peek().doc
+	annotations, err := p.readAnnotations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.isKeyword("protocol") {
+		if doc == "" {
+			doc = p.peek().doc
+		}
+		protocol, err := p.parseProtocol(doc, annotations)
+		return protocol, nil, err
+	}
+	// annotations belong to the first named type declaration of schema file
+	p.pos = start
+	mainSchema, err := p.parseSchemaFile()
+	return nil, mainSchema, err
+}
+
+func (p *parser) setNamespace(namespace string) {
+	p.fileNamespace = namespace
+	p.namespace = namespace
+}
+
+func (p *parser) parseSchemaFile() (interface{}, error) {
+	if p.isKeyword("namespace") {
+		p.next()
+		name, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		p.setNamespace(name.text)
+		if err = p.expectPunct(";"); err != nil {
+			return nil, err
+		}
+	}
+	var mainSchema interface{}
+	if p.isKeyword("schema") {
+		p.next()
+		var err error
+		if mainSchema, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		mainSchema = unwrapOptional(mainSchema)
+		if err = p.expectPunct(";"); err != nil {
+			return nil, err
+		}
+	}
+	for p.peek().kind != tokenEOF {
+		if err := p.parseDeclaration(false); err != nil {
+			return nil, err
+		}
+	}
+	return mainSchema, nil
+}
+
+func (p *parser) parseProtocol(doc string, annotations []annotation) (map[string]interface{}, error) {
+	p.next()
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	protocol := map[string]interface{}{"protocol": name.text}
+	if doc != "" {
+		protocol["doc"] = doc
+	}
+	for _, a := range annotations {
+		protocol[a.name] = a.value
+	}
+	if namespace, ok := protocol["namespace"].(string); ok {
+		p.setNamespace(namespace)
+	}
+	if idx := strings.LastIndex(name.text, "."); idx >= 0 {
+		p.setNamespace(name.text[:idx])
+		protocol["protocol"] = name.text[idx+1:]
+		protocol["namespace"] = p.namespace
+	}
+	if err = p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	for !p.isPunct("}") {
+		if p.peek().kind == tokenEOF {
+			return nil, p.errorf(p.peek(), "unexpected end of protocol %s", name.text)
+		}
+		if err = p.parseDeclaration(true); err != nil {
+			return nil, err
+		}
+	}
+	p.next()
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s after protocol", t)
+	}
+	return protocol, nil
+}
+
+// parseDeclaration parses import, named type or message declaration
+func (p *parser) parseDeclaration(allowMessages bool) error {
+	if p.isKeyword("import") {
+		return p.parseImport()
+	}
+	doc := p.peek().doc
+	annotations, err := p.readAnnotations()
+	if err != nil {
+		return err
+	}
+	if doc == "" {
+		doc = p.peek().doc
+	}
+	t := p.peek()
+	if t.kind == tokenIdent {
+		switch t.text {
+		case "record", "error":
+			return p.parseRecord(doc, annotations)
+		case "enum":
+			return p.parseEnum(doc, annotations)
+		case "fixed":
+			return p.parseFixed(doc, annotations)
+		}
+	}
+	if !allowMessages {
+		return p.errorf(t, "expected named type declaration, found %s", t)
+	}
+	return p.parseMessage(doc, annotations)
+}
+
+func (p *parser) parseImport() error {
+	p.next()
+	kind, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+	location, err := p.expectString()
+	if err != nil {
+		return err
+	}
+	if err = p.expectPunct(";"); err != nil {
+		return err
+	}
+	fileName := filepath.Join(filepath.Dir(p.file), location)
+	if absolute, err := filepath.Abs(fileName); err == nil {
+		fileName = absolute
+	}
+	if p.state.imported[fileName] {
+		return nil
+	}
+	p.state.imported[fileName] = true
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return p.errorf(kind, "failed to import %s: %v", location, err)
+	}
+	switch kind.text {
+	case "idl":
+		nested := parser{state: p.state, file: fileName}
+		if nested.tokens, err = tokenize(string(data)); err != nil {
+			return fmt.Errorf("%s:%w", fileName, err)
+		}
+		_, _, err = nested.parseFile()
+		return err
+	case "schema":
+		var jsonSchema interface{}
+		if err = json.Unmarshal(data, &jsonSchema); err != nil {
+			return p.errorf(kind, "failed to parse json from %s: %v", location, err)
+		}
+		p.state.addJSONTypes(jsonSchema, "")
+		return nil
+	case "protocol":
+		protocol := make(map[string]interface{})
+		if err = json.Unmarshal(data, &protocol); err != nil {
+			return p.errorf(kind, "failed to parse json from %s: %v", location, err)
+		}
+		namespace, _ := protocol["namespace"].(string)
+		if types, ok := protocol["types"].([]interface{}); ok {
+			for _, t := range types {
+				p.state.addJSONTypes(t, namespace)
+			}
+		}
+		if messages, ok := protocol["messages"].(map[string]interface{}); ok {
+			for name, message := range messages {
+				p.state.addMessage(name, message)
+			}
+		}
+		return nil
+	default:
+		return p.errorf(kind, "unknown import kind %s, expected idl, protocol or schema", kind.text)
+	}
+}
+
+func (s *state) addMessage(name string, message interface{}) {
+	if _, exists := s.messages[name]; !exists {
+		s.messageOrder = append(s.messageOrder, name)
+	}
+	s.messages[name] = message
+}
+
+// addJSONTypes adds schema from json as a declared type and remembers all named types in it,
+// named type without namespace gets the namespace of the protocol it is imported from
+func (s *state) addJSONTypes(jsonSchema interface{}, namespace string) {
+	if t, ok := jsonSchema.(map[string]interface{}); ok && namespace != "" {
+		name, _ := t["name"].(string)
+		if _, found := t["namespace"]; !found && name != "" && !strings.Contains(name, ".") {
+			t["namespace"] = namespace
+		}
+	}
+	s.types = append(s.types, jsonSchema)
+	collectNames(jsonSchema, namespace, s.declared)
+}
+
+func collectNames(jsonSchema interface{}, namespace string, names map[string]bool) {
+	switch t := jsonSchema.(type) {
+	case []interface{}:
+		for _, item := range t {
+			collectNames(item, namespace, names)
+		}
+	case map[string]interface{}:
+		typeName, _ := t["type"].(string)
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			var fullName string
+			fullName, namespace = declaredName(t, namespace)
+			names[fullName] = true
+			if fields, ok := t["fields"].([]interface{}); ok {
+				for _, field := range fields {
+					if fieldMap, ok := field.(map[string]interface{}); ok {
+						collectNames(fieldMap["type"], namespace, names)
+					}
+				}
+			}
+		case "array":
+			collectNames(t["items"], namespace, names)
+		case "map":
+			collectNames(t["values"], namespace, names)
+		default:
+			collectNames(t["type"], namespace, names)
+		}
+	}
+}
+
+// declaredName returns full name of json definition of named type and its namespace,
+// namespace is the one of enclosing type
+func declaredName(t map[string]interface{}, namespace string) (string, string) {
+	name, _ := t["name"].(string)
+	if ns, ok := t["namespace"].(string); ok {
+		namespace = ns
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		namespace = name[:idx]
+		name = name[idx+1:]
+	}
+	return qualify(name, namespace), namespace
+}
+
+func qualify(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// namedTypeHeader reads name of the named type and applies annotations to its json definition
+func (p *parser) namedTypeHeader(typeName, doc string, annotations []annotation) (map[string]interface{}, error) {
+	p.next()
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{"type": typeName, "name": name.text, "namespace": p.namespace}
+	if doc != "" {
+		result["doc"] = doc
+	}
+	for _, a := range annotations {
+		result[a.name] = a.value
+	}
+	if idx := strings.LastIndex(name.text, "."); idx >= 0 {
+		result["namespace"] = name.text[:idx]
+		result["name"] = name.text[idx+1:]
+	}
+	fullName := qualify(result["name"].(string), result["namespace"].(string))
+	if p.state.declared[fullName] {
+		return nil, p.errorf(name, "type %s is already defined", fullName)
+	}
+	p.state.declared[fullName] = true
+	return result, nil
+}
+
+func (p *parser) parseRecord(doc string, annotations []annotation) error {
+	typeName := p.peek().text
+	record, err := p.namedTypeHeader(typeName, doc, annotations)
+	if err != nil {
+		return err
+	}
+	namespace := p.namespace
+	p.namespace = record["namespace"].(string)
+	defer func() { p.namespace = namespace }()
+	if err = p.expectPunct("{"); err != nil {
+		return err
+	}
+	fields := make([]interface{}, 0)
+	for !p.isPunct("}") {
+		fieldDoc := p.peek().doc
+		fieldType, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		for {
+			field, err := p.parseVariable(fieldType, fieldDoc)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, field)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		if err = p.expectPunct(";"); err != nil {
+			return err
+		}
+	}
+	p.next()
+	record["fields"] = fields
+	p.state.types = append(p.state.types, record)
+	return nil
+}
+
+// parseVariable reads field name with annotations and optional default value
+func (p *parser) parseVariable(fieldType interface{}, doc string) (map[string]interface{}, error) {
+	if varDoc := p.peek().doc; varDoc != "" {
+		doc = varDoc
+	}
+	annotations, err := p.readAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	field := map[string]interface{}{"name": name.text}
+	if doc != "" {
+		field["doc"] = doc
+	}
+	for _, a := range annotations {
+		field[a.name] = a.value
+	}
+	if p.isPunct("=") {
+		p.next()
+		if field["default"], err = p.readJSON(); err != nil {
+			return nil, err
+		}
+	}
+	if optional, ok := fieldType.(optionalType); ok {
+		// optional type with non-null default puts null as second union element
+		if defaultValue, hasDefault := field["default"]; hasDefault && defaultValue != nil {
+			field["type"] = []interface{}{optional.itemType, "null"}
+		} else {
+			field["type"] = []interface{}{"null", optional.itemType}
+		}
+	} else {
+		field["type"] = fieldType
+	}
+	return field, nil
+}
+
+func (p *parser) parseEnum(doc string, annotations []annotation) error {
+	enum, err := p.namedTypeHeader("enum", doc, annotations)
+	if err != nil {
+		return err
+	}
+	if err = p.expectPunct("{"); err != nil {
+		return err
+	}
+	symbols := make([]interface{}, 0)
+	for !p.isPunct("}") {
+		if len(symbols) > 0 {
+			if err = p.expectPunct(","); err != nil {
+				return err
+			}
+		}
+		symbol, err := p.expectIdent()
+		if err != nil {
+			return err
+		}
+		symbols = append(symbols, symbol.text)
+	}
+	p.next()
+	enum["symbols"] = symbols
+	if p.isPunct("=") {
+		p.next()
+		defaultSymbol, err := p.expectIdent()
+		if err != nil {
+			return err
+		}
+		enum["default"] = defaultSymbol.text
+		if err = p.expectPunct(";"); err != nil {
+			return err
+		}
+	}
+	p.state.types = append(p.state.types, enum)
+	return nil
+}
+
+func (p *parser) parseFixed(doc string, annotations []annotation) error {
+	fixed, err := p.namedTypeHeader("fixed", doc, annotations)
+	if err != nil {
+		return err
+	}
+	if err = p.expectPunct("("); err != nil {
+		return err
+	}
+	if fixed["size"], err = p.expectInt(); err != nil {
+		return err
+	}
+	if err = p.expectPunct(")"); err != nil {
+		return err
+	}
+	if err = p.expectPunct(";"); err != nil {
+		return err
+	}
+	p.state.types = append(p.state.types, fixed)
+	return nil
+}
+
+func (p *parser) parseMessage(doc string, annotations []annotation) error {
+	message := make(map[string]interface{})
+	if doc != "" {
+		message["doc"] = doc
+	}
+	for _, a := range annotations {
+		message[a.name] = a.value
+	}
+	if p.isKeyword("void") {
+		p.next()
+		message["response"] = "null"
+	} else {
+		response, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		message["response"] = response
+	}
+	name, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+	if err = p.expectPunct("("); err != nil {
+		return err
+	}
+	request := make([]interface{}, 0)
+	for !p.isPunct(")") {
+		if len(request) > 0 {
+			if err = p.expectPunct(","); err != nil {
+				return err
+			}
+		}
+		paramDoc := p.peek().doc
+		paramType, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		param, err := p.parseVariable(paramType, paramDoc)
+		if err != nil {
+			return err
+		}
+		request = append(request, param)
+	}
+	p.next()
+	message["request"] = request
+	if p.isKeyword("oneway") {
+		p.next()
+		message["one-way"] = true
+	} else if p.isKeyword("throws") {
+		p.next()
+		errors := make([]interface{}, 0)
+		for {
+			errorName, err := p.expectIdent()
+			if err != nil {
+				return err
+			}
+			errors = append(errors, p.typeRef(errorName))
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		message["errors"] = errors
+	}
+	if err = p.expectPunct(";"); err != nil {
+		return err
+	}
+	p.state.addMessage(name.text, message)
+	return nil
+}
+
+// optionalType is the type declared with ? suffix, turned into union with null
+type optionalType struct {
+	itemType interface{}
+}
+
+func (p *parser) parseType() (interface{}, error) {
+	annotations, err := p.readAnnotations()
+	if err != nil {
+		return nil, err
+	}
+	t, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	switch {
+	case t.text == "array" || t.text == "map":
+		if err = p.expectPunct("<"); err != nil {
+			return nil, err
+		}
+		itemType, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectPunct(">"); err != nil {
+			return nil, err
+		}
+		if t.text == "array" {
+			result = map[string]interface{}{"type": "array", "items": unwrapOptional(itemType)}
+		} else {
+			result = map[string]interface{}{"type": "map", "values": unwrapOptional(itemType)}
+		}
+	case t.text == "union":
+		if err = p.expectPunct("{"); err != nil {
+			return nil, err
+		}
+		elements := make([]interface{}, 0)
+		for !p.isPunct("}") {
+			if len(elements) > 0 {
+				if err = p.expectPunct(","); err != nil {
+					return nil, err
+				}
+			}
+			element, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, unwrapOptional(element))
+		}
+		p.next()
+		result = elements
+	case t.text == "decimal":
+		if err = p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		precision, err := p.expectInt()
+		if err != nil {
+			return nil, err
+		}
+		scale := 0
+		if p.isPunct(",") {
+			p.next()
+			if scale, err = p.expectInt(); err != nil {
+				return nil, err
+			}
+		}
+		if err = p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		result = map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": precision, "scale": scale}
+	case primitiveTypes[t.text]:
+		result = t.text
+	case logicalTypes[t.text] != nil:
+		logical := make(map[string]interface{})
+		for key, value := range logicalTypes[t.text] {
+			logical[key] = value
+		}
+		result = logical
+	default:
+		result = p.typeRef(t)
+	}
+	if len(annotations) > 0 {
+		result = annotate(result, annotations)
+	}
+	if p.isPunct("?") {
+		p.next()
+		return optionalType{itemType: result}, nil
+	}
+	return result, nil
+}
+
+func unwrapOptional(t interface{}) interface{} {
+	if optional, ok := t.(optionalType); ok {
+		return []interface{}{"null", optional.itemType}
+	}
+	return t
+}
+
+// annotate adds annotations as properties of the type
+func annotate(t interface{}, annotations []annotation) interface{} {
+	var result map[string]interface{}
+	switch typed := t.(type) {
+	case map[string]interface{}:
+		result = typed
+	case []interface{}:
+		// unions can't have properties
+		return t
+	default:
+		result = map[string]interface{}{"type": t}
+	}
+	for _, a := range annotations {
+		result[a.name] = a.value
+	}
+	return result
+}
+
+// resolveRefs replaces references with full names of declared types. Names without
+// namespace are looked up in namespace of enclosing type and then of the file.
+func (s *state) resolveRefs(value interface{}) (interface{}, error) {
+	switch t := value.(type) {
+	case typeRef:
+		if strings.Contains(t.name, ".") {
+			if s.declared[t.name] {
+				return t.name, nil
+			}
+		} else {
+			for _, namespace := range []string{t.namespace, t.fileNamespace} {
+				if qualified := qualify(t.name, namespace); s.declared[qualified] {
+					return qualified, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%s:%d:%d: unknown type %s", t.file, t.line, t.col, t.name)
+	case optionalType:
+		itemType, err := s.resolveRefs(t.itemType)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{"null", itemType}, nil
+	case []interface{}:
+		for idx, item := range t {
+			resolved, err := s.resolveRefs(item)
+			if err != nil {
+				return nil, err
+			}
+			t[idx] = resolved
+		}
+		return t, nil
+	case map[string]interface{}:
+		for key, item := range t {
+			resolved, err := s.resolveRefs(item)
+			if err != nil {
+				return nil, err
+			}
+			t[key] = resolved
+		}
+		return t, nil
+	default:
+		return value, nil
+	}
+}
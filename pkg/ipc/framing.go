@@ -0,0 +1,62 @@
+package ipc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// BufferSize is maximum size of buffer the message is split to
+	BufferSize = 8192
+
+	maxMessageSize = 1 << 28
+)
+
+// WriteFrames writes message as list of buffers, each prefixed with its big-endian
+// length, terminated with empty buffer
+func WriteFrames(w io.Writer, message []byte) error {
+	header := make([]byte, 4)
+	for len(message) > 0 {
+		size := len(message)
+		if size > BufferSize {
+			size = BufferSize
+		}
+		binary.BigEndian.PutUint32(header, uint32(size))
+		if _, err := w.Write(header); err != nil {
+			return fmt.Errorf("failed to write buffer length: %w", err)
+		}
+		if _, err := w.Write(message[:size]); err != nil {
+			return fmt.Errorf("failed to write buffer: %w", err)
+		}
+		message = message[size:]
+	}
+	binary.BigEndian.PutUint32(header, 0)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write terminating buffer: %w", err)
+	}
+	return nil
+}
+
+// ReadFrames reads list of buffers up to empty buffer and returns their concatenated contents
+func ReadFrames(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	message := make([]byte, 0)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("failed to read buffer length: %w", err)
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size == 0 {
+			return message, nil
+		}
+		if len(message)+int(size) > maxMessageSize {
+			return nil, fmt.Errorf("message is larger than %d bytes", maxMessageSize)
+		}
+		buffer := make([]byte, size)
+		if _, err := io.ReadFull(r, buffer); err != nil {
+			return nil, fmt.Errorf("failed to read buffer of size %d: %w", size, err)
+		}
+		message = append(message, buffer...)
+	}
+}
@@ -0,0 +1,148 @@
+package ipc
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+	"io"
+)
+
+const (
+	MatchBoth   = "BOTH"
+	MatchClient = "CLIENT"
+	MatchNone   = "NONE"
+)
+
+var handshakeRequestSchema, _ = schema.ParseSchemaJSON([]byte(`{
+	"type": "record", "name": "HandshakeRequest", "namespace": "org.apache.avro.ipc",
+	"fields": [
+		{"name": "clientHash", "type": {"type": "fixed", "name": "MD5", "size": 16}},
+		{"name": "clientProtocol", "type": ["null", "string"]},
+		{"name": "serverHash", "type": "MD5"},
+		{"name": "meta", "type": ["null", {"type": "map", "values": "bytes"}]}
+	]
+}`))
+
+var handshakeResponseSchema, _ = schema.ParseSchemaJSON([]byte(`{
+	"type": "record", "name": "HandshakeResponse", "namespace": "org.apache.avro.ipc",
+	"fields": [
+		{"name": "match", "type": {"type": "enum", "name": "HandshakeMatch", "symbols": ["BOTH", "CLIENT", "NONE"]}},
+		{"name": "serverProtocol", "type": ["null", "string"]},
+		{"name": "serverHash", "type": ["null", {"type": "fixed", "name": "MD5", "size": 16}]},
+		{"name": "meta", "type": ["null", {"type": "map", "values": "bytes"}]}
+	]
+}`))
+
+var metaSchema, _ = schema.ParseSchemaJSON([]byte(`{"type": "map", "values": "bytes"}`))
+
+type HandshakeRequest struct {
+	ClientHash [16]byte
+	// ClientProtocol is set only when server doesn't know protocol of the client
+	ClientProtocol *string
+	ServerHash     [16]byte
+	Meta           map[string][]byte
+}
+
+func (h *HandshakeRequest) Write(w io.Writer) error {
+	value := map[string]interface{}{
+		"clientHash":     h.ClientHash[:],
+		"clientProtocol": optionalString(h.ClientProtocol),
+		"serverHash":     h.ServerHash[:],
+		"meta":           optionalMeta(h.Meta),
+	}
+	if err := handshakeRequestSchema.Write(w, value); err != nil {
+		return fmt.Errorf("failed to write handshake request: %w", err)
+	}
+	return nil
+}
+
+func ReadHandshakeRequest(r io.Reader) (*HandshakeRequest, error) {
+	value, err := handshakeRequestSchema.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read handshake request: %w", err)
+	}
+	record := value.(map[string]interface{})
+	result := &HandshakeRequest{Meta: readMeta(record["meta"])}
+	copy(result.ClientHash[:], record["clientHash"].([]byte))
+	copy(result.ServerHash[:], record["serverHash"].([]byte))
+	if clientProtocol, ok := record["clientProtocol"].(string); ok {
+		result.ClientProtocol = &clientProtocol
+	}
+	return result, nil
+}
+
+///////////////////////
+
+type HandshakeResponse struct {
+	Match string
+	// ServerProtocol and ServerHash are set only when client doesn't know protocol of the server
+	ServerProtocol *string
+	ServerHash     *[16]byte
+	Meta           map[string][]byte
+}
+
+func (h *HandshakeResponse) Write(w io.Writer) error {
+	value := map[string]interface{}{
+		"match":          h.Match,
+		"serverProtocol": optionalString(h.ServerProtocol),
+		"serverHash":     nil,
+		"meta":           optionalMeta(h.Meta),
+	}
+	if h.ServerHash != nil {
+		value["serverHash"] = h.ServerHash[:]
+	}
+	if err := handshakeResponseSchema.Write(w, value); err != nil {
+		return fmt.Errorf("failed to write handshake response: %w", err)
+	}
+	return nil
+}
+
+func ReadHandshakeResponse(r io.Reader) (*HandshakeResponse, error) {
+	value, err := handshakeResponseSchema.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read handshake response: %w", err)
+	}
+	record := value.(map[string]interface{})
+	result := &HandshakeResponse{Match: record["match"].(string), Meta: readMeta(record["meta"])}
+	if serverProtocol, ok := record["serverProtocol"].(string); ok {
+		result.ServerProtocol = &serverProtocol
+	}
+	if serverHash, ok := record["serverHash"].([]byte); ok {
+		result.ServerHash = &[16]byte{}
+		copy(result.ServerHash[:], serverHash)
+	}
+	return result, nil
+}
+
+func optionalString(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func optionalMeta(meta map[string][]byte) interface{} {
+	if meta == nil {
+		return nil
+	}
+	return metaValue(meta)
+}
+
+func metaValue(meta map[string][]byte) map[string]interface{} {
+	result := make(map[string]interface{}, len(meta))
+	for key, value := range meta {
+		result[key] = value
+	}
+	return result
+}
+
+func readMeta(value interface{}) map[string][]byte {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	result := make(map[string][]byte, len(values))
+	for key, item := range values {
+		result[key] = item.([]byte)
+	}
+	return result
+}
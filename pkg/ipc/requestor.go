@@ -0,0 +1,192 @@
+package ipc
+
+import (
+	"avroparser/pkg/protocol"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const ContentType = "avro/binary"
+
+// RemoteError is error declared by the message and returned by remote side
+type RemoteError struct {
+	Value interface{}
+}
+
+func (e *RemoteError) Error() string {
+	if message, ok := e.Value.(string); ok {
+		return message
+	}
+	return fmt.Sprintf("remote error %v", e.Value)
+}
+
+// Transceiver sends framed request to the server and returns framed response
+type Transceiver interface {
+	Transceive(request []byte) ([]byte, error)
+}
+
+///////////////////////
+
+type HTTPTransceiver struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPTransceiver(url string, client *http.Client) *HTTPTransceiver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPTransceiver{url: url, client: client}
+}
+
+func (t *HTTPTransceiver) Transceive(request []byte) ([]byte, error) {
+	response, err := t.client.Post(t.url, ContentType, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s: %w", t.url, err)
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", t.url, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request to %s failed with status %s: %s", t.url, response.Status, body)
+	}
+	return body, nil
+}
+
+///////////////////////
+
+// Requestor calls messages of remote protocol. Every request starts with handshake as
+// required for stateless transports, protocol of the server learned in handshake is
+// used to read responses.
+type Requestor struct {
+	local       *protocol.Protocol
+	transceiver Transceiver
+	// Meta is call metadata sent with every request
+	Meta map[string][]byte
+
+	lock         sync.Mutex
+	remote       *protocol.Protocol
+	remoteHash   [16]byte
+	sendProtocol bool
+}
+
+func NewRequestor(local *protocol.Protocol, transceiver Transceiver) *Requestor {
+	return &Requestor{local: local, transceiver: transceiver, remote: local, remoteHash: local.MD5()}
+}
+
+// Request calls message with given parameters and returns its response. Errors declared
+// by the message are returned as *RemoteError.
+func (r *Requestor) Request(messageName string, params map[string]interface{}) (interface{}, error) {
+	message, found := r.local.Message(messageName)
+	if !found {
+		return nil, fmt.Errorf("message %s is not declared in protocol %s", messageName, r.local.FullName())
+	}
+	call := bytes.Buffer{}
+	if err := metaSchema.Write(&call, metaValue(r.Meta)); err != nil {
+		return nil, fmt.Errorf("failed to write call metadata: %w", err)
+	}
+	if err := writeMessageName(&call, messageName); err != nil {
+		return nil, err
+	}
+	if err := message.WriteRequest(&call, params); err != nil {
+		return nil, err
+	}
+
+	// second attempt is made with protocol of the client if server doesn't know it
+	for attempt := 0; attempt < 2; attempt++ {
+		response, remote, err := r.transceive(call.Bytes())
+		if err != nil {
+			return nil, err
+		} else if response == nil {
+			continue
+		}
+		if message.OneWay {
+			return nil, nil
+		}
+		return readCallResponse(response, remote, messageName)
+	}
+	return nil, fmt.Errorf("server doesn't accept protocol %s", r.local.FullName())
+}
+
+// transceive sends handshake with the call and returns reader of call response, nil reader
+// is returned when server doesn't know protocol of the client
+func (r *Requestor) transceive(call []byte) (*bytes.Reader, *protocol.Protocol, error) {
+	r.lock.Lock()
+	request := bytes.Buffer{}
+	handshake := HandshakeRequest{ClientHash: r.local.MD5(), ServerHash: r.remoteHash}
+	if r.sendProtocol {
+		clientProtocol := string(r.local.JSON())
+		handshake.ClientProtocol = &clientProtocol
+	}
+	r.lock.Unlock()
+	if err := handshake.Write(&request); err != nil {
+		return nil, nil, err
+	}
+	request.Write(call)
+	framed := bytes.Buffer{}
+	if err := WriteFrames(&framed, request.Bytes()); err != nil {
+		return nil, nil, err
+	}
+
+	responseData, err := r.transceiver.Transceive(framed.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	message, err := ReadFrames(bytes.NewReader(responseData))
+	if err != nil {
+		return nil, nil, err
+	}
+	response := bytes.NewReader(message)
+	handshakeResponse, err := ReadHandshakeResponse(response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if handshakeResponse.ServerProtocol != nil && handshakeResponse.ServerHash != nil {
+		if r.remote, err = protocol.ParseJSON([]byte(*handshakeResponse.ServerProtocol)); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse server protocol: %w", err)
+		}
+		r.remoteHash = *handshakeResponse.ServerHash
+	}
+	switch handshakeResponse.Match {
+	case MatchNone:
+		r.sendProtocol = true
+		return nil, nil, nil
+	case MatchBoth, MatchClient:
+		return response, r.remote, nil
+	}
+	return nil, nil, fmt.Errorf("unknown handshake match %s", handshakeResponse.Match)
+}
+
+func readCallResponse(response *bytes.Reader, remote *protocol.Protocol, messageName string) (interface{}, error) {
+	message, found := remote.Message(messageName)
+	if !found {
+		return nil, fmt.Errorf("message %s is not declared in server protocol %s", messageName, remote.FullName())
+	}
+	if _, err := metaSchema.Read(response); err != nil {
+		return nil, fmt.Errorf("failed to read response metadata: %w", err)
+	}
+	isError, err := readBoolean(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response error flag: %w", err)
+	}
+	if isError {
+		value, err := message.Errors.Read(response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read error of message %s: %w", messageName, err)
+		}
+		return nil, &RemoteError{Value: value}
+	}
+	value, err := message.Response.Read(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of message %s: %w", messageName, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,238 @@
+package ipc
+
+import (
+	"avroparser/pkg/protocol"
+	"avroparser/pkg/schema"
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Call is single message call received by the server
+type Call struct {
+	Message *protocol.Message
+	Params  map[string]interface{}
+	// Meta is call metadata sent by the client
+	Meta map[string][]byte
+	// ResponseMeta is metadata sent back to the client
+	ResponseMeta map[string][]byte
+}
+
+// Handler handles message call. Returned *RemoteError is sent to the client as error
+// declared by the message, other errors are sent as string errors.
+type Handler func(call *Call) (interface{}, error)
+
+// maxClientProtocols is number of client protocols kept by responder, clients with dropped
+// protocols send them again in the next handshake
+const maxClientProtocols = 256
+
+// Responder serves messages of the protocol. Requests are read with protocol of the client
+// received in handshake, responses are written with protocol of the server.
+type Responder struct {
+	local    *protocol.Protocol
+	handlers map[string]Handler
+
+	lock sync.Mutex
+	// clients are elements of recent list by protocol hash, recent list is ordered from the
+	// most to the least recently used protocol
+	clients map[[16]byte]*list.Element
+	recent  *list.List
+}
+
+func NewResponder(local *protocol.Protocol) *Responder {
+	return &Responder{
+		local:    local,
+		handlers: make(map[string]Handler),
+		clients:  make(map[[16]byte]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Handle sets handler of the message
+func (r *Responder) Handle(messageName string, handler Handler) error {
+	if _, found := r.local.Message(messageName); !found {
+		return fmt.Errorf("message %s is not declared in protocol %s", messageName, r.local.FullName())
+	}
+	r.handlers[messageName] = handler
+	return nil
+}
+
+func (r *Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	request, err := ReadFrames(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response, err := r.Respond(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	if err = WriteFrames(w, response); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// Respond handles unframed request with handshake and returns unframed response
+func (r *Responder) Respond(request []byte) ([]byte, error) {
+	reader := bytes.NewReader(request)
+	handshake, err := ReadHandshakeRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+	client, err := r.clientProtocol(handshake)
+	if err != nil {
+		return nil, err
+	}
+
+	response := bytes.Buffer{}
+	handshakeResponse := HandshakeResponse{Match: MatchBoth}
+	if handshake.ServerHash != r.local.MD5() {
+		serverProtocol, serverHash := string(r.local.JSON()), r.local.MD5()
+		handshakeResponse.ServerProtocol, handshakeResponse.ServerHash = &serverProtocol, &serverHash
+		handshakeResponse.Match = MatchClient
+	}
+	if client == nil {
+		handshakeResponse.Match = MatchNone
+	}
+	if err = handshakeResponse.Write(&response); err != nil {
+		return nil, err
+	}
+	if client == nil || reader.Len() == 0 {
+		// client has to repeat the call with its protocol, or it was handshake only
+		return response.Bytes(), nil
+	}
+
+	call, err := r.readCall(reader, client)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.handle(call)
+	if call.Message.OneWay {
+		return response.Bytes(), nil
+	}
+	if err = r.writeCallResponse(&response, call, result, err); err != nil {
+		return nil, err
+	}
+	return response.Bytes(), nil
+}
+
+// clientProtocol returns protocol of the client, nil is returned if it is not known yet.
+// Protocols are kept by their hash only if it matches the hash sent by the client.
+func (r *Responder) clientProtocol(handshake *HandshakeRequest) (*protocol.Protocol, error) {
+	if handshake.ClientHash == r.local.MD5() {
+		return r.local, nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if element, found := r.clients[handshake.ClientHash]; found {
+		r.recent.MoveToFront(element)
+		return element.Value.(*protocol.Protocol), nil
+	}
+	if handshake.ClientProtocol == nil {
+		return nil, nil
+	}
+	client, err := protocol.ParseJSON([]byte(*handshake.ClientProtocol))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client protocol: %w", err)
+	}
+	if client.MD5() != handshake.ClientHash {
+		return nil, fmt.Errorf("client hash %x doesn't match hash %x of client protocol", handshake.ClientHash, client.MD5())
+	}
+	r.clients[handshake.ClientHash] = r.recent.PushFront(client)
+	if r.recent.Len() > maxClientProtocols {
+		oldest := r.recent.Remove(r.recent.Back()).(*protocol.Protocol)
+		delete(r.clients, oldest.MD5())
+	}
+	return client, nil
+}
+
+func (r *Responder) readCall(reader io.Reader, client *protocol.Protocol) (*Call, error) {
+	meta, err := metaSchema.Read(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read call metadata: %w", err)
+	}
+	messageName, err := schema.AvroString{}.Read(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message name: %w", err)
+	}
+	clientMessage, found := client.Message(messageName.(string))
+	if !found {
+		return nil, fmt.Errorf("message %s is not declared in client protocol %s", messageName, client.FullName())
+	}
+	localMessage, found := r.local.Message(messageName.(string))
+	if !found {
+		return nil, fmt.Errorf("message %s is not declared in protocol %s", messageName, r.local.FullName())
+	}
+	params, err := clientMessage.ReadRequest(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Call{Message: localMessage, Params: params, Meta: readMeta(meta)}, nil
+}
+
+func (r *Responder) handle(call *Call) (interface{}, error) {
+	handler, found := r.handlers[call.Message.Name]
+	if !found {
+		return nil, fmt.Errorf("message %s is not implemented", call.Message.Name)
+	}
+	return handler(call)
+}
+
+func (r *Responder) writeCallResponse(w io.Writer, call *Call, result interface{}, callErr error) error {
+	if err := metaSchema.Write(w, metaValue(call.ResponseMeta)); err != nil {
+		return fmt.Errorf("failed to write response metadata: %w", err)
+	}
+	if callErr == nil {
+		if err := writeBoolean(w, false); err != nil {
+			return err
+		}
+		if err := call.Message.Response.Write(w, result); err != nil {
+			return fmt.Errorf("failed to write response of message %s: %w", call.Message.Name, err)
+		}
+		return nil
+	}
+	if err := writeBoolean(w, true); err != nil {
+		return err
+	}
+	var errorValue interface{} = callErr.Error()
+	var remoteErr *RemoteError
+	if errors.As(callErr, &remoteErr) {
+		errorValue = remoteErr.Value
+	}
+	if err := call.Message.Errors.Write(w, errorValue); err != nil {
+		return fmt.Errorf("failed to write error of message %s: %w", call.Message.Name, err)
+	}
+	return nil
+}
+
+func readBoolean(r io.Reader) (bool, error) {
+	value, err := schema.AvroBoolean{}.Read(r)
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+func writeBoolean(w io.Writer, value bool) error {
+	if err := (schema.AvroBoolean{}).Write(w, value); err != nil {
+		return fmt.Errorf("failed to write error flag: %w", err)
+	}
+	return nil
+}
+
+func writeMessageName(w io.Writer, value string) error {
+	if err := (schema.AvroString{}).Write(w, value); err != nil {
+		return fmt.Errorf("failed to write message name: %w", err)
+	}
+	return nil
+}
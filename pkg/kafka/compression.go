@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"avroparser/pkg/lz4"
+	"avroparser/pkg/snappy"
+	"avroparser/pkg/zstd"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+)
+
+type Compression int16
+
+const (
+	CompressionNone   Compression = 0
+	CompressionGzip   Compression = 1
+	CompressionSnappy Compression = 2
+	CompressionLz4    Compression = 3
+	CompressionZstd   Compression = 4
+)
+
+// xerialMagic starts snappy data framed by xerial snappy-java used by kafka clients
+var xerialMagic = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionLz4:
+		return "lz4"
+	case CompressionZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("compression(%d)", int16(c))
+}
+
+func (c Compression) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(reader)
+	case CompressionSnappy:
+		return decodeXerialSnappy(data)
+	case CompressionLz4:
+		return lz4.DecodeFrames(data)
+	case CompressionZstd:
+		return zstd.Decode(data)
+	}
+	return nil, fmt.Errorf("unknown compression %d", int16(c))
+}
+
+// decodeXerialSnappy decodes xerial framing of 16-byte header and blocks prefixed with
+// big-endian length, data without the header is decoded as single snappy block
+func decodeXerialSnappy(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, xerialMagic) {
+		return snappy.Decode(data)
+	}
+	if len(data) < 16 {
+		return nil, fmt.Errorf("xerial snappy header is truncated")
+	}
+	result := make([]byte, 0, len(data)*2)
+	for data = data[16:]; len(data) > 0; {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("xerial snappy block length is truncated")
+		}
+		size := binary.BigEndian.Uint32(data)
+		if uint32(len(data)-4) < size {
+			return nil, fmt.Errorf("xerial snappy block of length %d is truncated", size)
+		}
+		block, err := snappy.Decode(data[4 : 4+size])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, block...)
+		data = data[4+size:]
+	}
+	return result, nil
+}
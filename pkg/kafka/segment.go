@@ -0,0 +1,269 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// batchOverhead is size of batch offset and length fields preceding the batch length
+	batchOverhead = 12
+	// batchHeaderSize is size of batch fields from partition leader epoch to records count
+	batchHeaderSize = 49
+
+	attributeCompressionMask = 0x07
+	attributeTransactional   = 0x10
+	attributeControl         = 0x20
+
+	maxBatchSize = 1 << 30
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+type Record struct {
+	Offset    int64
+	Timestamp int64
+	// Key and Value are nil for null key and value
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// Batch is record batch of message format v2
+type Batch struct {
+	BaseOffset           int64
+	PartitionLeaderEpoch int32
+	Magic                int8
+	Crc                  uint32
+	Attributes           int16
+	LastOffsetDelta      int32
+	BaseTimestamp        int64
+	MaxTimestamp         int64
+	ProducerId           int64
+	ProducerEpoch        int16
+	BaseSequence         int32
+	Records              []Record
+	// recordOffsets are offsets of records in uncompressed records data
+	recordOffsets []int
+}
+
+func (b *Batch) Compression() Compression {
+	return Compression(b.Attributes & attributeCompressionMask)
+}
+
+func (b *Batch) IsTransactional() bool {
+	return b.Attributes&attributeTransactional != 0
+}
+
+// IsControl reports whether the batch contains transaction markers instead of data records
+func (b *Batch) IsControl() bool {
+	return b.Attributes&attributeControl != 0
+}
+
+///////////////////////
+
+// SegmentReader reads record batches from kafka log segment file
+type SegmentReader struct {
+	r      io.Reader
+	offset int64
+	batch  *Batch
+	// batchOffset is segment offset of the current batch
+	batchOffset int64
+	record      int
+}
+
+func NewSegmentReader(r io.Reader) *SegmentReader {
+	return &SegmentReader{r: r}
+}
+
+// Next returns next data record, records of control batches are skipped.
+// io.EOF is returned at the end of the segment.
+func (s *SegmentReader) Next() (*Record, error) {
+	for s.batch == nil || s.record == len(s.batch.Records) {
+		batchOffset := s.offset
+		batch, err := s.NextBatch()
+		if err != nil {
+			return nil, err
+		}
+		if !batch.IsControl() {
+			s.batch, s.batchOffset, s.record = batch, batchOffset, 0
+		}
+	}
+	s.record++
+	return &s.batch.Records[s.record-1], nil
+}
+
+// Position returns segment offset of the record to be returned by Next. Records of
+// compressed batches and records of batches that are not read yet have offset of their
+// batch.
+func (s *SegmentReader) Position() int64 {
+	if s.batch == nil || s.record == len(s.batch.Records) {
+		return s.offset
+	}
+	if s.batch.Compression() != CompressionNone {
+		return s.batchOffset
+	}
+	return s.batchOffset + batchOverhead + batchHeaderSize + int64(s.batch.recordOffsets[s.record])
+}
+
+// NextBatch reads and decompresses next batch, io.EOF is returned at the end of the segment
+func (s *SegmentReader) NextBatch() (*Batch, error) {
+	batchOffset := s.offset
+	prefix := make([]byte, batchOverhead)
+	if n, err := io.ReadFull(s.r, prefix); err != nil {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read batch at segment offset %d: %w", batchOffset, err)
+	}
+	length := int32(binary.BigEndian.Uint32(prefix[8:]))
+	if length == 0 {
+		// rest of preallocated segment file is filled with zeros
+		return nil, io.EOF
+	}
+	if length < batchHeaderSize || length > maxBatchSize {
+		return nil, fmt.Errorf("invalid batch length %d at segment offset %d", length, batchOffset)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return nil, fmt.Errorf("failed to read batch of length %d at segment offset %d: %w", length, batchOffset, err)
+	}
+	s.offset += batchOverhead + int64(length)
+	batch, err := parseBatch(int64(binary.BigEndian.Uint64(prefix)), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse batch at segment offset %d: %w", batchOffset, err)
+	}
+	return batch, nil
+}
+
+func parseBatch(baseOffset int64, data []byte) (*Batch, error) {
+	batch := &Batch{BaseOffset: baseOffset}
+	batch.PartitionLeaderEpoch = int32(binary.BigEndian.Uint32(data))
+	batch.Magic = int8(data[4])
+	if batch.Magic != 2 {
+		return nil, fmt.Errorf("message format with magic %d is not supported, only record batches v2 are", batch.Magic)
+	}
+	batch.Crc = binary.BigEndian.Uint32(data[5:])
+	if crc := crc32.Checksum(data[9:], crc32c); crc != batch.Crc {
+		return nil, fmt.Errorf("batch crc %08x doesn't match computed crc %08x", batch.Crc, crc)
+	}
+	batch.Attributes = int16(binary.BigEndian.Uint16(data[9:]))
+	batch.LastOffsetDelta = int32(binary.BigEndian.Uint32(data[11:]))
+	batch.BaseTimestamp = int64(binary.BigEndian.Uint64(data[15:]))
+	batch.MaxTimestamp = int64(binary.BigEndian.Uint64(data[23:]))
+	batch.ProducerId = int64(binary.BigEndian.Uint64(data[31:]))
+	batch.ProducerEpoch = int16(binary.BigEndian.Uint16(data[39:]))
+	batch.BaseSequence = int32(binary.BigEndian.Uint32(data[41:]))
+	count := int32(binary.BigEndian.Uint32(data[45:]))
+	if count < 0 {
+		return nil, fmt.Errorf("negative number of records %d", count)
+	}
+
+	records, err := batch.Compression().decompress(data[batchHeaderSize:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress records with %s: %w", batch.Compression(), err)
+	}
+	reader := bytes.NewReader(records)
+	// every record takes at least a byte, so count of corrupt batch doesn't preallocate more
+	capacity := int(count)
+	if capacity > len(records) {
+		capacity = len(records)
+	}
+	batch.Records = make([]Record, 0, capacity)
+	batch.recordOffsets = make([]int, 0, capacity)
+	for idx := int32(0); idx < count; idx++ {
+		batch.recordOffsets = append(batch.recordOffsets, len(records)-reader.Len())
+		record, err := readRecord(reader, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record %d of %d: %w", idx, count, err)
+		}
+		batch.Records = append(batch.Records, record)
+	}
+	return batch, nil
+}
+
+func readRecord(reader *bytes.Reader, batch *Batch) (Record, error) {
+	length, err := binary.ReadVarint(reader)
+	if err != nil {
+		return Record{}, err
+	}
+	if length < 0 || length > int64(reader.Len()) {
+		return Record{}, fmt.Errorf("invalid record length %d", length)
+	}
+	data := make([]byte, length)
+	if _, err = io.ReadFull(reader, data); err != nil {
+		return Record{}, err
+	}
+	r := bytes.NewReader(data)
+
+	record := Record{}
+	// record attributes are unused
+	if _, err = r.ReadByte(); err != nil {
+		return Record{}, err
+	}
+	timestampDelta, err := binary.ReadVarint(r)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read timestamp delta: %w", err)
+	}
+	record.Timestamp = batch.BaseTimestamp + timestampDelta
+	offsetDelta, err := binary.ReadVarint(r)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read offset delta: %w", err)
+	}
+	record.Offset = batch.BaseOffset + offsetDelta
+	if record.Key, err = readVarBytes(r); err != nil {
+		return Record{}, fmt.Errorf("failed to read key: %w", err)
+	}
+	if record.Value, err = readVarBytes(r); err != nil {
+		return Record{}, fmt.Errorf("failed to read value: %w", err)
+	}
+	headersCount, err := binary.ReadVarint(r)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read headers count: %w", err)
+	}
+	if headersCount < 0 || headersCount > int64(r.Len()) {
+		return Record{}, fmt.Errorf("invalid headers count %d", headersCount)
+	}
+	for idx := int64(0); idx < headersCount; idx++ {
+		key, err := readVarBytes(r)
+		if err != nil || key == nil {
+			return Record{}, fmt.Errorf("failed to read key of header %d", idx)
+		}
+		value, err := readVarBytes(r)
+		if err != nil {
+			return Record{}, fmt.Errorf("failed to read value of header %s: %w", key, err)
+		}
+		record.Headers = append(record.Headers, Header{Key: string(key), Value: value})
+	}
+	if r.Len() != 0 {
+		return Record{}, fmt.Errorf("%d bytes left in record after decoding", r.Len())
+	}
+	return record, nil
+}
+
+// readVarBytes reads bytes prefixed with varint length, -1 length stands for null
+func readVarBytes(r *bytes.Reader) ([]byte, error) {
+	length, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length == -1 {
+		return nil, nil
+	}
+	if length < -1 || length > int64(r.Len()) {
+		return nil, fmt.Errorf("invalid length %d", length)
+	}
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
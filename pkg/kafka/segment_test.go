@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/segment.log has a batch of 3 records for each compression in order of their
+// codes, records are compressed by compress/gzip, github.com/golang/snappy in xerial
+// framing, lz4 1.9 cli with linked blocks and zstd 1.5 cli
+func readFile(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSegmentReader(t *testing.T) {
+	reader := NewSegmentReader(bytes.NewReader(readFile(t, "segment.log")))
+	for codec := CompressionNone; codec <= CompressionZstd; codec++ {
+		batch, err := reader.NextBatch()
+		if err != nil {
+			t.Fatalf("batch %s: %v", codec, err)
+		}
+		if batch.Compression() != codec || batch.BaseOffset != int64(codec)*3 || len(batch.Records) != 3 {
+			t.Fatalf("unexpected batch %+v", batch)
+		}
+		for idx, record := range batch.Records {
+			if record.Offset != batch.BaseOffset+int64(idx) || record.Timestamp != batch.BaseTimestamp+int64(idx)*10 {
+				t.Errorf("batch %s: unexpected offset %d or timestamp %d of record %d", codec, record.Offset, record.Timestamp, idx)
+			}
+			key := fmt.Sprintf("key-%d-%d", codec, idx)
+			if idx == 1 && record.Key != nil || idx != 1 && string(record.Key) != key {
+				t.Errorf("batch %s: unexpected key %q of record %d", codec, record.Key, idx)
+			}
+			value := fmt.Sprintf("value %d of batch %d: %s", idx, codec, bytes.Repeat([]byte("abc"), 20))
+			if string(record.Value) != value {
+				t.Errorf("batch %s: unexpected value %q of record %d", codec, record.Value, idx)
+			}
+			if len(record.Headers) != 1 || record.Headers[0].Key != "codec" || string(record.Headers[0].Value) != fmt.Sprint(int(codec)) {
+				t.Errorf("batch %s: unexpected headers %v of record %d", codec, record.Headers, idx)
+			}
+		}
+	}
+	if _, err := reader.NextBatch(); err != io.EOF {
+		t.Fatalf("expected end of segment, got %v", err)
+	}
+}
+
+func TestSegmentReaderPosition(t *testing.T) {
+	data := readFile(t, "segment.log")
+	reader := NewSegmentReader(bytes.NewReader(data))
+	batchOffset := int64(0)
+	for codec := CompressionNone; codec <= CompressionZstd; codec++ {
+		for idx := 0; idx < 3; idx++ {
+			position := reader.Position()
+			record, err := reader.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx == 0 {
+				// batch is read by Next, so its first record has offset of the batch
+				batchOffset = position
+				continue
+			}
+			if codec != CompressionNone {
+				if position != batchOffset {
+					t.Errorf("batch %s: expected position %d of record %d, got %d", codec, batchOffset, idx, position)
+				}
+				continue
+			}
+			// records of uncompressed batch are read at their positions
+			if read, err := readRecord(bytes.NewReader(data[position:]), reader.batch); err != nil || read.Offset != record.Offset {
+				t.Errorf("expected record %d at position %d, got %+v, %v", record.Offset, position, read, err)
+			}
+		}
+		if position := reader.Position(); position != reader.offset {
+			t.Errorf("batch %s: expected position %d after last record, got %d", codec, reader.offset, position)
+		}
+	}
+	if position := reader.Position(); position != int64(len(data)) {
+		t.Fatalf("expected position at the end of segment, got %d", position)
+	}
+}
+
+func TestSegmentReaderCorrupt(t *testing.T) {
+	data := readFile(t, "segment.log")
+	corrupt := append([]byte{}, data...)
+	// first byte of records of the first batch
+	corrupt[12+49] ^= 0xff
+	if _, err := NewSegmentReader(bytes.NewReader(corrupt)).Next(); err == nil {
+		t.Error("expected crc error")
+	}
+	reader := NewSegmentReader(bytes.NewReader(data[:len(data)-10]))
+	var err error
+	for err == nil {
+		_, err = reader.Next()
+	}
+	if err == io.EOF {
+		t.Error("expected error of truncated batch")
+	}
+}
+
+// TestXerialSnappy decodes testdata/sample.jsonl in xerial framing with 2 KiB blocks
+func TestXerialSnappy(t *testing.T) {
+	decoded, err := CompressionSnappy.decompress(readFile(t, "xerial.snappy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample := readFile(t, "sample.jsonl"); !bytes.Equal(decoded, sample) {
+		t.Fatalf("decoded %d bytes don't match %d bytes of sample", len(decoded), len(sample))
+	}
+}
+
+func FuzzSegmentReader(f *testing.F) {
+	f.Add(readFile(f, "segment.log"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := NewSegmentReader(bytes.NewReader(data))
+		for {
+			if _, err := reader.Next(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func FuzzDecompress(f *testing.F) {
+	f.Add(int16(CompressionSnappy), readFile(f, "xerial.snappy"))
+	f.Fuzz(func(t *testing.T, codec int16, data []byte) {
+		_, _ = Compression(codec).decompress(data)
+	})
+}
@@ -0,0 +1,174 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	frameMagic         = 0x184D2204
+	skippableMagicMask = 0xFFFFFFF0
+	skippableMagic     = 0x184D2A50
+
+	flagBlockChecksum   = 0x10
+	flagContentSize     = 0x08
+	flagContentChecksum = 0x04
+	flagDictId          = 0x01
+
+	uncompressedBlockBit = 0x80000000
+	minMatch             = 4
+)
+
+var errCorrupt = errors.New("lz4: corrupt input")
+
+// DecodeFrames decodes concatenated lz4 frames, skippable frames are ignored. Checksums
+// are not verified.
+func DecodeFrames(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(src)*3)
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errCorrupt
+		}
+		magic := binary.LittleEndian.Uint32(src)
+		if magic&skippableMagicMask == skippableMagic {
+			if len(src) < 8 {
+				return nil, errCorrupt
+			}
+			size := int(binary.LittleEndian.Uint32(src[4:]))
+			if len(src) < 8+size {
+				return nil, errCorrupt
+			}
+			src = src[8+size:]
+			continue
+		}
+		if magic != frameMagic {
+			return nil, fmt.Errorf("lz4: unknown frame magic %x", magic)
+		}
+		var err error
+		if dst, src, err = decodeFrame(dst, src[4:]); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func decodeFrame(dst, src []byte) ([]byte, []byte, error) {
+	if len(src) < 3 {
+		return nil, nil, errCorrupt
+	}
+	flags := src[0]
+	if flags>>6 != 1 {
+		return nil, nil, fmt.Errorf("lz4: unsupported frame version %d", flags>>6)
+	}
+	// flags, block descriptor and header checksum
+	headerSize := 3
+	if flags&flagContentSize != 0 {
+		headerSize += 8
+	}
+	if flags&flagDictId != 0 {
+		headerSize += 4
+	}
+	if len(src) < headerSize {
+		return nil, nil, errCorrupt
+	}
+	src = src[headerSize:]
+	// blocks may reference data of previous blocks, so all are decoded into dst
+	frameStart := len(dst)
+	for {
+		if len(src) < 4 {
+			return nil, nil, errCorrupt
+		}
+		size := binary.LittleEndian.Uint32(src)
+		src = src[4:]
+		if size == 0 {
+			break
+		}
+		compressed := size&uncompressedBlockBit == 0
+		size &^= uncompressedBlockBit
+		if uint32(len(src)) < size {
+			return nil, nil, errCorrupt
+		}
+		if compressed {
+			var err error
+			if dst, err = decodeBlock(dst, src[:size], frameStart); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			dst = append(dst, src[:size]...)
+		}
+		src = src[size:]
+		if flags&flagBlockChecksum != 0 {
+			if len(src) < 4 {
+				return nil, nil, errCorrupt
+			}
+			src = src[4:]
+		}
+	}
+	if flags&flagContentChecksum != 0 {
+		if len(src) < 4 {
+			return nil, nil, errCorrupt
+		}
+		src = src[4:]
+	}
+	return dst, src, nil
+}
+
+// decodeBlock appends decoded block to dst, matches may reference data after frameStart
+func decodeBlock(dst, src []byte, frameStart int) ([]byte, error) {
+	for s := 0; s < len(src); {
+		token := src[s]
+		s++
+		literals := int(token >> 4)
+		if literals == 15 {
+			for {
+				if s >= len(src) {
+					return nil, errCorrupt
+				}
+				literals += int(src[s])
+				s++
+				if src[s-1] != 255 {
+					break
+				}
+			}
+		}
+		if len(src)-s < literals {
+			return nil, errCorrupt
+		}
+		dst = append(dst, src[s:s+literals]...)
+		s += literals
+		if s == len(src) {
+			// last sequence has literals only
+			break
+		}
+
+		if len(src)-s < 2 {
+			return nil, errCorrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[s:]))
+		s += 2
+		length := int(token & 0x0f)
+		if length == 15 {
+			for {
+				if s >= len(src) {
+					return nil, errCorrupt
+				}
+				length += int(src[s])
+				s++
+				if src[s-1] != 255 {
+					break
+				}
+			}
+		}
+		length += minMatch
+		if offset == 0 || offset > len(dst)-frameStart {
+			return nil, errCorrupt
+		}
+		// match may overlap with bytes being copied
+		for start := len(dst) - offset; length > 0; length-- {
+			dst = append(dst, dst[start])
+			start++
+		}
+	}
+	return dst, nil
+}
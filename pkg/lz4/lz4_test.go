@@ -0,0 +1,70 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Fixtures are testdata/sample.jsonl compressed by lz4 1.9 cli into 1 KiB blocks:
+//
+//	lz4 -B1024 -BD --content-size sample.jsonl linked.lz4
+//	lz4 -B1024 -BI sample.jsonl independent.lz4
+//	lz4 -B1024 -BD -BX sample.jsonl checksum.lz4
+var fixtures = []string{"linked.lz4", "independent.lz4", "checksum.lz4"}
+
+func readFile(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeFrames(t *testing.T) {
+	sample := readFile(t, "sample.jsonl")
+	for _, name := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			decoded, err := DecodeFrames(readFile(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, sample) {
+				t.Fatalf("decoded %d bytes don't match %d bytes of sample", len(decoded), len(sample))
+			}
+		})
+	}
+	t.Run("concatenated", func(t *testing.T) {
+		decoded, err := DecodeFrames(append(readFile(t, "linked.lz4"), readFile(t, "independent.lz4")...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, append(append([]byte{}, sample...), sample...)) {
+			t.Fatalf("decoded %d bytes don't match two samples", len(decoded))
+		}
+	})
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	data := readFile(t, "checksum.lz4")
+	if _, err := DecodeFrames(data[:len(data)/2]); err == nil {
+		t.Error("expected error of truncated frame")
+	}
+	if _, err := DecodeFrames(data[:len(data)-2]); err == nil {
+		t.Error("expected error of truncated checksum")
+	}
+	if _, err := DecodeFrames([]byte("not lz4")); err == nil {
+		t.Error("expected error of unknown magic")
+	}
+}
+
+func FuzzDecodeFrames(f *testing.F) {
+	for _, name := range fixtures {
+		f.Add(readFile(f, name))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = DecodeFrames(data)
+	})
+}
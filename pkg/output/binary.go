@@ -0,0 +1,162 @@
+package output
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// binaryEncoder writes values of one of binary formats similar to json
+type binaryEncoder interface {
+	null()
+	boolean(value bool)
+	int32(value int32)
+	int64(value int64)
+	float32(value float32)
+	float64(value float64)
+	string(value string)
+	bytes(value []byte)
+	arrayHeader(size int)
+	mapHeader(size int)
+}
+
+// binaryWriter writes records one after another as values of binary format. Ints and
+// longs, floats and doubles are written with their widths, bytes are written as binary
+// data. Records and maps are written as maps keyed by field names, unions as their values.
+type binaryWriter struct {
+	w       io.Writer
+	buffer  *bytes.Buffer
+	encoder binaryEncoder
+}
+
+func (b *binaryWriter) Begin(Record) error {
+	return nil
+}
+
+func (b *binaryWriter) Record(record Record) error {
+	b.buffer.Reset()
+	if len(record) == 1 && record[0].Name == "" {
+		if err := b.encode(record[0].Schema, record[0].Value); err != nil {
+			return err
+		}
+	} else {
+		b.encoder.mapHeader(len(record))
+		for _, field := range record {
+			b.encoder.string(field.Name)
+			if err := b.encode(field.Schema, field.Value); err != nil {
+				return fmt.Errorf("failed to write %s: %w", field.Name, err)
+			}
+		}
+	}
+	_, err := b.w.Write(b.buffer.Bytes())
+	return err
+}
+
+func (b *binaryWriter) End() error {
+	return nil
+}
+
+// encode writes value with the schema, values without schema are written by their go types
+func (b *binaryWriter) encode(s schema.ItemSchema, value interface{}) error {
+	switch t := s.(type) {
+	case schema.AvroUnion:
+		if value == nil {
+			b.encoder.null()
+			return nil
+		}
+		idx, value := t.Branch(value)
+		if idx < 0 {
+			return fmt.Errorf("value %v doesn't match any union element", value)
+		}
+		return b.encode(t.Elements()[idx], value)
+	case schema.AvroRecord:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not a record %s", value, t.FullName())
+		}
+		b.encoder.mapHeader(len(t.Fields()))
+		for _, f := range t.Fields() {
+			b.encoder.string(f.Name())
+			if err := b.encode(f.Type(), m[f.Name()]); err != nil {
+				return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+			}
+		}
+		return nil
+	case schema.AvroArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not an array", value)
+		}
+		return b.encodeArray(t.Items(), items)
+	case schema.AvroMap:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not a map", value)
+		}
+		return b.encodeMap(t.Values(), m)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		b.encoder.null()
+	case bool:
+		b.encoder.boolean(v)
+	case string:
+		b.encoder.string(v)
+	case []byte:
+		b.encoder.bytes(v)
+	case int32:
+		b.encoder.int32(v)
+	case int64:
+		b.encoder.int64(v)
+	case float32:
+		b.encoder.float32(v)
+	case float64:
+		b.encoder.float64(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			b.encoder.int64(i)
+		} else if f, err := v.Float64(); err == nil {
+			b.encoder.float64(f)
+		} else {
+			return fmt.Errorf("invalid number %s", v)
+		}
+	case []interface{}:
+		return b.encodeArray(nil, v)
+	case map[string]interface{}:
+		return b.encodeMap(nil, v)
+	default:
+		return fmt.Errorf("value %v of type %T can't be written", value, value)
+	}
+	return nil
+}
+
+func (b *binaryWriter) encodeArray(items schema.ItemSchema, values []interface{}) error {
+	b.encoder.arrayHeader(len(values))
+	for idx, item := range values {
+		if err := b.encode(items, item); err != nil {
+			return fmt.Errorf("failed to write item at idx %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+func (b *binaryWriter) encodeMap(values schema.ItemSchema, m map[string]interface{}) error {
+	b.encoder.mapHeader(len(m))
+	for _, key := range sortedKeys(m) {
+		b.encoder.string(key)
+		if err := b.encode(values, m[key]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// newBinaryWriter creates writer with encoder writing to the buffer of a record, so that
+// records failed to encode are not written partially
+func newBinaryWriter(w io.Writer, newEncoder func(buffer *bytes.Buffer) binaryEncoder) *binaryWriter {
+	buffer := &bytes.Buffer{}
+	return &binaryWriter{w: w, buffer: buffer, encoder: newEncoder(buffer)}
+}
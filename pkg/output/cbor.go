@@ -0,0 +1,124 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+const (
+	cborUnsigned = 0 << 5
+	cborNegative = 1 << 5
+	cborBytes    = 2 << 5
+	cborText     = 3 << 5
+	cborArray    = 4 << 5
+	cborMap      = 5 << 5
+	cborSimple   = 7 << 5
+)
+
+// NewCBORWriter writes records as a CBOR sequence, ints and longs are written with 32 and
+// 64-bit arguments and floats and doubles as single and double precision floats
+func NewCBORWriter(w io.Writer) Writer {
+	return newBinaryWriter(w, func(buffer *bytes.Buffer) binaryEncoder {
+		return &cborEncoder{w: buffer}
+	})
+}
+
+type cborEncoder struct {
+	w   *bytes.Buffer
+	buf [9]byte
+}
+
+// head writes major type with argument of given size, sizes 0 to 8 are encoded with
+// additional information 24 to 27 and size 0 stands for argument in the initial byte
+func (c *cborEncoder) head(major byte, argument uint64, size int) {
+	switch size {
+	case 0:
+		c.buf[0] = major | byte(argument)
+	case 1:
+		c.buf[0] = major | 24
+		c.buf[1] = byte(argument)
+	case 2:
+		c.buf[0] = major | 25
+		binary.BigEndian.PutUint16(c.buf[1:], uint16(argument))
+	case 4:
+		c.buf[0] = major | 26
+		binary.BigEndian.PutUint32(c.buf[1:], uint32(argument))
+	case 8:
+		c.buf[0] = major | 27
+		binary.BigEndian.PutUint64(c.buf[1:], argument)
+	}
+	c.w.Write(c.buf[:1+size])
+}
+
+// length writes length argument in the shortest form
+func (c *cborEncoder) length(major byte, length int) {
+	switch {
+	case length < 24:
+		c.head(major, uint64(length), 0)
+	case length <= math.MaxUint8:
+		c.head(major, uint64(length), 1)
+	case length <= math.MaxUint16:
+		c.head(major, uint64(length), 2)
+	case length <= math.MaxUint32:
+		c.head(major, uint64(length), 4)
+	default:
+		c.head(major, uint64(length), 8)
+	}
+}
+
+// integer writes value with argument of given size, negative n is encoded as -1-n
+func (c *cborEncoder) integer(value int64, size int) {
+	if value < 0 {
+		c.head(cborNegative, uint64(-1-value), size)
+	} else {
+		c.head(cborUnsigned, uint64(value), size)
+	}
+}
+
+func (c *cborEncoder) null() {
+	c.w.WriteByte(cborSimple | 22)
+}
+
+func (c *cborEncoder) boolean(value bool) {
+	if value {
+		c.w.WriteByte(cborSimple | 21)
+	} else {
+		c.w.WriteByte(cborSimple | 20)
+	}
+}
+
+func (c *cborEncoder) int32(value int32) {
+	c.integer(int64(value), 4)
+}
+
+func (c *cborEncoder) int64(value int64) {
+	c.integer(value, 8)
+}
+
+func (c *cborEncoder) float32(value float32) {
+	c.head(cborSimple, uint64(math.Float32bits(value)), 4)
+}
+
+func (c *cborEncoder) float64(value float64) {
+	c.head(cborSimple, math.Float64bits(value), 8)
+}
+
+func (c *cborEncoder) string(value string) {
+	c.length(cborText, len(value))
+	c.w.WriteString(value)
+}
+
+func (c *cborEncoder) bytes(value []byte) {
+	c.length(cborBytes, len(value))
+	c.w.Write(value)
+}
+
+func (c *cborEncoder) arrayHeader(size int) {
+	c.length(cborArray, size)
+}
+
+func (c *cborEncoder) mapHeader(size int) {
+	c.length(cborMap, size)
+}
@@ -0,0 +1,334 @@
+package output
+
+import (
+	"avroparser/pkg/schema"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DefaultCSVNull is written for nulls by default, so that they differ from empty strings
+const DefaultCSVNull = `\N`
+
+type CSVOptions struct {
+	// Delimiter separates columns, ',' is used if it is not set
+	Delimiter rune
+	// Null is written for null values and missing columns
+	Null string
+	// Explode writes a row for each item of arrays and each entry of maps, columns of
+	// other fields are repeated. Rows are a cartesian product if a record has several
+	// arrays or maps. Arrays and maps are written as json otherwise.
+	Explode bool
+}
+
+// CSVWriter writes records as rows with header derived from schema of the header given to
+// Begin, or of the first record if it is not known. Nested records are flattened into
+// columns named by dotted path of the fields. Records of other schemas are written to the
+// columns of the header by names, their missing columns are null and records with columns
+// that aren't in the header fail.
+type CSVWriter struct {
+	w       *csv.Writer
+	options CSVOptions
+	// columns are indexes of header columns by their names
+	columns map[string]int
+	header  []string
+	json    *JSONWriter
+}
+
+func NewCSVWriter(w io.Writer, options CSVOptions) *CSVWriter {
+	csvWriter := csv.NewWriter(w)
+	if options.Delimiter != 0 {
+		csvWriter.Comma = options.Delimiter
+	}
+	return &CSVWriter{w: csvWriter, options: options, json: NewJSONWriter(nil, JSONOptions{})}
+}
+
+func (c *CSVWriter) Begin(header Record) error {
+	if header == nil {
+		return nil
+	}
+	return c.writeHeader(c.recordNode(header))
+}
+
+func (c *CSVWriter) Record(record Record) error {
+	node := c.recordNode(record)
+	if c.header == nil {
+		if err := c.writeHeader(node); err != nil {
+			return err
+		}
+	}
+	// columns of records with other schemas are matched to the header by name
+	names := node.columnNames(nil)
+	for _, name := range names {
+		if _, found := c.columns[name]; !found {
+			return fmt.Errorf("column %s is not in csv header, records have different schemas", name)
+		}
+	}
+	var values []interface{}
+	if len(record) == 1 && record[0].Name == "" {
+		values = []interface{}{record[0].Value}
+	} else {
+		values = make([]interface{}, len(record))
+		for idx, field := range record {
+			values[idx] = field.Value
+		}
+	}
+	rows, err := node.rows(c, values)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		line := make([]string, len(c.header))
+		for idx := range line {
+			line[idx] = c.options.Null
+		}
+		for idx, cell := range row {
+			line[c.columns[names[idx]]] = cell
+		}
+		if err = c.w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CSVWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *CSVWriter) writeHeader(node *csvNode) error {
+	c.header = node.columnNames(nil)
+	c.columns = make(map[string]int, len(c.header))
+	for idx, name := range c.header {
+		c.columns[name] = idx
+	}
+	return c.w.Write(c.header)
+}
+
+func (c *CSVWriter) recordNode(record Record) *csvNode {
+	if len(record) == 1 && record[0].Name == "" {
+		child := c.node("", record[0].Schema, nil)
+		if child.kind == csvLeaf {
+			child.name = "value"
+		}
+		return &csvNode{kind: csvRecord, children: []*csvNode{child}}
+	}
+	node := &csvNode{kind: csvRecord}
+	for _, field := range record {
+		node.children = append(node.children, c.node(field.Name, field.Schema, nil))
+	}
+	return node
+}
+
+// node builds columns of the value with the schema, records that are already being
+// flattened are written as json to stop recursion of recursive types
+func (c *CSVWriter) node(name string, s schema.ItemSchema, parents []string) *csvNode {
+	switch t := s.(type) {
+	case schema.AvroRecord:
+		fullName := schema.TypeName(t)
+		for _, parent := range parents {
+			if parent == fullName {
+				return &csvNode{name: name, kind: csvLeaf, schema: s}
+			}
+		}
+		parents = append(parents, fullName)
+		node := &csvNode{name: name, kind: csvRecord, schema: s}
+		for _, f := range t.Fields() {
+			node.children = append(node.children, c.node(joinColumn(name, f.Name()), f.Type(), parents))
+		}
+		return node
+	case schema.AvroArray:
+		if c.options.Explode {
+			return &csvNode{name: name, kind: csvArray, schema: s, children: []*csvNode{c.node(name, t.Items(), parents)}}
+		}
+	case schema.AvroMap:
+		if c.options.Explode {
+			return &csvNode{name: name, kind: csvMap, schema: s, children: []*csvNode{
+				{name: joinColumn(name, "key"), kind: csvLeaf},
+				c.node(joinColumn(name, "value"), t.Values(), parents),
+			}}
+		}
+	case schema.AvroUnion:
+		// optional value is written in columns of its type, null leaves all of them empty
+		var nonNull []schema.ItemSchema
+		for _, element := range t.Elements() {
+			if _, isNull := element.(schema.AvroNull); !isNull {
+				nonNull = append(nonNull, element)
+			}
+		}
+		if len(nonNull) == 1 {
+			if node := c.node(name, nonNull[0], parents); node.kind != csvLeaf {
+				return node
+			}
+		}
+	}
+	return &csvNode{name: name, kind: csvLeaf, schema: s}
+}
+
+// cell formats scalar value, complex values are written as json
+func (c *CSVWriter) cell(s schema.ItemSchema, value interface{}) (string, error) {
+	if union, ok := s.(schema.AvroUnion); ok {
+		if idx, untagged := union.Branch(value); idx >= 0 {
+			s, value = union.Elements()[idx], untagged
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return c.options.Null, nil
+	case string:
+		return v, nil
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	c.json.buffer.Reset()
+	if err := c.json.encode(s, value); err != nil {
+		return "", err
+	}
+	return c.json.buffer.String(), nil
+}
+
+func joinColumn(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+///////////////////////
+
+type csvNodeKind int
+
+const (
+	csvLeaf csvNodeKind = iota
+	csvRecord
+	csvArray
+	csvMap
+)
+
+// csvNode is a column of scalar value or a group of columns of record fields, array
+// items or map entries
+type csvNode struct {
+	name     string
+	kind     csvNodeKind
+	schema   schema.ItemSchema
+	children []*csvNode
+}
+
+func (n *csvNode) columnNames(names []string) []string {
+	if n.kind == csvLeaf {
+		return append(names, n.name)
+	}
+	for _, child := range n.children {
+		names = child.columnNames(names)
+	}
+	return names
+}
+
+func (n *csvNode) width() int {
+	if n.kind == csvLeaf {
+		return 1
+	}
+	width := 0
+	for _, child := range n.children {
+		width += child.width()
+	}
+	return width
+}
+
+// emptyRows returns single row of nulls for null values and empty arrays and maps
+func (n *csvNode) emptyRows(c *CSVWriter) [][]string {
+	row := make([]string, n.width())
+	for idx := range row {
+		row[idx] = c.options.Null
+	}
+	return [][]string{row}
+}
+
+// rows returns cells of the value, values of the top level node are values of record fields
+func (n *csvNode) rows(c *CSVWriter, value interface{}) ([][]string, error) {
+	switch n.kind {
+	case csvLeaf:
+		cell, err := c.cell(n.schema, value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", n.name, err)
+		}
+		return [][]string{{cell}}, nil
+	case csvRecord:
+		var values []interface{}
+		switch v := value.(type) {
+		case nil:
+			return n.emptyRows(c), nil
+		case []interface{}:
+			values = v
+		case map[string]interface{}:
+			values = make([]interface{}, len(n.children))
+			for idx, f := range n.schema.(schema.AvroRecord).Fields() {
+				values[idx] = v[f.Name()]
+			}
+		default:
+			return nil, fmt.Errorf("value %v of %s is not a record", value, n.name)
+		}
+		rows := [][]string{{}}
+		for idx, child := range n.children {
+			childRows, err := child.rows(c, values[idx])
+			if err != nil {
+				return nil, err
+			}
+			rows = product(rows, childRows)
+		}
+		return rows, nil
+	case csvArray:
+		items, _ := value.([]interface{})
+		if len(items) == 0 {
+			return n.emptyRows(c), nil
+		}
+		var rows [][]string
+		for _, item := range items {
+			itemRows, err := n.children[0].rows(c, item)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, itemRows...)
+		}
+		return rows, nil
+	case csvMap:
+		m, _ := value.(map[string]interface{})
+		if len(m) == 0 {
+			return n.emptyRows(c), nil
+		}
+		var rows [][]string
+		for _, key := range sortedKeys(m) {
+			valueRows, err := n.children[1].rows(c, m[key])
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, product([][]string{{key}}, valueRows)...)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unknown column kind %d", n.kind)
+}
+
+func product(left, right [][]string) [][]string {
+	result := make([][]string, 0, len(left)*len(right))
+	for _, l := range left {
+		for _, r := range right {
+			row := make([]string, 0, len(l)+len(r))
+			result = append(result, append(append(row, l...), r...))
+		}
+	}
+	return result
+}
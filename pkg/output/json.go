@@ -0,0 +1,327 @@
+package output
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Field is a named value decoded with the schema, schema is nil for values that are
+// not avro data like message offsets
+type Field struct {
+	Name   string
+	Schema schema.ItemSchema
+	Value  interface{}
+}
+
+// Record is a set of fields written as one output record, single field without name is
+// written as its value
+type Record []Field
+
+type JSONOptions struct {
+	// Array writes all records as single json array instead of one record per line
+	Array  bool
+	Pretty bool
+	// AvroEncoding writes unions as {"type": value} and bytes as ISO-8859-1 strings
+	// as avro json encoding requires, bytes are written as base64 otherwise
+	AvroEncoding   bool
+	LongsAsStrings bool
+	// SortKeys sorts fields of records by name, record fields are written in schema
+	// order otherwise. Keys of maps are always sorted.
+	SortKeys bool
+}
+
+type JSONWriter struct {
+	w       io.Writer
+	options JSONOptions
+	count   int
+	buffer  bytes.Buffer
+}
+
+func NewJSONWriter(w io.Writer, options JSONOptions) *JSONWriter {
+	return &JSONWriter{w: w, options: options}
+}
+
+func (j *JSONWriter) Begin(Record) error {
+	if j.options.Array {
+		_, err := io.WriteString(j.w, "[")
+		return err
+	}
+	return nil
+}
+
+func (j *JSONWriter) Record(record Record) error {
+	j.buffer.Reset()
+	if len(record) == 1 && record[0].Name == "" {
+		if err := j.encode(record[0].Schema, record[0].Value); err != nil {
+			return err
+		}
+	} else if err := j.encodeRecord(record); err != nil {
+		return err
+	}
+	data := j.buffer.Bytes()
+	if j.options.Pretty {
+		indented := bytes.Buffer{}
+		prefix := ""
+		if j.options.Array {
+			prefix = "  "
+		}
+		if err := json.Indent(&indented, data, prefix, "  "); err != nil {
+			return err
+		}
+		data = indented.Bytes()
+	}
+
+	j.count++
+	if !j.options.Array {
+		_, err := j.w.Write(append(data, '\n'))
+		return err
+	}
+	separator := ","
+	if j.count == 1 {
+		separator = ""
+	}
+	if j.options.Pretty {
+		separator += "\n  "
+	}
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+	_, err := j.w.Write(data)
+	return err
+}
+
+func (j *JSONWriter) End() error {
+	if !j.options.Array {
+		return nil
+	}
+	ending := "]\n"
+	if j.options.Pretty && j.count > 0 {
+		ending = "\n]\n"
+	}
+	_, err := io.WriteString(j.w, ending)
+	return err
+}
+
+func (j *JSONWriter) encodeRecord(record Record) error {
+	fields := record
+	if j.options.SortKeys {
+		fields = append(Record{}, record...)
+		sort.SliceStable(fields, func(a, b int) bool { return fields[a].Name < fields[b].Name })
+	}
+	j.buffer.WriteByte('{')
+	for idx, field := range fields {
+		if idx > 0 {
+			j.buffer.WriteByte(',')
+		}
+		writeJSONString(&j.buffer, field.Name)
+		j.buffer.WriteByte(':')
+		if err := j.encode(field.Schema, field.Value); err != nil {
+			return fmt.Errorf("failed to write %s: %w", field.Name, err)
+		}
+	}
+	j.buffer.WriteByte('}')
+	return nil
+}
+
+// encode writes value with the schema, values without schema are written by their go types
+func (j *JSONWriter) encode(s schema.ItemSchema, value interface{}) error {
+	switch t := s.(type) {
+	case schema.AvroUnion:
+		if value == nil && !j.options.AvroEncoding {
+			j.buffer.WriteString("null")
+			return nil
+		}
+		idx, value := t.Branch(value)
+		if idx < 0 {
+			return fmt.Errorf("value %v doesn't match any union element", value)
+		}
+		branch := t.Elements()[idx]
+		if !j.options.AvroEncoding {
+			return j.encode(branch, value)
+		}
+		if _, isNull := branch.(schema.AvroNull); isNull {
+			j.buffer.WriteString("null")
+			return nil
+		}
+		j.buffer.WriteByte('{')
+		writeJSONString(&j.buffer, schema.TypeName(branch))
+		j.buffer.WriteByte(':')
+		if err := j.encode(branch, value); err != nil {
+			return err
+		}
+		j.buffer.WriteByte('}')
+		return nil
+	case schema.AvroRecord:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not a record %s", value, t.FullName())
+		}
+		fields := t.Fields()
+		if j.options.SortKeys {
+			fields = append([]schema.AvroRecordField{}, fields...)
+			sort.SliceStable(fields, func(a, b int) bool { return fields[a].Name() < fields[b].Name() })
+		}
+		j.buffer.WriteByte('{')
+		for idx, f := range fields {
+			if idx > 0 {
+				j.buffer.WriteByte(',')
+			}
+			writeJSONString(&j.buffer, f.Name())
+			j.buffer.WriteByte(':')
+			if err := j.encode(f.Type(), m[f.Name()]); err != nil {
+				return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+			}
+		}
+		j.buffer.WriteByte('}')
+		return nil
+	case schema.AvroArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not an array", value)
+		}
+		return j.encodeArray(t.Items(), items)
+	case schema.AvroMap:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not a map", value)
+		}
+		return j.encodeMap(t.Values(), m)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		j.buffer.WriteString("null")
+	case bool:
+		j.buffer.WriteString(strconv.FormatBool(v))
+	case string:
+		writeJSONString(&j.buffer, v)
+	case []byte:
+		if j.options.AvroEncoding {
+			writeJSONString(&j.buffer, latin1String(v))
+		} else {
+			writeJSONString(&j.buffer, base64.StdEncoding.EncodeToString(v))
+		}
+	case int32:
+		j.buffer.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		if j.options.LongsAsStrings {
+			writeJSONString(&j.buffer, strconv.FormatInt(v, 10))
+		} else {
+			j.buffer.WriteString(strconv.FormatInt(v, 10))
+		}
+	case float32:
+		writeJSONFloat(&j.buffer, float64(v), 32)
+	case float64:
+		writeJSONFloat(&j.buffer, v, 64)
+	case []interface{}:
+		return j.encodeArray(nil, v)
+	case map[string]interface{}:
+		return j.encodeMap(nil, v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		j.buffer.Write(data)
+	}
+	return nil
+}
+
+func (j *JSONWriter) encodeArray(items schema.ItemSchema, values []interface{}) error {
+	j.buffer.WriteByte('[')
+	for idx, item := range values {
+		if idx > 0 {
+			j.buffer.WriteByte(',')
+		}
+		if err := j.encode(items, item); err != nil {
+			return fmt.Errorf("failed to write item at idx %d: %w", idx, err)
+		}
+	}
+	j.buffer.WriteByte(']')
+	return nil
+}
+
+func (j *JSONWriter) encodeMap(values schema.ItemSchema, m map[string]interface{}) error {
+	j.buffer.WriteByte('{')
+	for idx, key := range sortedKeys(m) {
+		if idx > 0 {
+			j.buffer.WriteByte(',')
+		}
+		writeJSONString(&j.buffer, key)
+		j.buffer.WriteByte(':')
+		if err := j.encode(values, m[key]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", key, err)
+		}
+	}
+	j.buffer.WriteByte('}')
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeJSONFloat writes NaN and infinities as strings since json has no numbers for them
+func writeJSONFloat(buffer *bytes.Buffer, value float64, bitSize int) {
+	switch {
+	case math.IsNaN(value):
+		buffer.WriteString(`"NaN"`)
+	case math.IsInf(value, 1):
+		buffer.WriteString(`"Infinity"`)
+	case math.IsInf(value, -1):
+		buffer.WriteString(`"-Infinity"`)
+	default:
+		buffer.WriteString(strconv.FormatFloat(value, 'g', -1, bitSize))
+	}
+}
+
+func writeJSONString(buffer *bytes.Buffer, value string) {
+	const hex = "0123456789abcdef"
+	buffer.WriteByte('"')
+	for idx := 0; idx < len(value); {
+		char, size := utf8.DecodeRuneInString(value[idx:])
+		switch {
+		case char == '"' || char == '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteByte(byte(char))
+		case char == '\n':
+			buffer.WriteString(`\n`)
+		case char == '\r':
+			buffer.WriteString(`\r`)
+		case char == '\t':
+			buffer.WriteString(`\t`)
+		case char < 0x20 || char == '\u2028' || char == '\u2029':
+			buffer.WriteString(`\u`)
+			for shift := 12; shift >= 0; shift -= 4 {
+				buffer.WriteByte(hex[(char>>shift)&0xf])
+			}
+		case char == utf8.RuneError && size == 1:
+			buffer.WriteString(`\ufffd`)
+		default:
+			buffer.WriteString(value[idx : idx+size])
+		}
+		idx += size
+	}
+	buffer.WriteByte('"')
+}
+
+func latin1String(value []byte) string {
+	result := make([]rune, len(value))
+	for idx, b := range value {
+		result[idx] = rune(b)
+	}
+	return string(result)
+}
@@ -0,0 +1,106 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// NewMessagePackWriter writes records as a stream of MessagePack values
+func NewMessagePackWriter(w io.Writer) Writer {
+	return newBinaryWriter(w, func(buffer *bytes.Buffer) binaryEncoder {
+		return &msgpackEncoder{w: buffer}
+	})
+}
+
+type msgpackEncoder struct {
+	w   *bytes.Buffer
+	buf [9]byte
+}
+
+// header writes format byte followed by big-endian value of given size
+func (m *msgpackEncoder) header(format byte, value uint64, size int) {
+	m.buf[0] = format
+	switch size {
+	case 1:
+		m.buf[1] = byte(value)
+	case 2:
+		binary.BigEndian.PutUint16(m.buf[1:], uint16(value))
+	case 4:
+		binary.BigEndian.PutUint32(m.buf[1:], uint32(value))
+	case 8:
+		binary.BigEndian.PutUint64(m.buf[1:], value)
+	}
+	m.w.Write(m.buf[:1+size])
+}
+
+// length writes length with the smallest of formats for 8, 16 and 32-bit lengths
+func (m *msgpackEncoder) length(length int, format8, format16, format32 byte) {
+	switch {
+	case length <= math.MaxUint8 && format8 != 0:
+		m.header(format8, uint64(length), 1)
+	case length <= math.MaxUint16:
+		m.header(format16, uint64(length), 2)
+	default:
+		m.header(format32, uint64(length), 4)
+	}
+}
+
+func (m *msgpackEncoder) null() {
+	m.w.WriteByte(0xc0)
+}
+
+func (m *msgpackEncoder) boolean(value bool) {
+	if value {
+		m.w.WriteByte(0xc3)
+	} else {
+		m.w.WriteByte(0xc2)
+	}
+}
+
+func (m *msgpackEncoder) int32(value int32) {
+	m.header(0xd2, uint64(uint32(value)), 4)
+}
+
+func (m *msgpackEncoder) int64(value int64) {
+	m.header(0xd3, uint64(value), 8)
+}
+
+func (m *msgpackEncoder) float32(value float32) {
+	m.header(0xca, uint64(math.Float32bits(value)), 4)
+}
+
+func (m *msgpackEncoder) float64(value float64) {
+	m.header(0xcb, math.Float64bits(value), 8)
+}
+
+func (m *msgpackEncoder) string(value string) {
+	if len(value) < 32 {
+		m.w.WriteByte(0xa0 | byte(len(value)))
+	} else {
+		m.length(len(value), 0xd9, 0xda, 0xdb)
+	}
+	m.w.WriteString(value)
+}
+
+func (m *msgpackEncoder) bytes(value []byte) {
+	m.length(len(value), 0xc4, 0xc5, 0xc6)
+	m.w.Write(value)
+}
+
+func (m *msgpackEncoder) arrayHeader(size int) {
+	if size < 16 {
+		m.w.WriteByte(0x90 | byte(size))
+	} else {
+		m.length(size, 0, 0xdc, 0xdd)
+	}
+}
+
+func (m *msgpackEncoder) mapHeader(size int) {
+	if size < 16 {
+		m.w.WriteByte(0x80 | byte(size))
+	} else {
+		m.length(size, 0, 0xde, 0xdf)
+	}
+}
@@ -0,0 +1,158 @@
+package output
+
+import (
+	"avroparser/pkg/parquet"
+	"avroparser/pkg/schema"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ParquetWriter writes records as rows of parquet file with schema of the first record.
+// Record of single unnamed field is written with columns of its fields, fields of other
+// records are top level columns. Fields without avro schema get schema inferred from
+// their values. File without rows is written with schema of the header.
+type ParquetWriter struct {
+	w       io.Writer
+	options parquet.Options
+	header  Record
+	writer  *parquet.Writer
+	// single is set if rows are values of single unnamed field
+	single bool
+}
+
+func NewParquetWriter(w io.Writer, options parquet.Options) *ParquetWriter {
+	return &ParquetWriter{w: w, options: options}
+}
+
+func (p *ParquetWriter) Begin(header Record) error {
+	p.header = header
+	return nil
+}
+
+func (p *ParquetWriter) Record(record Record) error {
+	if p.writer == nil {
+		if err := p.begin(record); err != nil {
+			return err
+		}
+	}
+	if p.single {
+		value, ok := record[0].Value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not a record", record[0].Value)
+		}
+		return p.writer.WriteRecord(value)
+	}
+	values := make([]interface{}, len(record))
+	for idx, field := range record {
+		values[idx] = field.Value
+		if field.Schema == nil {
+			values[idx] = normalizeInferred(field.Value)
+		}
+	}
+	return p.writer.Write(values)
+}
+
+func (p *ParquetWriter) End() error {
+	if p.writer == nil {
+		if p.header == nil {
+			return fmt.Errorf("parquet file can't be written without records, schema is taken from the first record")
+		}
+		if err := p.begin(p.header); err != nil {
+			return err
+		}
+	}
+	return p.writer.Close()
+}
+
+func (p *ParquetWriter) begin(record Record) error {
+	var name string
+	var fields []parquet.Field
+	if len(record) == 1 && record[0].Name == "" {
+		var err error
+		if fields, err = parquet.RecordFields(record[0].Schema); err != nil {
+			return err
+		}
+		name = record[0].Schema.(schema.AvroRecord).Name()
+		p.single = true
+	} else {
+		name = "record"
+		for _, field := range record {
+			fieldSchema := field.Schema
+			if fieldSchema == nil {
+				var err error
+				if fieldSchema, err = inferSchema(field.Value); err != nil {
+					return fmt.Errorf("failed to infer schema of %s: %w", field.Name, err)
+				}
+			}
+			fields = append(fields, parquet.Field{Name: field.Name, Schema: fieldSchema})
+		}
+	}
+	writer, err := parquet.NewWriter(p.w, name, fields, p.options)
+	if err != nil {
+		return err
+	}
+	p.writer = writer
+	return nil
+}
+
+// inferSchema returns nullable avro type for values that are not avro data, like offsets
+// and headers of messages
+func inferSchema(value interface{}) (schema.ItemSchema, error) {
+	return schema.ParseSchema([]interface{}{"null", inferType(value)})
+}
+
+func inferType(value interface{}) interface{} {
+	switch v := normalizeInferred(value).(type) {
+	case bool:
+		return "boolean"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case []byte:
+		return "bytes"
+	case []interface{}:
+		for _, item := range v {
+			if item != nil {
+				return map[string]interface{}{"type": "array", "items": []interface{}{"null", inferType(item)}}
+			}
+		}
+		return map[string]interface{}{"type": "array", "items": []interface{}{"null", "string"}}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if v[key] != nil {
+				return map[string]interface{}{"type": "map", "values": []interface{}{"null", inferType(v[key])}}
+			}
+		}
+		return map[string]interface{}{"type": "map", "values": []interface{}{"null", "string"}}
+	}
+	return "string"
+}
+
+// normalizeInferred converts json numbers to longs and doubles
+func normalizeInferred(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for idx, item := range v {
+			result[idx] = normalizeInferred(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = normalizeInferred(item)
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,11 @@
+package output
+
+// Writer writes records in one of output formats
+type Writer interface {
+	// Begin is called before the first record with names and schemas of fields of records,
+	// header is nil if they are known only from the records
+	Begin(header Record) error
+	Record(record Record) error
+	// End is called after the last record, it completes the output
+	End() error
+}
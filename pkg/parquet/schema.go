@@ -0,0 +1,323 @@
+package parquet
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+	"strconv"
+)
+
+// physical types
+const (
+	typeBoolean           = 0
+	typeInt32             = 1
+	typeInt64             = 2
+	typeFloat             = 4
+	typeDouble            = 5
+	typeByteArray         = 6
+	typeFixedLenByteArray = 7
+)
+
+// repetition types
+const (
+	required = 0
+	optional = 1
+	repeated = 2
+)
+
+// converted types, set along with logical types for older readers
+const (
+	convertedUTF8            = 0
+	convertedMap             = 1
+	convertedMapKeyValue     = 2
+	convertedList            = 3
+	convertedEnum            = 4
+	convertedDecimal         = 5
+	convertedDate            = 6
+	convertedTimeMillis      = 7
+	convertedTimeMicros      = 8
+	convertedTimestampMillis = 9
+	convertedTimestampMicros = 10
+	noConvertedType          = -1
+)
+
+// logical types are ids of LogicalType union members
+const (
+	logicalString    = 1
+	logicalMap       = 2
+	logicalList      = 3
+	logicalEnum      = 4
+	logicalDecimal   = 5
+	logicalDate      = 6
+	logicalTime      = 7
+	logicalTimestamp = 8
+	noLogicalType    = 0
+)
+
+// time units are ids of TimeUnit union members
+const (
+	unitMillis = 1
+	unitMicros = 2
+	unitNanos  = 3
+)
+
+type nodeKind int
+
+const (
+	leafNode nodeKind = iota
+	groupNode
+	listNode
+	mapNode
+	// unionNode is a group with optional member for each non-null type of the union
+	unionNode
+)
+
+// Field is a top level column or group of columns
+type Field struct {
+	Name   string
+	Schema schema.ItemSchema
+}
+
+// RecordFields returns fields of avro record, so that records can be written as rows
+func RecordFields(s schema.ItemSchema) ([]Field, error) {
+	record, ok := s.(schema.AvroRecord)
+	if !ok {
+		return nil, fmt.Errorf("schema of rows should be a record, got %s", schema.TypeName(s))
+	}
+	fields := make([]Field, len(record.Fields()))
+	for idx, f := range record.Fields() {
+		fields[idx] = Field{Name: f.Name(), Schema: f.Type()}
+	}
+	return fields, nil
+}
+
+// node is an element of parquet schema with avro schema of values it stores
+type node struct {
+	name       string
+	kind       nodeKind
+	repetition int
+	schema     schema.ItemSchema
+	children   []*node
+
+	physicalType  int
+	typeLength    int
+	convertedType int
+	logicalType   int
+	// timeUnit and utc annotate time and timestamp logical types
+	timeUnit  int
+	utc       bool
+	logical   *schema.LogicalType
+	maxDef    int
+	maxRep    int
+	column    *column
+	fieldPath []string
+}
+
+// newNode converts avro schema to parquet schema element, parents are names of
+// records being converted as parquet has no recursive types
+func newNode(name string, s schema.ItemSchema, repetition int, parents []string) (*node, error) {
+	n := &node{name: name, repetition: repetition, schema: s, convertedType: noConvertedType}
+	switch t := s.(type) {
+	case schema.AvroUnion:
+		var elements []schema.ItemSchema
+		nullable := false
+		for _, element := range t.Elements() {
+			if _, isNull := element.(schema.AvroNull); isNull {
+				nullable = true
+			} else {
+				elements = append(elements, element)
+			}
+		}
+		if nullable && repetition == required {
+			repetition = optional
+		}
+		switch len(elements) {
+		case 0:
+			return nil, fmt.Errorf("field %s has only null type", name)
+		case 1:
+			return newNode(name, elements[0], repetition, parents)
+		}
+		n.kind, n.repetition = unionNode, repetition
+		for idx, element := range elements {
+			member, err := newNode("member"+strconv.Itoa(idx), element, optional, parents)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, member)
+		}
+		return n, nil
+	case schema.AvroRecord:
+		fullName := schema.TypeName(t)
+		for _, parent := range parents {
+			if parent == fullName {
+				return nil, fmt.Errorf("recursive record %s can't be written to parquet", fullName)
+			}
+		}
+		parents = append(parents, fullName)
+		n.kind = groupNode
+		for _, f := range t.Fields() {
+			child, err := newNode(f.Name(), f.Type(), required, parents)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+		if len(n.children) == 0 {
+			return nil, fmt.Errorf("record %s has no fields, parquet groups can't be empty", fullName)
+		}
+		return n, nil
+	case schema.AvroArray:
+		element, err := newNode("element", t.Items(), required, parents)
+		if err != nil {
+			return nil, err
+		}
+		n.kind, n.convertedType, n.logicalType = listNode, convertedList, logicalList
+		n.children = []*node{{name: "list", kind: groupNode, repetition: repeated, convertedType: noConvertedType, children: []*node{element}}}
+		return n, nil
+	case schema.AvroMap:
+		key := &node{name: "key", repetition: required, schema: schema.AvroString{}, physicalType: typeByteArray,
+			convertedType: convertedUTF8, logicalType: logicalString}
+		value, err := newNode("value", t.Values(), required, parents)
+		if err != nil {
+			return nil, err
+		}
+		n.kind, n.convertedType, n.logicalType = mapNode, convertedMap, logicalMap
+		n.children = []*node{{name: "key_value", kind: groupNode, repetition: repeated, convertedType: noConvertedType, children: []*node{key, value}}}
+		return n, nil
+	case schema.AvroNull:
+		return nil, fmt.Errorf("field %s has null type", name)
+	}
+
+	n.kind = leafNode
+	n.logical = schema.LogicalTypeOf(s)
+	switch t := s.(type) {
+	case schema.AvroBoolean:
+		n.physicalType = typeBoolean
+	case schema.AvroInt:
+		n.physicalType = typeInt32
+	case schema.AvroLong:
+		n.physicalType = typeInt64
+	case schema.AvroFloat:
+		n.physicalType = typeFloat
+	case schema.AvroDouble:
+		n.physicalType = typeDouble
+	case schema.AvroBytes:
+		n.physicalType = typeByteArray
+	case schema.AvroString:
+		n.physicalType, n.convertedType, n.logicalType = typeByteArray, convertedUTF8, logicalString
+	case schema.AvroEnum:
+		n.physicalType, n.convertedType, n.logicalType = typeByteArray, convertedEnum, logicalEnum
+	case schema.AvroFixed:
+		n.physicalType, n.typeLength = typeFixedLenByteArray, t.Size()
+	default:
+		return nil, fmt.Errorf("type of field %s is not supported: %T", name, s)
+	}
+	if n.logical != nil {
+		n.annotate()
+	}
+	return n, nil
+}
+
+// annotate sets parquet logical type of avro logical type, uuid and duration are left
+// as they are as parquet stores them differently
+func (n *node) annotate() {
+	switch n.logical.Name {
+	case schema.LogicalDecimal:
+		n.convertedType, n.logicalType = convertedDecimal, logicalDecimal
+	case schema.LogicalDate:
+		n.convertedType, n.logicalType = convertedDate, logicalDate
+	case schema.LogicalTimeMillis:
+		n.convertedType, n.logicalType, n.timeUnit, n.utc = convertedTimeMillis, logicalTime, unitMillis, true
+	case schema.LogicalTimeMicros:
+		n.convertedType, n.logicalType, n.timeUnit, n.utc = convertedTimeMicros, logicalTime, unitMicros, true
+	case schema.LogicalTimestampMillis:
+		n.convertedType, n.logicalType, n.timeUnit, n.utc = convertedTimestampMillis, logicalTimestamp, unitMillis, true
+	case schema.LogicalTimestampMicros:
+		n.convertedType, n.logicalType, n.timeUnit, n.utc = convertedTimestampMicros, logicalTimestamp, unitMicros, true
+	case schema.LogicalTimestampNanos:
+		n.logicalType, n.timeUnit, n.utc = logicalTimestamp, unitNanos, true
+	case schema.LogicalLocalTimestampMillis:
+		n.logicalType, n.timeUnit = logicalTimestamp, unitMillis
+	case schema.LogicalLocalTimestampMicros:
+		n.logicalType, n.timeUnit = logicalTimestamp, unitMicros
+	case schema.LogicalLocalTimestampNanos:
+		n.logicalType, n.timeUnit = logicalTimestamp, unitNanos
+	}
+}
+
+// setLevels sets maximum definition and repetition levels and collects leaf columns
+func (n *node) setLevels(def, rep int, path []string, columns []*column) []*column {
+	if n.repetition == optional {
+		def++
+	} else if n.repetition == repeated {
+		def++
+		rep++
+	}
+	n.maxDef, n.maxRep = def, rep
+	path = append(append([]string{}, path...), n.name)
+	if n.kind == leafNode {
+		n.fieldPath = path
+		n.column = &column{node: n}
+		return append(columns, n.column)
+	}
+	for _, child := range n.children {
+		columns = child.setLevels(def, rep, path, columns)
+	}
+	return columns
+}
+
+// writeElement writes parquet schema element of the node followed by elements of children
+func (n *node) writeElement(t *thriftWriter, root bool) {
+	t.structBegin()
+	if n.kind == leafNode {
+		t.i32Field(1, int32(n.physicalType))
+		if n.physicalType == typeFixedLenByteArray {
+			t.i32Field(2, int32(n.typeLength))
+		}
+	}
+	if !root {
+		t.i32Field(3, int32(n.repetition))
+	}
+	t.stringField(4, n.name)
+	if n.kind != leafNode {
+		t.i32Field(5, int32(len(n.children)))
+	}
+	if n.convertedType != noConvertedType {
+		t.i32Field(6, int32(n.convertedType))
+	}
+	if n.logicalType == logicalDecimal {
+		t.i32Field(7, int32(n.logical.Scale))
+		t.i32Field(8, int32(n.logical.Precision))
+	}
+	if n.logicalType != noLogicalType {
+		t.structField(10)
+		switch n.logicalType {
+		case logicalDecimal:
+			t.structField(logicalDecimal)
+			t.i32Field(1, int32(n.logical.Scale))
+			t.i32Field(2, int32(n.logical.Precision))
+			t.structEnd()
+		case logicalTime, logicalTimestamp:
+			t.structField(int16(n.logicalType))
+			t.boolField(1, n.utc)
+			t.structField(2)
+			t.emptyStructField(int16(n.timeUnit))
+			t.structEnd()
+			t.structEnd()
+		default:
+			t.emptyStructField(int16(n.logicalType))
+		}
+		t.structEnd()
+	}
+	t.structEnd()
+	for _, child := range n.children {
+		child.writeElement(t, false)
+	}
+}
+
+func (n *node) countElements() int {
+	count := 1
+	for _, child := range n.children {
+		count += child.countElements()
+	}
+	return count
+}
@@ -0,0 +1,114 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// thrift compact protocol types
+const (
+	thriftBooleanTrue  = 1
+	thriftBooleanFalse = 2
+	thriftI32          = 5
+	thriftI64          = 6
+	thriftBinary       = 8
+	thriftList         = 9
+	thriftStruct       = 12
+)
+
+// thriftWriter writes structures of parquet metadata in thrift compact protocol
+type thriftWriter struct {
+	buffer bytes.Buffer
+	// lastField is id of the previous field of each struct being written
+	lastField []int16
+}
+
+func (t *thriftWriter) varint(value uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	t.buffer.Write(buf[:binary.PutUvarint(buf, value)])
+}
+
+func (t *thriftWriter) zigzag(value int64) {
+	t.varint(uint64((value << 1) ^ (value >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := t.lastField[len(t.lastField)-1]
+	if delta := id - last; delta > 0 && delta <= 15 {
+		t.buffer.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buffer.WriteByte(fieldType)
+		t.zigzag(int64(id))
+	}
+	t.lastField[len(t.lastField)-1] = id
+}
+
+func (t *thriftWriter) structBegin() {
+	t.lastField = append(t.lastField, 0)
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buffer.WriteByte(0)
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+func (t *thriftWriter) boolField(id int16, value bool) {
+	if value {
+		t.fieldHeader(id, thriftBooleanTrue)
+	} else {
+		t.fieldHeader(id, thriftBooleanFalse)
+	}
+}
+
+func (t *thriftWriter) i32Field(id int16, value int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(value))
+}
+
+func (t *thriftWriter) i64Field(id int16, value int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(value)
+}
+
+func (t *thriftWriter) stringField(id int16, value string) {
+	t.fieldHeader(id, thriftBinary)
+	t.varint(uint64(len(value)))
+	t.buffer.WriteString(value)
+}
+
+// structField begins nested struct, it is ended with structEnd
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+// emptyStructField writes struct without fields, as used by members of thrift unions
+func (t *thriftWriter) emptyStructField(id int16) {
+	t.structField(id)
+	t.structEnd()
+}
+
+func (t *thriftWriter) listField(id int16, elementType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buffer.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		t.buffer.WriteByte(0xf0 | elementType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) i32List(id int16, values []int32) {
+	t.listField(id, thriftI32, len(values))
+	for _, value := range values {
+		t.zigzag(int64(value))
+	}
+}
+
+func (t *thriftWriter) stringList(id int16, values []string) {
+	t.listField(id, thriftBinary, len(values))
+	for _, value := range values {
+		t.varint(uint64(len(value)))
+		t.buffer.WriteString(value)
+	}
+}
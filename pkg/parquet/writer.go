@@ -0,0 +1,571 @@
+package parquet
+
+import (
+	"avroparser/pkg/schema"
+	"avroparser/pkg/snappy"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	DefaultRowGroupSize = 64 * 1024 * 1024
+	DefaultPageSize     = 1024 * 1024
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageTypeData = 0
+)
+
+var magic = []byte("PAR1")
+
+type Options struct {
+	// Codec compresses pages: uncompressed, snappy or gzip
+	Codec string
+	// RowGroupSize is approximate size of buffered data after which row group is written
+	RowGroupSize int
+	// PageSize is approximate size of values of a column in single page
+	PageSize int
+	// Metadata is written as key-value metadata of the file
+	Metadata map[string]string
+}
+
+// Writer writes rows of avro values as parquet file. Records are written as groups,
+// arrays and maps as LIST and MAP groups with repeated groups inside of them, nullable
+// unions as optional fields and other unions as groups with optional member of each type.
+type Writer struct {
+	w         io.Writer
+	offset    int64
+	options   Options
+	codec     int32
+	root      *node
+	columns   []*column
+	rowGroups []rowGroup
+	rows      int64
+	total     int64
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	size    int64
+	rows    int64
+}
+
+type columnChunk struct {
+	offset           int64
+	values           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// NewWriter writes parquet schema with the fields as top level columns of the message named name
+func NewWriter(w io.Writer, name string, fields []Field, options Options) (*Writer, error) {
+	writer := &Writer{w: w, options: options}
+	switch options.Codec {
+	case "", "uncompressed":
+		writer.codec = codecUncompressed
+	case "snappy":
+		writer.codec = codecSnappy
+	case "gzip":
+		writer.codec = codecGzip
+	default:
+		return nil, fmt.Errorf("parquet codec %s is not supported, expected one of uncompressed, snappy, gzip", options.Codec)
+	}
+	if writer.options.RowGroupSize <= 0 {
+		writer.options.RowGroupSize = DefaultRowGroupSize
+	}
+	if writer.options.PageSize <= 0 {
+		writer.options.PageSize = DefaultPageSize
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("parquet schema should have at least one field")
+	}
+	writer.root = &node{name: name, kind: groupNode, convertedType: noConvertedType}
+	for _, field := range fields {
+		child, err := newNode(field.Name, field.Schema, required, nil)
+		if err != nil {
+			return nil, err
+		}
+		writer.root.children = append(writer.root.children, child)
+		writer.columns = child.setLevels(0, 0, nil, writer.columns)
+	}
+	if err := writer.write(magic); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// Write writes a row with values of the fields
+func (w *Writer) Write(values []interface{}) error {
+	if len(values) != len(w.root.children) {
+		return fmt.Errorf("row has %d values, expected %d", len(values), len(w.root.children))
+	}
+	for _, c := range w.columns {
+		c.mark()
+	}
+	for idx, child := range w.root.children {
+		if err := w.shred(child, values[idx], 0, 0); err != nil {
+			// columns are rolled back so that a bad row doesn't break the file
+			for _, c := range w.columns {
+				c.rollback()
+			}
+			return fmt.Errorf("failed to write %s: %w", child.name, err)
+		}
+	}
+	w.rows++
+	size := 0
+	for _, c := range w.columns {
+		if c.size >= w.options.PageSize {
+			if err := c.flushPage(w.codec); err != nil {
+				return err
+			}
+		}
+		size += c.size + c.chunk.Len()
+	}
+	if size >= w.options.RowGroupSize {
+		return w.flushRowGroup()
+	}
+	return nil
+}
+
+// WriteRecord writes avro record with fields named as top level fields
+func (w *Writer) WriteRecord(value map[string]interface{}) error {
+	values := make([]interface{}, len(w.root.children))
+	for idx, child := range w.root.children {
+		values[idx] = value[child.name]
+	}
+	return w.Write(values)
+}
+
+// Close writes buffered rows and file footer, underlying writer is not closed
+func (w *Writer) Close() error {
+	if err := w.flushRowGroup(); err != nil {
+		return err
+	}
+	footer := w.footer()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(footer)))
+	if err := w.write(length); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+// shred writes value into columns with definition and repetition levels, def is the level
+// of defined parents and rep is repetition level of the first written value
+func (w *Writer) shred(n *node, value interface{}, rep, def int) error {
+	if n.repetition == optional {
+		if value == nil {
+			n.writeNull(rep, def)
+			return nil
+		}
+		def++
+	} else if value == nil {
+		return fmt.Errorf("value of required field %s is null", n.name)
+	}
+	switch n.kind {
+	case leafNode:
+		return n.column.append(value, rep, def)
+	case groupNode:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v of %s is not a record", value, n.name)
+		}
+		for _, child := range n.children {
+			if err := w.shred(child, m[child.name], rep, def); err != nil {
+				return fmt.Errorf("failed to write %s: %w", child.name, err)
+			}
+		}
+	case listNode:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("value %v of %s is not an array", value, n.name)
+		}
+		list := n.children[0]
+		if len(items) == 0 {
+			list.writeNull(rep, def)
+		}
+		for idx, item := range items {
+			itemRep := rep
+			if idx > 0 {
+				itemRep = list.maxRep
+			}
+			if err := w.shred(list.children[0], item, itemRep, def+1); err != nil {
+				return fmt.Errorf("failed to write item at idx %d: %w", idx, err)
+			}
+		}
+	case mapNode:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v of %s is not a map", value, n.name)
+		}
+		keyValue := n.children[0]
+		if len(m) == 0 {
+			keyValue.writeNull(rep, def)
+		}
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for idx, key := range keys {
+			entryRep := rep
+			if idx > 0 {
+				entryRep = keyValue.maxRep
+			}
+			if err := keyValue.children[0].column.append(key, entryRep, def+1); err != nil {
+				return err
+			}
+			if err := w.shred(keyValue.children[1], m[key], entryRep, def+1); err != nil {
+				return fmt.Errorf("failed to write %s: %w", key, err)
+			}
+		}
+	case unionNode:
+		member, value, err := n.unionMember(value)
+		if err != nil {
+			return err
+		}
+		for idx, child := range n.children {
+			if idx == member {
+				if err = w.shred(child, value, rep, def); err != nil {
+					return err
+				}
+			} else {
+				child.writeNull(rep, def)
+			}
+		}
+	}
+	return nil
+}
+
+// unionMember returns index of member of union group the value is written to and the value
+// without union tag, members are non-null types of the union in the same order
+func (n *node) unionMember(value interface{}) (int, interface{}, error) {
+	union := n.schema.(schema.AvroUnion)
+	branch, value := union.Branch(value)
+	if branch < 0 {
+		return 0, nil, fmt.Errorf("value %v doesn't match any type of union %s", value, n.name)
+	}
+	member := 0
+	for _, element := range union.Elements()[:branch] {
+		if _, isNull := element.(schema.AvroNull); !isNull {
+			member++
+		}
+	}
+	return member, value, nil
+}
+
+// writeNull writes null to all columns of the node
+func (n *node) writeNull(rep, def int) {
+	if n.kind == leafNode {
+		n.column.appendNull(rep, def)
+		return
+	}
+	for _, child := range n.children {
+		child.writeNull(rep, def)
+	}
+}
+
+func (w *Writer) flushRowGroup() error {
+	if w.rows == 0 {
+		return nil
+	}
+	group := rowGroup{rows: w.rows}
+	for _, c := range w.columns {
+		if err := c.flushPage(w.codec); err != nil {
+			return err
+		}
+		chunk := columnChunk{
+			offset:           w.offset,
+			values:           c.chunkValues,
+			uncompressedSize: c.chunkUncompressed,
+			compressedSize:   int64(c.chunk.Len()),
+		}
+		if err := w.write(c.chunk.Bytes()); err != nil {
+			return err
+		}
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.uncompressedSize
+		c.chunk.Reset()
+		c.chunkValues, c.chunkUncompressed = 0, 0
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.total += w.rows
+	w.rows = 0
+	return nil
+}
+
+func (w *Writer) footer() []byte {
+	t := &thriftWriter{}
+	t.structBegin()
+	t.i32Field(1, 1)
+	t.listField(2, thriftStruct, w.root.countElements())
+	w.root.writeElement(t, true)
+	t.i64Field(3, w.total)
+	t.listField(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.structBegin()
+		t.listField(1, thriftStruct, len(group.columns))
+		for idx, chunk := range group.columns {
+			c := w.columns[idx]
+			t.structBegin()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, int32(c.node.physicalType))
+			t.i32List(2, []int32{encodingPlain, encodingRLE})
+			t.stringList(3, c.node.fieldPath)
+			t.i32Field(4, w.codec)
+			t.i64Field(5, chunk.values)
+			t.i64Field(6, chunk.uncompressedSize)
+			t.i64Field(7, chunk.compressedSize)
+			t.i64Field(9, chunk.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64Field(2, group.size)
+		t.i64Field(3, group.rows)
+		t.structEnd()
+	}
+	if len(w.options.Metadata) > 0 {
+		keys := make([]string, 0, len(w.options.Metadata))
+		for key := range w.options.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		t.listField(5, thriftStruct, len(keys))
+		for _, key := range keys {
+			t.structBegin()
+			t.stringField(1, key)
+			t.stringField(2, w.options.Metadata[key])
+			t.structEnd()
+		}
+	}
+	t.stringField(6, "avroparser")
+	t.structEnd()
+	return t.buffer.Bytes()
+}
+
+///////////////////////
+
+// column buffers values and levels of a leaf until they are written as a page
+type column struct {
+	node      *node
+	values    []interface{}
+	defLevels []int
+	repLevels []int
+	// size is approximate size of encoded values of the page
+	size int
+	// chunk is encoded pages of the current row group
+	chunk             bytes.Buffer
+	chunkValues       int64
+	chunkUncompressed int64
+
+	markLevels, markValues, markSize int
+}
+
+func (c *column) mark() {
+	c.markLevels, c.markValues, c.markSize = len(c.defLevels), len(c.values), c.size
+}
+
+func (c *column) rollback() {
+	c.defLevels, c.repLevels = c.defLevels[:c.markLevels], c.repLevels[:c.markLevels]
+	c.values, c.size = c.values[:c.markValues], c.markSize
+}
+
+func (c *column) appendNull(rep, def int) {
+	c.defLevels = append(c.defLevels, def)
+	c.repLevels = append(c.repLevels, rep)
+}
+
+func (c *column) append(value interface{}, rep, def int) error {
+	var size int
+	switch c.node.physicalType {
+	case typeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("value %v of %s is not boolean", value, c.node.name)
+		}
+	case typeInt32:
+		if _, ok := value.(int32); !ok {
+			return fmt.Errorf("value %v of %s is not int", value, c.node.name)
+		}
+		size = 4
+	case typeInt64:
+		if _, ok := value.(int64); !ok {
+			return fmt.Errorf("value %v of %s is not long", value, c.node.name)
+		}
+		size = 8
+	case typeFloat:
+		if _, ok := value.(float32); !ok {
+			return fmt.Errorf("value %v of %s is not float", value, c.node.name)
+		}
+		size = 4
+	case typeDouble:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("value %v of %s is not double", value, c.node.name)
+		}
+		size = 8
+	case typeByteArray:
+		switch v := value.(type) {
+		case string:
+			value = []byte(v)
+		case []byte:
+		default:
+			return fmt.Errorf("value %v of %s is not string or bytes", value, c.node.name)
+		}
+		size = 4 + len(value.([]byte))
+	case typeFixedLenByteArray:
+		if b, ok := value.([]byte); !ok || len(b) != c.node.typeLength {
+			return fmt.Errorf("value %v of %s is not fixed of size %d", value, c.node.name, c.node.typeLength)
+		}
+		size = c.node.typeLength
+	}
+	c.values = append(c.values, value)
+	c.defLevels = append(c.defLevels, def)
+	c.repLevels = append(c.repLevels, rep)
+	c.size += size
+	return nil
+}
+
+// flushPage encodes buffered values as data page v1 appended to the column chunk
+func (c *column) flushPage(codec int32) error {
+	if len(c.defLevels) == 0 {
+		return nil
+	}
+	page := bytes.Buffer{}
+	if c.node.maxRep > 0 {
+		writeLevels(&page, c.repLevels, c.node.maxRep)
+	}
+	if c.node.maxDef > 0 {
+		writeLevels(&page, c.defLevels, c.node.maxDef)
+	}
+	c.writeValues(&page)
+	data := page.Bytes()
+	compressed, err := compress(codec, data)
+	if err != nil {
+		return fmt.Errorf("failed to compress page of %s: %w", c.node.name, err)
+	}
+
+	t := &thriftWriter{}
+	t.structBegin()
+	t.i32Field(1, pageTypeData)
+	t.i32Field(2, int32(len(data)))
+	t.i32Field(3, int32(len(compressed)))
+	t.structField(5)
+	t.i32Field(1, int32(len(c.defLevels)))
+	t.i32Field(2, encodingPlain)
+	t.i32Field(3, encodingRLE)
+	t.i32Field(4, encodingRLE)
+	t.structEnd()
+	t.structEnd()
+
+	c.chunk.Write(t.buffer.Bytes())
+	c.chunk.Write(compressed)
+	c.chunkValues += int64(len(c.defLevels))
+	c.chunkUncompressed += int64(t.buffer.Len() + len(data))
+	c.values, c.defLevels, c.repLevels, c.size = c.values[:0], c.defLevels[:0], c.repLevels[:0], 0
+	return nil
+}
+
+// writeValues writes values in plain encoding
+func (c *column) writeValues(page *bytes.Buffer) {
+	buf := make([]byte, 8)
+	switch c.node.physicalType {
+	case typeBoolean:
+		packed := make([]byte, (len(c.values)+7)/8)
+		for idx, value := range c.values {
+			if value.(bool) {
+				packed[idx/8] |= 1 << (idx % 8)
+			}
+		}
+		page.Write(packed)
+	case typeInt32:
+		for _, value := range c.values {
+			binary.LittleEndian.PutUint32(buf, uint32(value.(int32)))
+			page.Write(buf[:4])
+		}
+	case typeInt64:
+		for _, value := range c.values {
+			binary.LittleEndian.PutUint64(buf, uint64(value.(int64)))
+			page.Write(buf)
+		}
+	case typeFloat:
+		for _, value := range c.values {
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(value.(float32)))
+			page.Write(buf[:4])
+		}
+	case typeDouble:
+		for _, value := range c.values {
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(value.(float64)))
+			page.Write(buf)
+		}
+	case typeByteArray:
+		for _, value := range c.values {
+			binary.LittleEndian.PutUint32(buf, uint32(len(value.([]byte))))
+			page.Write(buf[:4])
+			page.Write(value.([]byte))
+		}
+	case typeFixedLenByteArray:
+		for _, value := range c.values {
+			page.Write(value.([]byte))
+		}
+	}
+}
+
+// writeLevels writes levels in RLE encoding prefixed with 4-byte length, only runs of
+// repeated values are used
+func writeLevels(page *bytes.Buffer, levels []int, maxLevel int) {
+	byteWidth := (bits.Len(uint(maxLevel)) + 7) / 8
+	encoded := bytes.Buffer{}
+	buf := make([]byte, binary.MaxVarintLen64)
+	for start := 0; start < len(levels); {
+		end := start + 1
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		encoded.Write(buf[:binary.PutUvarint(buf, uint64(end-start)<<1)])
+		for idx := 0; idx < byteWidth; idx++ {
+			encoded.WriteByte(byte(levels[start] >> (8 * idx)))
+		}
+		start = end
+	}
+	binary.LittleEndian.PutUint32(buf, uint32(encoded.Len()))
+	page.Write(buf[:4])
+	page.Write(encoded.Bytes())
+}
+
+func compress(codec int32, data []byte) ([]byte, error) {
+	switch codec {
+	case codecSnappy:
+		return snappy.Encode(data), nil
+	case codecGzip:
+		result := bytes.Buffer{}
+		writer := gzip.NewWriter(&result)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return result.Bytes(), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,514 @@
+package parquet
+
+import (
+	"avroparser/pkg/schema"
+	"avroparser/pkg/snappy"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const rowSchema = `{"type": "record", "name": "Row", "fields": [
+	{"name": "id", "type": "long"},
+	{"name": "name", "type": ["null", "string"]},
+	{"name": "address", "type": ["null", {"type": "record", "name": "Address", "fields": [
+		{"name": "street", "type": "string"},
+		{"name": "zip", "type": ["null", "int"]}
+	]}]},
+	{"name": "tags", "type": {"type": "array", "items": ["null", "string"]}},
+	{"name": "scores", "type": {"type": "map", "values": "long"}},
+	{"name": "value", "type": ["null", "long", "string"]}
+]}`
+
+var rows = []map[string]interface{}{
+	{
+		"id": int64(1), "name": "a", "address": map[string]interface{}{"street": "s1", "zip": int32(10)},
+		"tags": []interface{}{"x", nil}, "scores": map[string]interface{}{"b": int64(2), "a": int64(1)}, "value": int64(5),
+	},
+	{
+		"id": int64(2), "name": nil, "address": nil,
+		"tags": []interface{}{}, "scores": map[string]interface{}{}, "value": "v",
+	},
+	{
+		"id": int64(3), "name": "c", "address": map[string]interface{}{"street": "s3", "zip": nil},
+		"tags": []interface{}{"y"}, "scores": map[string]interface{}{"k": int64(3)}, "value": nil,
+	},
+}
+
+// columnData is levels and values of a column read from all pages of a column chunk
+type columnData struct {
+	def, rep []int
+	values   []interface{}
+}
+
+var expectedColumns = map[string]columnData{
+	"id":                     {[]int{0, 0, 0}, []int{0, 0, 0}, []interface{}{int64(1), int64(2), int64(3)}},
+	"name":                   {[]int{1, 0, 1}, []int{0, 0, 0}, []interface{}{"a", "c"}},
+	"address.street":         {[]int{1, 0, 1}, []int{0, 0, 0}, []interface{}{"s1", "s3"}},
+	"address.zip":            {[]int{2, 0, 1}, []int{0, 0, 0}, []interface{}{int32(10)}},
+	"tags.list.element":      {[]int{2, 1, 0, 2}, []int{0, 1, 0, 0}, []interface{}{"x", "y"}},
+	"scores.key_value.key":   {[]int{1, 1, 0, 1}, []int{0, 1, 0, 0}, []interface{}{"a", "b", "k"}},
+	"scores.key_value.value": {[]int{1, 1, 0, 1}, []int{0, 1, 0, 0}, []interface{}{int64(1), int64(2), int64(3)}},
+	"value.member0":          {[]int{2, 1, 0}, []int{0, 0, 0}, []interface{}{int64(5)}},
+	"value.member1":          {[]int{1, 2, 0}, []int{0, 0, 0}, []interface{}{"v"}},
+}
+
+// schemaElement is name, repetition, number of children and converted type of parquet
+// schema element, -1 is not set
+type schemaElement struct {
+	name                            string
+	repetition, children, converted int64
+}
+
+var expectedSchema = []schemaElement{
+	{"Row", -1, 6, -1},
+	{"id", required, -1, -1},
+	{"name", optional, -1, convertedUTF8},
+	{"address", optional, 2, -1},
+	{"street", required, -1, convertedUTF8},
+	{"zip", optional, -1, -1},
+	{"tags", required, 1, convertedList},
+	{"list", repeated, 1, -1},
+	{"element", optional, -1, convertedUTF8},
+	{"scores", required, 1, convertedMap},
+	{"key_value", repeated, 2, -1},
+	{"key", required, -1, convertedUTF8},
+	{"value", required, -1, -1},
+	{"value", optional, 2, -1},
+	{"member0", optional, -1, -1},
+	{"member1", optional, -1, convertedUTF8},
+}
+
+func writeRows(t *testing.T, options Options) []byte {
+	t.Helper()
+	s, err := schema.ParseSchemaJSON([]byte(rowSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := RecordFields(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer := bytes.Buffer{}
+	writer, err := NewWriter(&buffer, "Row", fields, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err = writer.WriteRecord(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{"uncompressed", Options{}},
+		{"snappy pages", Options{Codec: "snappy", PageSize: 1}},
+		{"gzip row groups", Options{Codec: "gzip", RowGroupSize: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := writeRows(t, test.options)
+			footer := readFooter(t, data)
+			if rowCount := footer[3]; rowCount != int64(len(rows)) {
+				t.Fatalf("expected %d rows, got %v", len(rows), rowCount)
+			}
+			checkSchema(t, footer[2].([]interface{}))
+
+			columns := make(map[string]columnData)
+			groupRows := int64(0)
+			for _, group := range footer[4].([]interface{}) {
+				group := group.(map[int16]interface{})
+				groupRows += group[3].(int64)
+				for _, chunk := range group[1].([]interface{}) {
+					meta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+					path := joinPath(meta[3].([]interface{}))
+					maxDef, maxRep := expectedLevels(path)
+					read := readChunk(t, data, meta, maxDef, maxRep)
+					column := columns[path]
+					column.def = append(column.def, read.def...)
+					column.rep = append(column.rep, read.rep...)
+					column.values = append(column.values, read.values...)
+					columns[path] = column
+				}
+			}
+			if groupRows != int64(len(rows)) {
+				t.Fatalf("expected %d rows in row groups, got %d", len(rows), groupRows)
+			}
+			if len(columns) != len(expectedColumns) {
+				t.Fatalf("expected %d columns, got %d", len(expectedColumns), len(columns))
+			}
+			for path, expected := range expectedColumns {
+				if !reflect.DeepEqual(columns[path], expected) {
+					t.Errorf("column %s: expected %+v, got %+v", path, expected, columns[path])
+				}
+			}
+		})
+	}
+}
+
+func TestWriterWithoutRows(t *testing.T) {
+	buffer := bytes.Buffer{}
+	writer, err := NewWriter(&buffer, "Row", []Field{{Name: "id", Schema: schema.AvroLong{}}}, Options{Metadata: map[string]string{"k": "v"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	footer := readFooter(t, buffer.Bytes())
+	if footer[3] != int64(0) || len(footer[4].([]interface{})) != 0 {
+		t.Fatalf("expected no rows, got %v", footer)
+	}
+	metadata := footer[5].([]interface{})[0].(map[int16]interface{})
+	if string(metadata[1].([]byte)) != "k" || string(metadata[2].([]byte)) != "v" {
+		t.Fatalf("unexpected key-value metadata %v", metadata)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	s, err := schema.ParseSchemaJSON([]byte(`{"type": "record", "name": "Node", "fields": [{"name": "next", "type": ["null", "Node"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewWriter(io.Discard, "Node", []Field{{Name: "node", Schema: s}}, Options{}); err == nil || !strings.Contains(err.Error(), "recursive record") {
+		t.Fatalf("expected error of recursive record, got %v", err)
+	}
+
+	buffer := bytes.Buffer{}
+	writer, err := NewWriter(&buffer, "Row", []Field{{Name: "id", Schema: schema.AvroLong{}}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Write([]interface{}{"x"}); err == nil {
+		t.Fatal("expected error of string in long column")
+	}
+	if err = writer.Write([]interface{}{nil}); err == nil {
+		t.Fatal("expected error of null in required column")
+	}
+	if err = writer.Write([]interface{}{int64(7)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// bad rows are rolled back
+	data := buffer.Bytes()
+	meta := readFooter(t, data)[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})[0].(map[int16]interface{})[3].(map[int16]interface{})
+	if read := readChunk(t, data, meta, 0, 0); !reflect.DeepEqual(read.values, []interface{}{int64(7)}) {
+		t.Fatalf("expected single value, got %v", read.values)
+	}
+}
+
+func checkSchema(t *testing.T, elements []interface{}) {
+	t.Helper()
+	if len(elements) != len(expectedSchema) {
+		t.Fatalf("expected %d schema elements, got %d", len(expectedSchema), len(elements))
+	}
+	for idx, element := range elements {
+		e := element.(map[int16]interface{})
+		actual := schemaElement{name: string(e[4].([]byte)), repetition: -1, children: -1, converted: -1}
+		if value, found := e[3]; found {
+			actual.repetition = value.(int64)
+		}
+		if value, found := e[5]; found {
+			actual.children = value.(int64)
+		}
+		if value, found := e[6]; found {
+			actual.converted = value.(int64)
+		}
+		if actual != expectedSchema[idx] {
+			t.Errorf("schema element %d: expected %+v, got %+v", idx, expectedSchema[idx], actual)
+		}
+	}
+}
+
+func joinPath(path []interface{}) string {
+	names := make([]string, len(path))
+	for idx, name := range path {
+		names[idx] = string(name.([]byte))
+	}
+	return strings.Join(names, ".")
+}
+
+// expectedLevels returns maximum levels of the column by its expected levels
+func expectedLevels(path string) (int, int) {
+	maxDef, maxRep := 0, 0
+	for _, level := range expectedColumns[path].def {
+		if level > maxDef {
+			maxDef = level
+		}
+	}
+	for _, level := range expectedColumns[path].rep {
+		if level > maxRep {
+			maxRep = level
+		}
+	}
+	return maxDef, maxRep
+}
+
+func readFooter(t *testing.T, data []byte) map[int16]interface{} {
+	t.Helper()
+	if !bytes.HasPrefix(data, magic) || !bytes.HasSuffix(data, magic) {
+		t.Fatal("file doesn't start and end with magic")
+	}
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-length : len(data)-8]
+	r := &thriftReader{data: footer}
+	result, err := r.readStruct()
+	if err != nil {
+		t.Fatalf("failed to read footer: %v", err)
+	}
+	if r.pos != len(footer) {
+		t.Fatalf("footer has %d bytes after metadata", len(footer)-r.pos)
+	}
+	return result
+}
+
+// readChunk reads data pages of column chunk
+func readChunk(t *testing.T, data []byte, meta map[int16]interface{}, maxDef, maxRep int) columnData {
+	t.Helper()
+	start := meta[9].(int64)
+	chunk := data[start : start+meta[7].(int64)]
+	result := columnData{}
+	values, uncompressed := int64(0), int64(0)
+	for len(chunk) > 0 {
+		r := &thriftReader{data: chunk}
+		header, err := r.readStruct()
+		if err != nil {
+			t.Fatalf("failed to read page header: %v", err)
+		}
+		pageSize := int(header[3].(int64))
+		page, err := decompress(meta[4].(int64), chunk[r.pos:r.pos+pageSize])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(page)) != header[2].(int64) {
+			t.Fatalf("expected page of %d bytes, got %d", header[2], len(page))
+		}
+		uncompressed += int64(r.pos) + header[2].(int64)
+		chunk = chunk[r.pos+pageSize:]
+
+		count := int(header[5].(map[int16]interface{})[1].(int64))
+		values += int64(count)
+		rep, def := make([]int, count), make([]int, count)
+		if maxRep > 0 {
+			rep, page = readLevels(t, page, maxRep, count)
+		}
+		if maxDef > 0 {
+			def, page = readLevels(t, page, maxDef, count)
+		}
+		defined := 0
+		for _, level := range def {
+			if level == maxDef {
+				defined++
+			}
+		}
+		result.def = append(result.def, def...)
+		result.rep = append(result.rep, rep...)
+		result.values = append(result.values, readValues(t, meta[1].(int64), page, defined)...)
+	}
+	if values != meta[5].(int64) || uncompressed != meta[6].(int64) {
+		t.Fatalf("expected %d values of %d bytes, got %d of %d bytes", meta[5], meta[6], values, uncompressed)
+	}
+	return result
+}
+
+func decompress(codec int64, data []byte) ([]byte, error) {
+	switch codec {
+	case codecSnappy:
+		return snappy.Decode(data)
+	case codecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	}
+	return data, nil
+}
+
+// readLevels reads levels in RLE/bit-packing hybrid encoding prefixed with length
+func readLevels(t *testing.T, page []byte, maxLevel, count int) ([]int, []byte) {
+	t.Helper()
+	length := int(binary.LittleEndian.Uint32(page))
+	encoded, rest := page[4:4+length], page[4+length:]
+	width := bits.Len(uint(maxLevel))
+	var levels []int
+	for len(encoded) > 0 {
+		header, n := binary.Uvarint(encoded)
+		encoded = encoded[n:]
+		if header&1 == 0 {
+			value := 0
+			for idx := 0; idx < (width+7)/8; idx++ {
+				value |= int(encoded[idx]) << (8 * idx)
+			}
+			encoded = encoded[(width+7)/8:]
+			for idx := uint64(0); idx < header>>1; idx++ {
+				levels = append(levels, value)
+			}
+		} else {
+			packed := int(header>>1) * 8
+			for idx := 0; idx < packed; idx++ {
+				value := 0
+				for bit := 0; bit < width; bit++ {
+					pos := idx*width + bit
+					value |= int(encoded[pos/8]>>(pos%8)&1) << bit
+				}
+				levels = append(levels, value)
+			}
+			encoded = encoded[packed*width/8:]
+		}
+	}
+	if len(levels) < count {
+		t.Fatalf("expected %d levels, got %d", count, len(levels))
+	}
+	return levels[:count], rest
+}
+
+// readValues reads values in plain encoding, byte arrays are returned as strings
+func readValues(t *testing.T, physicalType int64, page []byte, count int) []interface{} {
+	t.Helper()
+	values := make([]interface{}, 0, count)
+	for idx := 0; idx < count; idx++ {
+		switch physicalType {
+		case typeInt32:
+			values = append(values, int32(binary.LittleEndian.Uint32(page)))
+			page = page[4:]
+		case typeInt64:
+			values = append(values, int64(binary.LittleEndian.Uint64(page)))
+			page = page[8:]
+		case typeByteArray:
+			length := binary.LittleEndian.Uint32(page)
+			values = append(values, string(page[4:4+length]))
+			page = page[4+length:]
+		default:
+			t.Fatalf("unexpected physical type %d", physicalType)
+		}
+	}
+	if len(page) != 0 {
+		t.Fatalf("page has %d bytes after values", len(page))
+	}
+	return values
+}
+
+///////////////////////
+
+// thriftReader reads thrift compact protocol into maps of field ids, lists, int64, bool
+// and byte slices
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.pos++
+	return r.data[r.pos-1], nil
+}
+
+func (r *thriftReader) varint() (uint64, error) {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return value, nil
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	value, err := r.varint()
+	return int64(value>>1) ^ -int64(value&1), err
+}
+
+func (r *thriftReader) readStruct() (map[int16]interface{}, error) {
+	result := make(map[int16]interface{})
+	last := int16(0)
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return result, nil
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			value, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(value)
+		}
+		fieldType := header & 0x0f
+		var value interface{}
+		switch fieldType {
+		case thriftBooleanTrue:
+			value = true
+		case thriftBooleanFalse:
+			value = false
+		default:
+			if value, err = r.readValue(fieldType); err != nil {
+				return nil, fmt.Errorf("failed to read field %d: %w", id, err)
+			}
+		}
+		result[id] = value
+		last = id
+	}
+}
+
+func (r *thriftReader) readValue(valueType byte) (interface{}, error) {
+	switch valueType {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		length, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(r.data)-r.pos) < length {
+			return nil, io.ErrUnexpectedEOF
+		}
+		r.pos += int(length)
+		return r.data[r.pos-int(length) : r.pos], nil
+	case thriftList:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		list := []interface{}{}
+		for idx := uint64(0); idx < size; idx++ {
+			item, err := r.readValue(header & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case thriftStruct:
+		return r.readStruct()
+	}
+	return nil, fmt.Errorf("unexpected thrift type %d", valueType)
+}
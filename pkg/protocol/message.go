@@ -0,0 +1,89 @@
+package protocol
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+	"io"
+)
+
+type Param struct {
+	Name string
+	Doc  string
+	Type schema.ItemSchema
+	// Default is default value as decoded from json
+	Default    interface{}
+	HasDefault bool
+}
+
+// DefaultValue returns default value of the parameter converted to be written with its type
+func (p Param) DefaultValue() (interface{}, error) {
+	if !p.HasDefault {
+		return nil, fmt.Errorf("parameter %s has no default value", p.Name)
+	}
+	return schema.DefaultFromJSON(p.Type, p.Default)
+}
+
+// Message is definition of protocol message. Request is encoded like a record with
+// parameters as fields, errors are encoded as union of string and declared errors.
+type Message struct {
+	Name     string
+	Doc      string
+	Request  []Param
+	Response schema.ItemSchema
+	Errors   schema.ItemSchema
+	OneWay   bool
+}
+
+func (m *Message) ReadRequest(r io.Reader) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(m.Request))
+	for _, param := range m.Request {
+		if value, err := param.Type.Read(r); err != nil {
+			return nil, fmt.Errorf("failed reading parameter %s of message %s: %w", param.Name, m.Name, err)
+		} else {
+			result[param.Name] = value
+		}
+	}
+	return result, nil
+}
+
+// WriteRequest writes request parameters, missing parameters are written with their defaults
+func (m *Message) WriteRequest(w io.Writer, params map[string]interface{}) error {
+	for _, param := range m.Request {
+		value, present := params[param.Name]
+		if !present {
+			if !param.HasDefault {
+				return fmt.Errorf("parameter %s of message %s is missing and has no default", param.Name, m.Name)
+			}
+			var err error
+			if value, err = param.DefaultValue(); err != nil {
+				return fmt.Errorf("parameter %s of message %s: %w", param.Name, m.Name, err)
+			}
+		}
+		if err := param.Type.Write(w, value); err != nil {
+			return fmt.Errorf("failed writing parameter %s of message %s: %w", param.Name, m.Name, err)
+		}
+	}
+	return nil
+}
+
+// RequestSchema returns schema reading and writing request parameters of the message as a record
+func (m *Message) RequestSchema() schema.ItemSchema {
+	return requestSchema{message: m}
+}
+
+///////////////////////
+
+type requestSchema struct {
+	message *Message
+}
+
+func (s requestSchema) Read(r io.Reader) (interface{}, error) {
+	return s.message.ReadRequest(r)
+}
+
+func (s requestSchema) Write(w io.Writer, value interface{}) error {
+	if params, ok := value.(map[string]interface{}); ok {
+		return s.message.WriteRequest(w, params)
+	}
+	return fmt.Errorf("request of message %s should be map, got %T", s.message.Name, value)
+}
@@ -0,0 +1,232 @@
+package protocol
+
+import (
+	"avroparser/pkg/idl"
+	"avroparser/pkg/schema"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Protocol is parsed avro protocol declaration
+type Protocol struct {
+	Name      string
+	Namespace string
+	Doc       string
+	// Types are named types declared in types array of the protocol in declaration order
+	Types    []schema.ItemSchema
+	Messages map[string]*Message
+	parser   *schema.Parser
+	text     []byte
+}
+
+func (p *Protocol) FullName() string {
+	if p.Namespace == "" {
+		return p.Name
+	}
+	return p.Namespace + "." + p.Name
+}
+
+// JSON returns json declaration of the protocol
+func (p *Protocol) JSON() []byte {
+	return p.text
+}
+
+// MD5 returns hash of json declaration of the protocol used in rpc handshake
+func (p *Protocol) MD5() [16]byte {
+	return md5.Sum(p.text)
+}
+
+// Message returns definition of the message by its name
+func (p *Protocol) Message(name string) (*Message, bool) {
+	message, found := p.Messages[name]
+	return message, found
+}
+
+// MessageNames returns sorted names of all messages of the protocol
+func (p *Protocol) MessageNames() []string {
+	names := make([]string, 0, len(p.Messages))
+	for name := range p.Messages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NamedType returns named type declared in the protocol by its full name
+func (p *Protocol) NamedType(name string) (schema.ItemSchema, bool) {
+	return p.parser.NamedType(name)
+}
+
+func ParseFile(fileName string) (*Protocol, error) {
+	if strings.HasSuffix(fileName, ".avdl") {
+		document, err := idl.ParseFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if document.Protocol == nil {
+			return nil, fmt.Errorf("%s declares schema, not protocol", fileName)
+		}
+		return Parse(document.Protocol)
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSON(data)
+}
+
+func ParseJSON(data []byte) (*Protocol, error) {
+	var jsonProtocol map[string]interface{}
+	if err := json.Unmarshal(data, &jsonProtocol); err != nil {
+		return nil, fmt.Errorf("failed to parse json %w", err)
+	}
+	result, err := Parse(jsonProtocol)
+	if err != nil {
+		return nil, err
+	}
+	result.text = data
+	return result, nil
+}
+
+// Parse parses protocol from its json declaration in .avpr format
+func Parse(data map[string]interface{}) (*Protocol, error) {
+	result := &Protocol{Messages: make(map[string]*Message), parser: schema.NewParser()}
+	var err error
+	if result.text, err = json.Marshal(data); err != nil {
+		return nil, fmt.Errorf("failed to write protocol json: %w", err)
+	}
+	if result.Name, err = getString(data, "protocol", true); err != nil {
+		return nil, err
+	}
+	if result.Namespace, err = getString(data, "namespace", false); err != nil {
+		return nil, err
+	}
+	if result.Doc, err = getString(data, "doc", false); err != nil {
+		return nil, err
+	}
+	if strings.Contains(result.Name, ".") && result.Namespace == "" {
+		idx := strings.LastIndex(result.Name, ".")
+		result.Namespace, result.Name = result.Name[:idx], result.Name[idx+1:]
+	}
+
+	if types, present := data["types"]; present {
+		typesArray, ok := types.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("types of protocol %s should be array, got %T", result.Name, types)
+		}
+		if result.Types, err = result.parser.ParseInNamespace(result.Namespace, typesArray...); err != nil {
+			return nil, fmt.Errorf("failed to parse types of protocol %s: %w", result.Name, err)
+		}
+	}
+
+	if messages, present := data["messages"]; present {
+		messagesMap, ok := messages.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("messages of protocol %s should be object, got %T", result.Name, messages)
+		}
+		names := make([]string, 0, len(messagesMap))
+		for name := range messagesMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if message, err := result.readMessage(name, messagesMap[name]); err != nil {
+				return nil, fmt.Errorf("failed to parse message %s: %w", name, err)
+			} else {
+				result.Messages[name] = message
+			}
+		}
+	}
+	return result, nil
+}
+
+func (p *Protocol) readMessage(name string, data interface{}) (*Message, error) {
+	messageData, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("message should be object, got %T", data)
+	}
+	message := &Message{Name: name}
+	var err error
+	if message.Doc, err = getString(messageData, "doc", false); err != nil {
+		return nil, err
+	}
+	if oneWay, present := messageData["one-way"]; present {
+		if message.OneWay, ok = oneWay.(bool); !ok {
+			return nil, fmt.Errorf("one-way should be boolean, got %T", oneWay)
+		}
+	}
+
+	requestData, ok := messageData["request"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("request should be array of parameters, got %T", messageData["request"])
+	}
+	// all schemas of the message are parsed at once: parameters, response and errors union
+	jsonSchemas := make([]interface{}, 0, len(requestData)+2)
+	for _, item := range requestData {
+		paramData, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("request parameter should be object, got %T", item)
+		}
+		param := Param{}
+		if param.Name, err = getString(paramData, "name", true); err != nil {
+			return nil, err
+		}
+		if param.Doc, err = getString(paramData, "doc", false); err != nil {
+			return nil, err
+		}
+		param.Default, param.HasDefault = paramData["default"]
+		paramType, present := paramData["type"]
+		if !present {
+			return nil, fmt.Errorf("type of request parameter %s is missing", param.Name)
+		}
+		message.Request = append(message.Request, param)
+		jsonSchemas = append(jsonSchemas, paramType)
+	}
+
+	response, present := messageData["response"]
+	if !present {
+		return nil, fmt.Errorf("response is missing")
+	}
+	// errors union always includes string for undeclared system errors
+	errorsUnion := []interface{}{"string"}
+	if errors, present := messageData["errors"]; present {
+		errorsArray, ok := errors.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("errors should be array, got %T", errors)
+		}
+		errorsUnion = append(errorsUnion, errorsArray...)
+	}
+	if message.OneWay && (response != "null" || len(errorsUnion) > 1) {
+		return nil, fmt.Errorf("one-way message should have null response and no errors")
+	}
+	jsonSchemas = append(jsonSchemas, response, errorsUnion)
+
+	schemas, err := p.parser.ParseInNamespace(p.Namespace, jsonSchemas...)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range message.Request {
+		message.Request[idx].Type = schemas[idx]
+	}
+	message.Response = schemas[len(schemas)-2]
+	message.Errors = schemas[len(schemas)-1]
+	return message, nil
+}
+
+func getString(data map[string]interface{}, key string, required bool) (string, error) {
+	value, present := data[key]
+	if !present {
+		if required {
+			return "", fmt.Errorf("required attribute %s is missing", key)
+		}
+		return "", nil
+	}
+	if result, ok := value.(string); ok {
+		return result, nil
+	}
+	return "", fmt.Errorf("attribute %s should be string, got %T", key, value)
+}
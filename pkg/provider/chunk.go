@@ -6,9 +6,10 @@ import (
 )
 
 type DataChunk struct {
-	name   string
-	schema schema.ItemSchema
-	data   interface{}
+	name     string
+	schema   schema.ItemSchema
+	schemaId string
+	data     interface{}
 }
 
 func (ch DataChunk) Name() string {
@@ -26,3 +27,19 @@ func NewDataChunk(name string, s schema.ItemSchema, r io.Reader) (DataChunk, err
 	}
 	return DataChunk{name: name, schema: s, data: data}, nil
 }
+
+func (ch DataChunk) Schema() schema.ItemSchema {
+	return ch.schema
+}
+
+// SchemaId identifies schema of the chunk within the stream, like id of the schema in
+// registry, so that schemas can be told apart without comparing them. It is empty if the
+// schema has no identity.
+func (ch DataChunk) SchemaId() string {
+	return ch.schemaId
+}
+
+func (ch DataChunk) withSchemaId(id string) DataChunk {
+	ch.schemaId = id
+	return ch
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"avroparser/pkg/schema"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const confluentMagicByte = 0
+
+// ConfluentStreamConverter reads messages in confluent wire format: magic byte 0,
+// 4-byte big-endian schema id and avro binary payload
+type ConfluentStreamConverter struct {
+	registry SchemaRegistry
+}
+
+func NewConfluentStreamConverter(registry SchemaRegistry) *ConfluentStreamConverter {
+	return &ConfluentStreamConverter{registry: registry}
+}
+
+func (c *ConfluentStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	header := make([]byte, 5)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read confluent header: %w", err)
+	}
+	if header[0] != confluentMagicByte {
+		return nil, fmt.Errorf("unknown magic byte %d in confluent header", header[0])
+	}
+	id := binary.BigEndian.Uint32(header[1:])
+	s, err := c.registry.SchemaById(id)
+	if err != nil {
+		return nil, err
+	}
+	chunk, err := NewDataChunk("", s, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data with schema id %d: %w", id, err)
+	}
+	return []DataChunk{chunk.withSchemaId(fmt.Sprintf("confluent:%d", id))}, nil
+}
+
+///////////////////////
+
+type ConfluentEncoder struct {
+	schema schema.ItemSchema
+	header []byte
+}
+
+// NewConfluentEncoder creates encoder for schema with the id obtained from the registry
+func NewConfluentEncoder(itemSchema schema.ItemSchema, id uint32) *ConfluentEncoder {
+	header := make([]byte, 5)
+	header[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(header[1:], id)
+	return &ConfluentEncoder{schema: itemSchema, header: header}
+}
+
+func (e *ConfluentEncoder) Encode(writer io.Writer, value interface{}) error {
+	if _, err := writer.Write(e.header); err != nil {
+		return err
+	}
+	return e.schema.Write(writer, value)
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"avroparser/pkg/schema"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+)
+
+// DirectorySchemaProvider loads all .avsc files from a directory tree. Named types defined
+// in one file can be referenced from the other files.
+type DirectorySchemaProvider struct {
+	parser *schema.Parser
+	files  map[string]schema.ItemSchema
+}
+
+type schemaFile struct {
+	path string
+	data interface{}
+}
+
+func NewDirectorySchemaProvider(directory string) (*DirectorySchemaProvider, error) {
+	return NewFSSchemaProvider(os.DirFS(directory))
+}
+
+func NewFSSchemaProvider(fsys fs.FS) (*DirectorySchemaProvider, error) {
+	pending := make([]schemaFile, 0)
+	err := fs.WalkDir(fsys, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || path.Ext(filePath) != ".avsc" {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		file := schemaFile{path: filePath}
+		if err = json.Unmarshal(data, &file.data); err != nil {
+			return fmt.Errorf("failed to parse json from %s: %w", filePath, err)
+		}
+		pending = append(pending, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].path < pending[j].path })
+
+	provider := &DirectorySchemaProvider{parser: schema.NewParser(), files: make(map[string]schema.ItemSchema)}
+	// files are parsed in dependency order: files referencing unknown types are retried
+	// after the other files are parsed
+	for len(pending) > 0 {
+		failed := make([]schemaFile, 0)
+		for _, file := range pending {
+			var referenceErr *schema.ReferenceError
+			if roots, err := provider.parser.Parse(file.data); errors.As(err, &referenceErr) {
+				failed = append(failed, file)
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to parse schema from %s: %w", file.path, err)
+			} else {
+				provider.files[file.path] = roots[0]
+			}
+		}
+		if len(failed) == len(pending) {
+			return provider, provider.parseCyclic(failed)
+		}
+		pending = failed
+	}
+	return provider, nil
+}
+
+// parseCyclic parses files that reference each other in one go, error names the file with
+// reference that can't be resolved
+func (p *DirectorySchemaProvider) parseCyclic(files []schemaFile) error {
+	data := make([]interface{}, len(files))
+	for idx, file := range files {
+		data[idx] = file.data
+	}
+	roots, err := p.parser.Parse(data...)
+	var referenceErr *schema.ReferenceError
+	if errors.As(err, &referenceErr) {
+		return fmt.Errorf("failed to parse schema from %s: %w", files[referenceErr.Root].path, err)
+	} else if err != nil {
+		return fmt.Errorf("failed to parse schemas of %d files referencing each other: %w", len(files), err)
+	}
+	for idx, file := range files {
+		p.files[file.path] = roots[idx]
+	}
+	return nil
+}
+
+// Schema returns named type by its full name
+func (p *DirectorySchemaProvider) Schema(name string) (schema.ItemSchema, error) {
+	if s, found := p.parser.NamedType(name); found {
+		return s, nil
+	}
+	return nil, fmt.Errorf("schema with name %s is not found", name)
+}
+
+// FileSchema returns schema defined in the file with the path relative to the directory
+func (p *DirectorySchemaProvider) FileSchema(filePath string) (schema.ItemSchema, error) {
+	if s, found := p.files[filePath]; found {
+		return s, nil
+	}
+	return nil, fmt.Errorf("schema file %s is not found", filePath)
+}
+
+func (p *DirectorySchemaProvider) Schemas() map[string]schema.ItemSchema {
+	return p.files
+}
+
+// Parse parses schema that may reference named types from the directory
+func (p *DirectorySchemaProvider) Parse(data []byte) (schema.ItemSchema, error) {
+	roots, err := p.parser.ParseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return roots[0], nil
+}
@@ -19,9 +19,7 @@ func NewCountingReader(reader io.Reader) CountingReader {
 
 func (r *CountingReader) Read(p []byte) (n int, err error) {
 	n, err = r.reader.Read(p)
-	if err != nil {
-		r.bytesRead += n
-	}
+	r.bytesRead += n
 	return
 }
 
@@ -29,10 +27,19 @@ func (r *CountingReader) ReadBytes() int {
 	return r.bytesRead
 }
 
+func (r *CountingReader) BytesRead() uint64 {
+	return uint64(r.bytesRead)
+}
+
+// IsEof checks whether the error is caused by the end of the stream, decode errors of
+// complete messages are never the end of the stream even if decoder ran out of bytes
 func IsEof(err error) bool {
 	if err == io.EOF {
 		return true
 	}
+	if _, ok := err.(*DecodeError); ok {
+		return false
+	}
 	unwrapped := errors.Unwrap(err)
 	if nil != unwrapped {
 		if unwrapped == err {
@@ -43,3 +50,20 @@ func IsEof(err error) bool {
 	}
 	return false
 }
+
+///////////////////////
+
+// DecodeError is returned when message, frame or payload was read completely but can't be
+// decoded, so the stream can be read further. Data is the raw message.
+type DecodeError struct {
+	Data []byte
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+type FrameLength string
+
+const (
+	// FrameLengthVarint is unsigned LEB128 varint, as used by protobuf delimited streams
+	FrameLengthVarint         = FrameLength("varint")
+	FrameLengthBigEndian32    = FrameLength("be32")
+	FrameLengthLittleEndian32 = FrameLength("le32")
+
+	maxFrameSize = 1 << 28
+)
+
+func ParseFrameLength(name string) (FrameLength, error) {
+	switch FrameLength(name) {
+	case FrameLengthVarint, FrameLengthBigEndian32, FrameLengthLittleEndian32:
+		return FrameLength(name), nil
+	default:
+		return "", fmt.Errorf("unknown frame length encoding %s, expected one of varint, be32, le32", name)
+	}
+}
+
+// FramedStreamConverter reads length-prefixed frames and decodes each frame independently
+// with the wrapped converter, so a corrupt frame doesn't affect the following ones.
+type FramedStreamConverter struct {
+	frameLength FrameLength
+	converter   StreamConverter
+}
+
+func NewFramedStreamConverter(frameLength FrameLength, converter StreamConverter) *FramedStreamConverter {
+	return &FramedStreamConverter{frameLength: frameLength, converter: converter}
+}
+
+func (c *FramedStreamConverter) readLength(reader io.Reader) (uint64, error) {
+	switch c.frameLength {
+	case FrameLengthVarint:
+		byteReader, ok := reader.(io.ByteReader)
+		if !ok {
+			byteReader = singleByteReader{r: reader}
+		}
+		return binary.ReadUvarint(byteReader)
+	case FrameLengthBigEndian32, FrameLengthLittleEndian32:
+		data := make([]byte, 4)
+		if n, err := io.ReadFull(reader, data); err != nil {
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		if c.frameLength == FrameLengthBigEndian32 {
+			return uint64(binary.BigEndian.Uint32(data)), nil
+		}
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	default:
+		return 0, fmt.Errorf("unknown frame length encoding %s", c.frameLength)
+	}
+}
+
+// ReadFrame returns contents of the next frame in the stream
+func (c *FramedStreamConverter) ReadFrame(reader io.Reader) ([]byte, error) {
+	length, err := c.readLength(reader)
+	if err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read frame length: %w", err)
+	}
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("frame length %d exceeds maximum frame size %d", length, maxFrameSize)
+	}
+	frame := make([]byte, length)
+	if _, err = io.ReadFull(reader, frame); err != nil {
+		return nil, fmt.Errorf("failed to read frame of length %d: %w", length, err)
+	}
+	return frame, nil
+}
+
+func (c *FramedStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	frame, err := c.ReadFrame(reader)
+	if err != nil {
+		return nil, err
+	}
+	frameReader := bytes.NewReader(frame)
+	chunks, err := c.converter.Next(frameReader)
+	if err != nil {
+		return nil, &DecodeError{Data: frame, Err: fmt.Errorf("failed to decode frame of length %d: %w", len(frame), err)}
+	}
+	if frameReader.Len() > 0 {
+		return nil, &DecodeError{Data: frame, Err: fmt.Errorf("%d bytes left in frame of length %d after decoding", frameReader.Len(), len(frame))}
+	}
+	return chunks, nil
+}
+
+type singleByteReader struct {
+	r io.Reader
+}
+
+func (s singleByteReader) ReadByte() (byte, error) {
+	data := []byte{0}
+	if _, err := io.ReadFull(s.r, data); err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+func (s singleByteReader) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+// WriteFrame writes data prefixed with its length in the frame length encoding
+func WriteFrame(w io.Writer, frameLength FrameLength, data []byte) error {
+	prefix := make([]byte, binary.MaxVarintLen64)
+	switch frameLength {
+	case FrameLengthVarint:
+		prefix = prefix[:binary.PutUvarint(prefix, uint64(len(data)))]
+	case FrameLengthBigEndian32:
+		binary.BigEndian.PutUint32(prefix, uint32(len(data)))
+		prefix = prefix[:4]
+	case FrameLengthLittleEndian32:
+		binary.LittleEndian.PutUint32(prefix, uint32(len(data)))
+		prefix = prefix[:4]
+	default:
+		return fmt.Errorf("unknown frame length encoding %s", frameLength)
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	glueHeaderVersion = 3
+
+	GlueCompressionNone = 0
+	GlueCompressionZlib = 5
+)
+
+type SchemaVersionId [16]byte
+
+func (id SchemaVersionId) String() string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+func ParseSchemaVersionId(value string) (SchemaVersionId, error) {
+	var id SchemaVersionId
+	data, err := hex.DecodeString(strings.ReplaceAll(value, "-", ""))
+	if err != nil || len(data) != len(id) {
+		return id, fmt.Errorf("invalid schema version id %q", value)
+	}
+	copy(id[:], data)
+	return id, nil
+}
+
+// GlueSchemaRegistry looks up schemas by glue schema version ids
+type GlueSchemaRegistry interface {
+	SchemaByVersionId(id SchemaVersionId) (schema.ItemSchema, error)
+}
+
+// GlueStreamConverter reads messages in glue schema registry format: header version byte 3,
+// compression byte, 16-byte schema version uuid and avro binary payload, zlib compressed
+// if compression byte is 5
+type GlueStreamConverter struct {
+	registry GlueSchemaRegistry
+}
+
+func NewGlueStreamConverter(registry GlueSchemaRegistry) *GlueStreamConverter {
+	return &GlueStreamConverter{registry: registry}
+}
+
+func (c *GlueStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	header := make([]byte, 18)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read glue header: %w", err)
+	}
+	if header[0] != glueHeaderVersion {
+		return nil, fmt.Errorf("unknown header version %d in glue header", header[0])
+	}
+	var id SchemaVersionId
+	copy(id[:], header[2:])
+	s, err := c.registry.SchemaByVersionId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch header[1] {
+	case GlueCompressionNone:
+		chunk, err := NewDataChunk("", s, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data with schema version %s: %w", id, err)
+		}
+		return []DataChunk{chunk.withSchemaId("glue:" + id.String())}, nil
+	case GlueCompressionZlib:
+		// reading byte by byte keeps decompressor from consuming the next message
+		payload, err := zlib.NewReader(singleByteReader{r: reader})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zlib header of data with schema version %s: %w", id, err)
+		}
+		chunk, err := NewDataChunk("", s, payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data with schema version %s: %w", id, err)
+		}
+		if left, err := io.Copy(ioutil.Discard, payload); err != nil {
+			return nil, fmt.Errorf("failed to decompress data with schema version %s: %w", id, err)
+		} else if left != 0 {
+			return nil, fmt.Errorf("%d bytes left in compressed data with schema version %s after decoding", left, id)
+		}
+		return []DataChunk{chunk.withSchemaId("glue:" + id.String())}, nil
+	}
+	return nil, fmt.Errorf("unknown compression %d in glue header", header[1])
+}
+
+///////////////////////
+
+type GlueEncoder struct {
+	schema   schema.ItemSchema
+	header   []byte
+	compress bool
+}
+
+func NewGlueEncoder(itemSchema schema.ItemSchema, id SchemaVersionId, compress bool) *GlueEncoder {
+	header := []byte{glueHeaderVersion, GlueCompressionNone}
+	if compress {
+		header[1] = GlueCompressionZlib
+	}
+	return &GlueEncoder{schema: itemSchema, header: append(header, id[:]...), compress: compress}
+}
+
+func (e *GlueEncoder) Encode(writer io.Writer, value interface{}) error {
+	if !e.compress {
+		if _, err := writer.Write(e.header); err != nil {
+			return err
+		}
+		return e.schema.Write(writer, value)
+	}
+	buffer := bytes.Buffer{}
+	buffer.Write(e.header)
+	compressor := zlib.NewWriter(&buffer)
+	if err := e.schema.Write(compressor, value); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+	_, err := writer.Write(buffer.Bytes())
+	return err
+}
+
+///////////////////////
+
+// DirectoryGlueSchemaRegistry reads schemas from files named <uuid>.avsc in a directory,
+// it is a local stand-in for glue schema registry
+type DirectoryGlueSchemaRegistry struct {
+	directory string
+	lock      sync.Mutex
+	schemas   map[SchemaVersionId]schema.ItemSchema
+}
+
+func NewDirectoryGlueSchemaRegistry(directory string) *DirectoryGlueSchemaRegistry {
+	return &DirectoryGlueSchemaRegistry{directory: directory, schemas: make(map[SchemaVersionId]schema.ItemSchema)}
+}
+
+func (r *DirectoryGlueSchemaRegistry) SchemaByVersionId(id SchemaVersionId) (schema.ItemSchema, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if s, found := r.schemas[id]; found {
+		return s, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(r.directory, id.String()+".avsc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema with version id %s: %w", id, err)
+	}
+	s, err := schema.ParseSchemaJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema with version id %s: %w", id, err)
+	}
+	r.schemas[id] = s
+	return s, nil
+}
@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	KcatKeyField     = "key"
+	KcatPayloadField = "payload"
+)
+
+// KcatStreamConverter reads line-delimited json envelopes produced by kcat -J and returns
+// envelope fields as chunks with key and payload decoded by the converters. Key is left as
+// it is if key converter is nil. Key and payload are either raw strings where bytes are
+// kept as written by kcat or base64 strings.
+type KcatStreamConverter struct {
+	key    StreamConverter
+	value  StreamConverter
+	base64 bool
+	lines  *bufio.Reader
+	line   int
+	read   uint64
+}
+
+func NewKcatStreamConverter(key, value StreamConverter, base64 bool) *KcatStreamConverter {
+	return &KcatStreamConverter{key: key, value: value, base64: base64}
+}
+
+func (c *KcatStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	if c.lines == nil {
+		// envelopes are read from the first reader, lines are buffered
+		c.lines = bufio.NewReader(reader)
+	}
+	var line []byte
+	for len(bytes.TrimSpace(line)) == 0 {
+		var err error
+		line, err = c.lines.ReadBytes('\n')
+		c.line++
+		c.read += uint64(len(line))
+		if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+			return nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	chunks, err := c.convert(line)
+	if err != nil {
+		return nil, &DecodeError{Data: line, Err: fmt.Errorf("line %d: %w", c.line, err)}
+	}
+	return chunks, nil
+}
+
+// BytesRead returns length of envelope lines read so far, lines are read ahead from the
+// stream
+func (c *KcatStreamConverter) BytesRead() uint64 {
+	return c.read
+}
+
+func (c *KcatStreamConverter) convert(line []byte) ([]DataChunk, error) {
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse kcat envelope: %w", err)
+	}
+	names := make([]string, 0, len(envelope))
+	for name := range envelope {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]DataChunk, 0, len(envelope))
+	for _, name := range names {
+		var converter StreamConverter
+		switch name {
+		case KcatKeyField:
+			converter = c.key
+		case KcatPayloadField:
+			converter = c.value
+		}
+		if converter == nil || bytes.Equal(envelope[name], []byte("null")) {
+			decoder := json.NewDecoder(bytes.NewReader(envelope[name]))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			result = append(result, DataChunk{name: name, data: value})
+			continue
+		}
+		data, err := c.bytes(envelope[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		chunks, err := decodeAll(converter, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s of message at offset %s: %w", name, envelope["offset"], err)
+		}
+		result = appendNamed(result, name, chunks)
+	}
+	return result, nil
+}
+
+func (c *KcatStreamConverter) bytes(value json.RawMessage) ([]byte, error) {
+	if c.base64 {
+		var encoded string
+		if err := json.Unmarshal(value, &encoded); err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+	return unquoteRaw(value)
+}
+
+// unquoteRaw unquotes json string keeping bytes that are not valid utf-8 as they are,
+// escaped characters below 0x80 are single bytes as kcat escapes control bytes this way
+func unquoteRaw(value []byte) ([]byte, error) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return nil, fmt.Errorf("expected string, got %s", value)
+	}
+	value = value[1 : len(value)-1]
+	result := make([]byte, 0, len(value))
+	for idx := 0; idx < len(value); idx++ {
+		if value[idx] != '\\' {
+			result = append(result, value[idx])
+			continue
+		}
+		idx++
+		if idx == len(value) {
+			return nil, fmt.Errorf("unterminated escape sequence")
+		}
+		switch value[idx] {
+		case '"', '\\', '/':
+			result = append(result, value[idx])
+		case 'b':
+			result = append(result, '\b')
+		case 'f':
+			result = append(result, '\f')
+		case 'n':
+			result = append(result, '\n')
+		case 'r':
+			result = append(result, '\r')
+		case 't':
+			result = append(result, '\t')
+		case 'u':
+			if idx+4 >= len(value) {
+				return nil, fmt.Errorf("truncated unicode escape sequence")
+			}
+			code, err := strconv.ParseUint(string(value[idx+1:idx+5]), 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unicode escape sequence %s", value[idx-1:idx+5])
+			}
+			idx += 4
+			char := rune(code)
+			if utf16.IsSurrogate(char) && idx+6 < len(value) && value[idx+1] == '\\' && value[idx+2] == 'u' {
+				if low, err := strconv.ParseUint(string(value[idx+3:idx+7]), 16, 16); err == nil {
+					char = utf16.DecodeRune(char, rune(low))
+					idx += 6
+				}
+			}
+			if char < utf8.RuneSelf {
+				result = append(result, byte(char))
+			} else {
+				result = utf8.AppendRune(result, char)
+			}
+		default:
+			return nil, fmt.Errorf("invalid escape sequence \\%c", value[idx])
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	KeyChunkName     = "key"
+	ValueChunkName   = "value"
+	HeadersChunkName = "headers"
+)
+
+// KeyValueStreamConverter decodes key, value and optional headers of each message with
+// separate converters and returns them as chunks named key, value and headers
+type KeyValueStreamConverter struct {
+	key     StreamConverter
+	value   StreamConverter
+	headers StreamConverter
+}
+
+// NewKeyValueStreamConverter creates converter reading key followed by value, headers
+// converter may be nil if messages have no headers. Otherwise headers follow the value.
+func NewKeyValueStreamConverter(key, value, headers StreamConverter) *KeyValueStreamConverter {
+	return &KeyValueStreamConverter{key: key, value: value, headers: headers}
+}
+
+func (c *KeyValueStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	result := make([]DataChunk, 0, 3)
+	keyChunks, err := c.key.Next(reader)
+	if err != nil {
+		return nil, err
+	}
+	result = appendNamed(result, KeyChunkName, keyChunks)
+	valueChunks, err := c.value.Next(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message value: %w", err)
+	}
+	result = appendNamed(result, ValueChunkName, valueChunks)
+	if c.headers != nil {
+		headerChunks, err := c.headers.Next(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message headers: %w", err)
+		}
+		result = appendNamed(result, HeadersChunkName, headerChunks)
+	}
+	return result, nil
+}
+
+// appendNamed names single unnamed chunk with the name, other chunks are prefixed with it
+func appendNamed(result []DataChunk, name string, chunks []DataChunk) []DataChunk {
+	for _, chunk := range chunks {
+		if chunk.name == "" {
+			chunk.name = name
+		} else {
+			chunk.name = name + "." + chunk.name
+		}
+		result = append(result, chunk)
+	}
+	return result
+}
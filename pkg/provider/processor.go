@@ -2,7 +2,6 @@ package provider
 
 import (
 	"avroparser/pkg/schema"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -18,6 +17,7 @@ type StreamConverter interface {
 
 type StaticFileSchema struct {
 	schema schema.ItemSchema
+	id     string
 }
 
 func NewStaticFileStreamConverter(fileName string) (*StaticFileSchema, error) {
@@ -25,22 +25,28 @@ func NewStaticFileStreamConverter(fileName string) (*StaticFileSchema, error) {
 	if nil != err {
 		return nil, err
 	}
-	jsonSchema := make(map[string]interface{})
-	err = json.Unmarshal(schemaData, &jsonSchema)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse json %w", err)
-	}
-	parsedSchema, err := schema.ParseSchema(jsonSchema)
+	parsedSchema, err := schema.ParseSchemaJSON(schemaData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse schema %w", err)
 	}
-	return &StaticFileSchema{schema: parsedSchema}, nil
+	return NewStaticStreamConverter(parsedSchema), nil
+}
+
+func NewStaticStreamConverter(s schema.ItemSchema) *StaticFileSchema {
+	converter := &StaticFileSchema{schema: s}
+	// schema of the converter never changes, so the converter identifies it
+	converter.id = fmt.Sprintf("static:%p", converter)
+	return converter
+}
+
+func (sfs *StaticFileSchema) Schema() schema.ItemSchema {
+	return sfs.schema
 }
 
 func (sfs *StaticFileSchema) Next(reader io.Reader) ([]DataChunk, error) {
 	chunk, err := NewDataChunk("", sfs.schema, reader)
 	if nil == err {
-		return []DataChunk{chunk}, nil
+		return []DataChunk{chunk.withSchemaId(sfs.id)}, nil
 	}
 	return nil, err
 }
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+)
+
+// SchemaRegistry looks up schemas by confluent schema registry ids, see registry.Client
+// for the implementation backed by registry REST API
+type SchemaRegistry interface {
+	SchemaById(id uint32) (schema.ItemSchema, error)
+}
+
+type schemaCache struct {
+	lock    sync.Mutex
+	schemas map[uint32]schema.ItemSchema
+}
+
+func (c *schemaCache) get(id uint32, load func(id uint32) ([]byte, error)) (schema.ItemSchema, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if s, found := c.schemas[id]; found {
+		return s, nil
+	}
+	data, err := load(id)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schema.ParseSchemaJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema with id %d: %w", id, err)
+	}
+	c.schemas[id] = s
+	return s, nil
+}
+
+///////////////////////
+
+// DirectorySchemaRegistry reads schemas from files named <id>.avsc in a directory
+type DirectorySchemaRegistry struct {
+	directory string
+	cache     schemaCache
+}
+
+func NewDirectorySchemaRegistry(directory string) *DirectorySchemaRegistry {
+	return &DirectorySchemaRegistry{
+		directory: directory,
+		cache:     schemaCache{schemas: make(map[uint32]schema.ItemSchema)},
+	}
+}
+
+func (r *DirectorySchemaRegistry) SchemaById(id uint32) (schema.ItemSchema, error) {
+	return r.cache.get(id, func(id uint32) ([]byte, error) {
+		data, err := ioutil.ReadFile(filepath.Join(r.directory, fmt.Sprintf("%d.avsc", id)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema with id %d: %w", id, err)
+		}
+		return data, nil
+	})
+}
@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"avroparser/pkg/kafka"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	OffsetChunkName    = "offset"
+	TimestampChunkName = "timestamp"
+)
+
+// KafkaSegmentStreamConverter reads records of kafka log segment and decodes their keys and
+// values with separate converters. Keys are returned as strings if key converter is nil,
+// null keys and values are returned as nil, record headers are returned as strings.
+type KafkaSegmentStreamConverter struct {
+	key     StreamConverter
+	value   StreamConverter
+	segment *kafka.SegmentReader
+}
+
+func NewKafkaSegmentStreamConverter(key, value StreamConverter) *KafkaSegmentStreamConverter {
+	return &KafkaSegmentStreamConverter{key: key, value: value}
+}
+
+func (c *KafkaSegmentStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	if c.segment == nil {
+		// segment is read from the first reader, records are buffered batch by batch
+		c.segment = kafka.NewSegmentReader(reader)
+	}
+	record, err := c.segment.Next()
+	if err != nil {
+		return nil, err
+	}
+	result := []DataChunk{
+		{name: OffsetChunkName, data: record.Offset},
+		{name: TimestampChunkName, data: record.Timestamp},
+	}
+	if record.Key == nil || c.key == nil {
+		var key interface{}
+		if record.Key != nil {
+			key = string(record.Key)
+		}
+		result = append(result, DataChunk{name: KeyChunkName, data: key})
+	} else if keyChunks, err := decodeAll(c.key, record.Key); err != nil {
+		return nil, fmt.Errorf("failed to read key of record at offset %d: %w", record.Offset, err)
+	} else {
+		result = appendNamed(result, KeyChunkName, keyChunks)
+	}
+	if record.Value == nil {
+		result = append(result, DataChunk{name: ValueChunkName})
+	} else if valueChunks, err := decodeAll(c.value, record.Value); err != nil {
+		return nil, fmt.Errorf("failed to read value of record at offset %d: %w", record.Offset, err)
+	} else {
+		result = appendNamed(result, ValueChunkName, valueChunks)
+	}
+	if len(record.Headers) > 0 {
+		headers := make(map[string]interface{}, len(record.Headers))
+		for _, header := range record.Headers {
+			headers[header.Key] = string(header.Value)
+		}
+		result = append(result, DataChunk{name: HeadersChunkName, data: headers})
+	}
+	return result, nil
+}
+
+// BytesRead returns segment offset of the next record, so that records that can't be
+// decoded are reported at their offset rather than at the end of buffered batch
+func (c *KafkaSegmentStreamConverter) BytesRead() uint64 {
+	if c.segment == nil {
+		return 0
+	}
+	return uint64(c.segment.Position())
+}
+
+// decodeAll decodes data with the converter expecting no bytes to be left, errors are
+// DecodeError with the data
+func decodeAll(converter StreamConverter, data []byte) ([]DataChunk, error) {
+	reader := bytes.NewReader(data)
+	chunks, err := converter.Next(reader)
+	if err != nil {
+		return nil, &DecodeError{Data: data, Err: err}
+	}
+	if reader.Len() != 0 {
+		return nil, &DecodeError{Data: data, Err: fmt.Errorf("%d bytes of %d left after decoding", reader.Len(), len(data))}
+	}
+	return chunks, nil
+}
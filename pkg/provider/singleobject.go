@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"avroparser/pkg/schema"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var singleObjectMarker = []byte{0xc3, 0x01}
+
+type FingerprintSchemaStore interface {
+	SchemaByFingerprint(fingerprint uint64) (schema.ItemSchema, error)
+}
+
+type MemorySchemaStore struct {
+	lock    sync.RWMutex
+	schemas map[uint64]schema.ItemSchema
+}
+
+func NewMemorySchemaStore() *MemorySchemaStore {
+	return &MemorySchemaStore{schemas: make(map[uint64]schema.ItemSchema)}
+}
+
+// NewDirectorySchemaStore loads every .avsc file in the directory into memory store
+func NewDirectorySchemaStore(directory string) (*MemorySchemaStore, error) {
+	schemaProvider, err := NewDirectorySchemaProvider(directory)
+	if err != nil {
+		return nil, err
+	}
+	store := NewMemorySchemaStore()
+	for _, s := range schemaProvider.Schemas() {
+		store.Add(s)
+	}
+	return store, nil
+}
+
+func (s *MemorySchemaStore) Add(itemSchema schema.ItemSchema) uint64 {
+	fingerprint := schema.Fingerprint64(itemSchema)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.schemas[fingerprint] = itemSchema
+	return fingerprint
+}
+
+func (s *MemorySchemaStore) SchemaByFingerprint(fingerprint uint64) (schema.ItemSchema, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if itemSchema, found := s.schemas[fingerprint]; found {
+		return itemSchema, nil
+	}
+	return nil, fmt.Errorf("schema with fingerprint %016x is not found", fingerprint)
+}
+
+///////////////////////
+
+// SingleObjectStreamConverter reads messages in avro single object encoding: marker C3 01,
+// 8-byte little-endian CRC-64-AVRO fingerprint of the schema and avro binary payload
+type SingleObjectStreamConverter struct {
+	store FingerprintSchemaStore
+}
+
+func NewSingleObjectStreamConverter(store FingerprintSchemaStore) *SingleObjectStreamConverter {
+	return &SingleObjectStreamConverter{store: store}
+}
+
+func (c *SingleObjectStreamConverter) Next(reader io.Reader) ([]DataChunk, error) {
+	header := make([]byte, 10)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if n == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read single object header: %w", err)
+	}
+	if header[0] != singleObjectMarker[0] || header[1] != singleObjectMarker[1] {
+		return nil, fmt.Errorf("invalid single object marker %x", header[:2])
+	}
+	fingerprint := binary.LittleEndian.Uint64(header[2:])
+	s, err := c.store.SchemaByFingerprint(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	chunk, err := NewDataChunk("", s, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data with schema fingerprint %016x: %w", fingerprint, err)
+	}
+	return []DataChunk{chunk.withSchemaId(fmt.Sprintf("fingerprint:%016x", fingerprint))}, nil
+}
+
+///////////////////////
+
+type SingleObjectEncoder struct {
+	schema schema.ItemSchema
+	header []byte
+}
+
+func NewSingleObjectEncoder(itemSchema schema.ItemSchema) *SingleObjectEncoder {
+	header := make([]byte, 10)
+	copy(header, singleObjectMarker)
+	binary.LittleEndian.PutUint64(header[2:], schema.Fingerprint64(itemSchema))
+	return &SingleObjectEncoder{schema: itemSchema, header: header}
+}
+
+func (e *SingleObjectEncoder) Encode(writer io.Writer, value interface{}) error {
+	if _, err := writer.Write(e.header); err != nil {
+		return err
+	}
+	return e.schema.Write(writer, value)
+}
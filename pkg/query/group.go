@@ -0,0 +1,342 @@
+package query
+
+import (
+	"avroparser/pkg/expr"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// names of columns of group rows can't be written in queries
+const (
+	groupPrefix     = "\x00group"
+	aggregatePrefix = "\x00aggregate"
+)
+
+// aggregate is an aggregate function call, arg is nil for count(*)
+type aggregate struct {
+	call   *expr.Call
+	arg    *expr.Compiled
+	column expr.Column
+}
+
+// groupScope compiles group by expressions and aggregate arguments with the scope of
+// records, then rewrites select and order by expressions to use columns of group rows
+func (p *Plan) groupScope(scope expr.Scope, groupBy []expr.Expr, items []Item, orderBy []Order) (expr.Scope, error) {
+	var groupScope expr.Scope
+	for idx, e := range groupBy {
+		if hasAggregate(e) {
+			return nil, fmt.Errorf("invalid GROUP BY: aggregate functions can't be used in %s", e)
+		}
+		compiled, err := expr.Compile(e, scope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GROUP BY: %w", err)
+		}
+		if !orderable(compiled.Type) {
+			return nil, fmt.Errorf("invalid GROUP BY: %s of type %s can't be grouped by", e, compiled.Type)
+		}
+		p.groups = append(p.groups, compiled)
+		groupScope = append(groupScope, expr.Column{Name: groupPrefix + strconv.Itoa(idx), Type: compiled.Type, Schema: compiled.Schema})
+	}
+
+	rewrite := func(e expr.Expr) (expr.Expr, error) {
+		var err error
+		e = transform(e, func(n expr.Expr) expr.Expr {
+			for idx, group := range groupBy {
+				if n.String() == group.String() {
+					return columnPath(groupPrefix + strconv.Itoa(idx))
+				}
+			}
+			call, ok := n.(*expr.Call)
+			if !ok || !expr.IsAggregate(call.Name) || err != nil {
+				return nil
+			}
+			var a *aggregate
+			if a, err = p.aggregate(call, scope); err != nil {
+				return nil
+			}
+			return columnPath(a.column.Name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if path := fieldPath(e); path != nil {
+			return nil, fmt.Errorf("%s should be in GROUP BY or used in aggregate function", path)
+		}
+		return e, nil
+	}
+	var err error
+	for idx := range items {
+		if items[idx].Alias == "" {
+			items[idx].Alias = items[idx].Expr.String()
+		}
+		if items[idx].Expr, err = rewrite(items[idx].Expr); err != nil {
+			return nil, err
+		}
+	}
+	for idx := range orderBy {
+		if orderBy[idx].Expr, err = rewrite(orderBy[idx].Expr); err != nil {
+			return nil, fmt.Errorf("invalid ORDER BY: %w", err)
+		}
+	}
+	for _, a := range p.aggregates {
+		groupScope = append(groupScope, a.column)
+	}
+	return groupScope, nil
+}
+
+// aggregate returns aggregate of the call, calls with the same text share the aggregate
+func (p *Plan) aggregate(call *expr.Call, scope expr.Scope) (*aggregate, error) {
+	for _, a := range p.aggregates {
+		if a.call.String() == call.String() {
+			return a, nil
+		}
+	}
+	a := &aggregate{call: call, column: expr.Column{Name: aggregatePrefix + strconv.Itoa(len(p.aggregates))}}
+	switch {
+	case call.Star && call.Name != "count":
+		return nil, fmt.Errorf("%s: only count can be called with *", call)
+	case call.Distinct && call.Name != "count":
+		return nil, fmt.Errorf("%s: only count can count distinct values", call)
+	case !call.Star && len(call.Args) != 1:
+		return nil, fmt.Errorf("%s: aggregate function expects one argument", call)
+	}
+	if !call.Star {
+		var err error
+		if a.arg, err = expr.Compile(call.Args[0], scope); err != nil {
+			return nil, err
+		}
+	}
+	switch call.Name {
+	case "count":
+		a.column.Type = expr.Type{Kind: expr.KindInt}
+		if call.Distinct && !orderable(a.arg.Type) {
+			return nil, fmt.Errorf("%s: distinct values of type %s can't be counted", call, a.arg.Type)
+		}
+	case "sum", "avg":
+		if !numeric(a.arg.Type) {
+			return nil, fmt.Errorf("%s expects a number, got %s of type %s", call.Name, call.Args[0], a.arg.Type)
+		}
+		a.column.Type = expr.Type{Kind: expr.KindFloat}
+		if call.Name == "sum" && a.arg.Type.Kind == expr.KindInt {
+			a.column.Type.Kind = expr.KindInt
+		}
+	case "min", "max":
+		if !orderable(a.arg.Type) {
+			return nil, fmt.Errorf("%s: values of type %s can't be compared", call, a.arg.Type)
+		}
+		a.column.Type, a.column.Schema = a.arg.Type, a.arg.Schema
+	}
+	p.aggregates = append(p.aggregates, a)
+	return a, nil
+}
+
+func numeric(t expr.Type) bool {
+	switch t.Kind {
+	case expr.KindInt, expr.KindFloat, expr.KindAny, expr.KindNull:
+		return true
+	}
+	return false
+}
+
+///////////////////////
+
+// accumulator collects values of an aggregate for a group
+type accumulator struct {
+	count    int64
+	intSum   int64
+	floatSum float64
+	// best is the minimum or maximum value and raw is its avro value
+	best, raw interface{}
+	distinct  map[string]bool
+}
+
+func (a *aggregate) add(acc *accumulator, row map[string]interface{}) {
+	if a.arg == nil {
+		acc.count++
+		return
+	}
+	value := a.arg.Eval(row)
+	if value == nil {
+		return
+	}
+	switch a.call.Name {
+	case "count":
+		if a.call.Distinct {
+			if acc.distinct == nil {
+				acc.distinct = map[string]bool{}
+			}
+			key := keyOf(value)
+			if acc.distinct[key] {
+				return
+			}
+			acc.distinct[key] = true
+		}
+		acc.count++
+	case "sum", "avg":
+		switch v := value.(type) {
+		case int64:
+			acc.intSum += v
+			acc.floatSum += float64(v)
+		case float64:
+			acc.floatSum += v
+		default:
+			return
+		}
+		acc.count++
+	case "min", "max":
+		if acc.best != nil {
+			result, ok := expr.Compare(value, acc.best)
+			if !ok || (a.call.Name == "min" && result >= 0) || (a.call.Name == "max" && result <= 0) {
+				return
+			}
+		}
+		acc.best = value
+		if a.arg.Schema != nil {
+			acc.raw = a.arg.Raw(row)
+		}
+	}
+}
+
+// result returns value of the aggregate, sum, avg, min and max of no values are null
+func (a *aggregate) result(acc *accumulator) interface{} {
+	switch a.call.Name {
+	case "count":
+		return acc.count
+	case "sum":
+		if acc.count == 0 {
+			return nil
+		} else if a.column.Type.Kind == expr.KindInt {
+			return acc.intSum
+		}
+		return acc.floatSum
+	case "avg":
+		if acc.count == 0 {
+			return nil
+		}
+		return acc.floatSum / float64(acc.count)
+	}
+	if a.arg.Schema != nil {
+		return acc.raw
+	}
+	return acc.best
+}
+
+///////////////////////
+
+type group struct {
+	values       []interface{}
+	accumulators []accumulator
+}
+
+// groupSet keeps groups in order of their first rows
+type groupSet struct {
+	plan   *Plan
+	groups map[string]*group
+	order  []*group
+}
+
+func (p *Plan) newGroupSet() *groupSet {
+	return &groupSet{plan: p, groups: map[string]*group{}}
+}
+
+func (s *groupSet) add(row map[string]interface{}) {
+	values := make([]interface{}, len(s.plan.groups))
+	for idx, compiled := range s.plan.groups {
+		values[idx] = compiled.Eval(row)
+	}
+	key := keyOf(values...)
+	g, found := s.groups[key]
+	if !found {
+		// group rows keep avro values of paths
+		for idx, compiled := range s.plan.groups {
+			if compiled.Schema != nil {
+				values[idx] = compiled.Raw(row)
+			}
+		}
+		g = &group{values: values, accumulators: make([]accumulator, len(s.plan.aggregates))}
+		s.groups[key] = g
+		s.order = append(s.order, g)
+	}
+	for idx, a := range s.plan.aggregates {
+		a.add(&g.accumulators[idx], row)
+	}
+}
+
+// rows returns rows of groups, query without group by has single group even if there
+// are no records
+func (s *groupSet) rows() []map[string]interface{} {
+	if len(s.order) == 0 && len(s.plan.groups) == 0 {
+		s.order = append(s.order, &group{accumulators: make([]accumulator, len(s.plan.aggregates))})
+	}
+	rows := make([]map[string]interface{}, len(s.order))
+	for idx, g := range s.order {
+		row := map[string]interface{}{}
+		for groupIdx, value := range g.values {
+			row[groupPrefix+strconv.Itoa(groupIdx)] = value
+		}
+		for aggregateIdx, a := range s.plan.aggregates {
+			row[a.column.Name] = a.result(&g.accumulators[aggregateIdx])
+		}
+		rows[idx] = row
+	}
+	return rows
+}
+
+///////////////////////
+
+func columnPath(name string) *expr.Path {
+	return &expr.Path{Segments: []expr.Segment{{Name: name}}}
+}
+
+// transform replaces nodes of expression tree with results of f from the root, children
+// of replaced nodes are not visited and nodes f returns nil for are kept
+func transform(e expr.Expr, f func(expr.Expr) expr.Expr) expr.Expr {
+	if replaced := f(e); replaced != nil {
+		return replaced
+	}
+	switch n := e.(type) {
+	case *expr.Unary:
+		return &expr.Unary{Op: n.Op, X: transform(n.X, f)}
+	case *expr.Binary:
+		return &expr.Binary{Op: n.Op, Left: transform(n.Left, f), Right: transform(n.Right, f)}
+	case *expr.In:
+		list := make([]expr.Expr, len(n.List))
+		for idx, item := range n.List {
+			list[idx] = transform(item, f)
+		}
+		return &expr.In{X: transform(n.X, f), List: list, Not: n.Not}
+	case *expr.IsNull:
+		return &expr.IsNull{X: transform(n.X, f), Not: n.Not}
+	case *expr.Call:
+		args := make([]expr.Expr, len(n.Args))
+		for idx, arg := range n.Args {
+			args[idx] = transform(arg, f)
+		}
+		return &expr.Call{Name: n.Name, Args: args, Star: n.Star, Distinct: n.Distinct}
+	}
+	return e
+}
+
+func hasAggregate(e expr.Expr) bool {
+	found := false
+	transform(e, func(n expr.Expr) expr.Expr {
+		if call, ok := n.(*expr.Call); ok && expr.IsAggregate(call.Name) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// fieldPath returns path to a record field left in expression of group rows
+func fieldPath(e expr.Expr) *expr.Path {
+	var found *expr.Path
+	transform(e, func(n expr.Expr) expr.Expr {
+		if path, ok := n.(*expr.Path); ok && found == nil && !strings.HasPrefix(path.Segments[0].Name, "\x00") {
+			found = path
+		}
+		return nil
+	})
+	return found
+}
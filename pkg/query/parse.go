@@ -0,0 +1,152 @@
+package query
+
+import (
+	"avroparser/pkg/expr"
+	"fmt"
+	"strconv"
+)
+
+var reserved = []string{"select", "from", "where", "group", "by", "order", "limit", "as", "asc", "desc"}
+
+// Item is an expression of select list, Star is set for * selecting all fields
+type Item struct {
+	Expr  expr.Expr
+	Alias string
+	Star  bool
+}
+
+type Order struct {
+	Expr expr.Expr
+	Desc bool
+}
+
+// Query is a parsed select statement, Limit is negative if it is not set
+type Query struct {
+	Items   []Item
+	From    string
+	Where   expr.Expr
+	GroupBy []expr.Expr
+	OrderBy []Order
+	Limit   int
+}
+
+// Parse parses SELECT items FROM file [WHERE condition] [GROUP BY expressions]
+// [ORDER BY expressions [ASC|DESC]] [LIMIT count], file name is a path or a quoted string
+func Parse(text string) (*Query, error) {
+	tokens, err := expr.Tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := expr.NewParser(tokens, reserved...)
+	q := &Query{Limit: -1}
+	if err = p.ExpectKeyword("select"); err != nil {
+		return nil, err
+	}
+	if q.Items, err = parseItems(p); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("from"); err != nil {
+		return nil, err
+	}
+	if q.From, err = parseFrom(p, text); err != nil {
+		return nil, err
+	}
+	if p.AcceptKeyword("where") {
+		if q.Where, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	if p.AcceptKeyword("group") {
+		if err = p.ExpectKeyword("by"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			q.GroupBy = append(q.GroupBy, e)
+			if !p.AcceptOperator(",") {
+				break
+			}
+		}
+	}
+	if p.AcceptKeyword("order") {
+		if err = p.ExpectKeyword("by"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			order := Order{Expr: e}
+			if p.AcceptKeyword("desc") {
+				order.Desc = true
+			} else {
+				p.AcceptKeyword("asc")
+			}
+			q.OrderBy = append(q.OrderBy, order)
+			if !p.AcceptOperator(",") {
+				break
+			}
+		}
+	}
+	if p.AcceptKeyword("limit") {
+		token := p.Next()
+		if token.Kind != expr.TokenNumber {
+			return nil, fmt.Errorf("expected number after LIMIT, got %s at %d", token, token.Pos)
+		}
+		if q.Limit, err = strconv.Atoi(token.Text); err != nil {
+			return nil, fmt.Errorf("invalid limit %s at %d", token.Text, token.Pos)
+		}
+	}
+	if p.Peek().Kind != expr.TokenEOF {
+		return nil, p.Unexpected()
+	}
+	return q, nil
+}
+
+func parseItems(p *expr.Parser) ([]Item, error) {
+	var items []Item
+	for {
+		if p.AcceptOperator("*") {
+			items = append(items, Item{Star: true})
+		} else {
+			e, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			item := Item{Expr: e}
+			if p.AcceptKeyword("as") {
+				if item.Alias, err = p.ParseName(); err != nil {
+					return nil, err
+				}
+			} else if token := p.Peek(); (token.Kind == expr.TokenIdent || token.Kind == expr.TokenQuotedIdent) && !p.IsReserved(token) {
+				item.Alias, _ = p.ParseName()
+			}
+			items = append(items, item)
+		}
+		if !p.AcceptOperator(",") {
+			return items, nil
+		}
+	}
+}
+
+// parseFrom reads file name that is either a string or source text up to the whitespace,
+// so that paths are not split by operators and keywords they contain
+func parseFrom(p *expr.Parser, text string) (string, error) {
+	first := p.Peek()
+	if first.Kind == expr.TokenString {
+		p.Next()
+		return first.Text, nil
+	}
+	if first.Kind == expr.TokenEOF || p.IsReserved(first) {
+		return "", fmt.Errorf("expected file name after FROM, got %s at %d", first, first.Pos)
+	}
+	last := p.Next()
+	for token := p.Peek(); token.Kind != expr.TokenEOF && token.Pos == last.End; token = p.Peek() {
+		last = p.Next()
+	}
+	return text[first.Pos:last.End], nil
+}
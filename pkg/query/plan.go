@@ -0,0 +1,296 @@
+package query
+
+import (
+	"avroparser/pkg/expr"
+	"avroparser/pkg/output"
+	"avroparser/pkg/schema"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plan is a query type-checked against schema of records
+type Plan struct {
+	columns []column
+	where   *expr.Compiled
+	orderBy []orderKey
+	limit   int
+
+	// grouped is set for queries with group by or aggregates, columns and order keys of
+	// grouped queries are evaluated with rows of group and aggregate values
+	grouped    bool
+	groups     []*expr.Compiled
+	aggregates []*aggregate
+}
+
+// column is an output column, compiled expression is evaluated with input rows or with
+// rows of groups
+type column struct {
+	name     string
+	compiled *expr.Compiled
+}
+
+type orderKey struct {
+	compiled *expr.Compiled
+	desc     bool
+}
+
+// NewPlan compiles the query for records of the schema, record fields are columns of
+// the query and their nested fields are selected with paths
+func NewPlan(q *Query, s schema.ItemSchema) (*Plan, error) {
+	scope, err := expr.RecordScope(s)
+	if err != nil {
+		return nil, fmt.Errorf("query can read only files of records: %w", err)
+	}
+	plan := &Plan{limit: q.Limit}
+	if q.Where != nil {
+		if plan.where, err = expr.CompileFilter(q.Where, scope); err != nil {
+			return nil, fmt.Errorf("invalid WHERE: %w", err)
+		}
+	}
+
+	var items []Item
+	for _, item := range q.Items {
+		if !item.Star {
+			items = append(items, item)
+			continue
+		}
+		for _, col := range scope {
+			items = append(items, Item{Expr: &expr.Path{Segments: []expr.Segment{{Name: col.Name}}}})
+		}
+	}
+	groupBy := make([]expr.Expr, len(q.GroupBy))
+	for idx, e := range q.GroupBy {
+		if groupBy[idx], err = resolveItem(e, items); err != nil {
+			return nil, fmt.Errorf("invalid GROUP BY: %w", err)
+		}
+	}
+	orderBy := make([]Order, len(q.OrderBy))
+	for idx, order := range q.OrderBy {
+		orderBy[idx] = order
+		if orderBy[idx].Expr, err = resolveItem(order.Expr, items); err != nil {
+			return nil, fmt.Errorf("invalid ORDER BY: %w", err)
+		}
+	}
+
+	plan.grouped = len(groupBy) > 0
+	for _, item := range items {
+		plan.grouped = plan.grouped || hasAggregate(item.Expr)
+	}
+	for _, order := range orderBy {
+		plan.grouped = plan.grouped || hasAggregate(order.Expr)
+	}
+	if plan.grouped {
+		for _, item := range q.Items {
+			if item.Star {
+				return nil, fmt.Errorf("* can't be selected with GROUP BY or aggregate functions")
+			}
+		}
+		if scope, err = plan.groupScope(scope, groupBy, items, orderBy); err != nil {
+			return nil, err
+		}
+	}
+
+	names := map[string]bool{}
+	for _, item := range items {
+		compiled, err := expr.Compile(item.Expr, scope)
+		if err != nil {
+			return nil, err
+		}
+		name := item.Alias
+		if name == "" {
+			name = item.Expr.String()
+		}
+		for unique, idx := name, 2; names[name]; idx++ {
+			name = unique + "_" + strconv.Itoa(idx)
+		}
+		names[name] = true
+		plan.columns = append(plan.columns, column{name: name, compiled: compiled})
+	}
+	for _, order := range orderBy {
+		compiled, err := expr.Compile(order.Expr, scope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ORDER BY: %w", err)
+		}
+		if !orderable(compiled.Type) {
+			return nil, fmt.Errorf("invalid ORDER BY: %s of type %s can't be ordered", order.Expr, compiled.Type)
+		}
+		plan.orderBy = append(plan.orderBy, orderKey{compiled: compiled, desc: order.Desc})
+	}
+	return plan, nil
+}
+
+// Columns returns names of output columns
+func (p *Plan) Columns() []string {
+	names := make([]string, len(p.columns))
+	for idx, col := range p.columns {
+		names[idx] = col.name
+	}
+	return names
+}
+
+// Header returns output columns with schemas of paths to avro values, computed values
+// have no schema
+func (p *Plan) Header() output.Record {
+	header := make(output.Record, len(p.columns))
+	for idx, col := range p.columns {
+		header[idx] = output.Field{Name: col.name, Schema: col.compiled.Schema}
+	}
+	return header
+}
+
+// resolveItem replaces aliases and 1-based positions of select items with their expressions
+func resolveItem(e expr.Expr, items []Item) (expr.Expr, error) {
+	switch n := e.(type) {
+	case *expr.Path:
+		if len(n.Segments) == 1 {
+			for _, item := range items {
+				if item.Alias != "" && item.Alias == n.Segments[0].Name {
+					return item.Expr, nil
+				}
+			}
+		}
+	case *expr.Literal:
+		if position, ok := n.Value.(int64); ok {
+			if position < 1 || int(position) > len(items) {
+				return nil, fmt.Errorf("position %d is out of range of %d selected columns", position, len(items))
+			}
+			return items[position-1].Expr, nil
+		}
+	}
+	return e, nil
+}
+
+// Run reads records with next until it returns io.EOF and writes results with emit
+func (p *Plan) Run(next func() (interface{}, error), emit func(output.Record) error) error {
+	if p.limit == 0 {
+		return nil
+	}
+	var rows []resultRow
+	var groups *groupSet
+	if p.grouped {
+		groups = p.newGroupSet()
+	}
+	for count := 0; ; {
+		value, err := next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		row, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value %v is not a record", value)
+		}
+		if p.where != nil && !p.where.Matches(row) {
+			continue
+		}
+		if groups != nil {
+			groups.add(row)
+		} else if len(p.orderBy) > 0 {
+			rows = append(rows, p.result(row))
+		} else {
+			if err = emit(p.record(row)); err != nil {
+				return err
+			}
+			if count++; count == p.limit {
+				return nil
+			}
+		}
+	}
+	if groups != nil {
+		for _, row := range groups.rows() {
+			rows = append(rows, p.result(row))
+		}
+	}
+	p.sort(rows)
+	for idx, row := range rows {
+		if idx == p.limit {
+			break
+		}
+		if err := emit(row.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type resultRow struct {
+	record output.Record
+	keys   []interface{}
+}
+
+func (p *Plan) result(row map[string]interface{}) resultRow {
+	result := resultRow{record: p.record(row), keys: make([]interface{}, len(p.orderBy))}
+	for idx, key := range p.orderBy {
+		result.keys[idx] = key.compiled.Eval(row)
+	}
+	return result
+}
+
+// record evaluates output columns, paths to avro values are written with their schemas
+// and computed values as they are with timestamps as RFC 3339 strings
+func (p *Plan) record(row map[string]interface{}) output.Record {
+	record := make(output.Record, len(p.columns))
+	for idx, col := range p.columns {
+		field := output.Field{Name: col.name}
+		if col.compiled.Schema != nil {
+			field.Value = col.compiled.Raw(row)
+			if _, isUnion := col.compiled.Schema.(schema.AvroUnion); field.Value != nil || isUnion {
+				field.Schema = col.compiled.Schema
+			}
+		} else {
+			field.Value = col.compiled.Eval(row)
+			if t, ok := field.Value.(time.Time); ok {
+				field.Value = t.Format(time.RFC3339Nano)
+			}
+		}
+		record[idx] = field
+	}
+	return record
+}
+
+// sort orders rows by keys, nulls go before other values
+func (p *Plan) sort(rows []resultRow) {
+	if len(p.orderBy) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for idx, key := range p.orderBy {
+			a, b := rows[i].keys[idx], rows[j].keys[idx]
+			result := 0
+			switch {
+			case a == nil && b == nil:
+			case a == nil:
+				result = -1
+			case b == nil:
+				result = 1
+			default:
+				result, _ = expr.Compare(a, b)
+			}
+			if key.desc {
+				result = -result
+			}
+			if result != 0 {
+				return result < 0
+			}
+		}
+		return false
+	})
+}
+
+func orderable(t expr.Type) bool {
+	return t.Kind != expr.KindArray && t.Kind != expr.KindMap && t.Kind != expr.KindRecord
+}
+
+// keyOf returns string identifying values for grouping and counting distinct values
+func keyOf(values ...interface{}) string {
+	var key strings.Builder
+	for _, value := range values {
+		fmt.Fprintf(&key, "%T:%v\x00", value, value)
+	}
+	return key.String()
+}
@@ -0,0 +1,175 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type Entry struct {
+	Id         uint32      `json:"id"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+	// Subject and Version are set for entries fetched by subject version
+	Subject string `json:"subject,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// Cache keeps raw schemas fetched from the registry
+type Cache interface {
+	ById(id uint32) (Entry, bool)
+	ByVersion(subject string, version int) (Entry, bool)
+	Put(entry Entry) error
+	Entries() ([]Entry, error)
+}
+
+func versionKey(subject string, version int) string {
+	return subject + "@" + strconv.Itoa(version)
+}
+
+///////////////////////
+
+type MemoryCache struct {
+	lock     sync.RWMutex
+	ids      map[uint32]Entry
+	versions map[string]Entry
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{ids: make(map[uint32]Entry), versions: make(map[string]Entry)}
+}
+
+func (c *MemoryCache) ById(id uint32) (Entry, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	entry, found := c.ids[id]
+	return entry, found
+}
+
+func (c *MemoryCache) ByVersion(subject string, version int) (Entry, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	entry, found := c.versions[versionKey(subject, version)]
+	return entry, found
+}
+
+func (c *MemoryCache) Put(entry Entry) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if entry.Subject != "" {
+		c.versions[versionKey(entry.Subject, entry.Version)] = entry
+	}
+	if _, found := c.ids[entry.Id]; !found || entry.Subject == "" {
+		c.ids[entry.Id] = entry
+	}
+	return nil
+}
+
+func (c *MemoryCache) Entries() ([]Entry, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	result := make([]Entry, 0, len(c.ids)+len(c.versions))
+	for _, entry := range c.ids {
+		if entry.Subject == "" {
+			result = append(result, entry)
+		}
+	}
+	for _, entry := range c.versions {
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+///////////////////////
+
+// DiskCache keeps entries as json files in ids and versions subdirectories of the directory
+type DiskCache struct {
+	directory string
+}
+
+func NewDiskCache(directory string) (*DiskCache, error) {
+	for _, sub := range []string{"ids", "versions"} {
+		if err := os.MkdirAll(filepath.Join(directory, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &DiskCache{directory: directory}, nil
+}
+
+func (c *DiskCache) idPath(id uint32) string {
+	return filepath.Join(c.directory, "ids", fmt.Sprintf("%d.json", id))
+}
+
+func (c *DiskCache) versionPath(subject string, version int) string {
+	return filepath.Join(c.directory, "versions", url.PathEscape(versionKey(subject, version))+".json")
+}
+
+func readEntry(fileName string) (Entry, bool) {
+	entry := Entry{}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return entry, false
+	}
+	// broken cache files are treated as missing and overwritten on next fetch
+	return entry, json.Unmarshal(data, &entry) == nil
+}
+
+func (c *DiskCache) ById(id uint32) (Entry, bool) {
+	return readEntry(c.idPath(id))
+}
+
+func (c *DiskCache) ByVersion(subject string, version int) (Entry, bool) {
+	return readEntry(c.versionPath(subject, version))
+}
+
+func (c *DiskCache) Put(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	fileName := c.idPath(entry.Id)
+	if entry.Subject != "" {
+		fileName = c.versionPath(entry.Subject, entry.Version)
+		if _, found := c.ById(entry.Id); !found {
+			if err = writeFileAtomic(c.idPath(entry.Id), data); err != nil {
+				return err
+			}
+		}
+	}
+	return writeFileAtomic(fileName, data)
+}
+
+func (c *DiskCache) Entries() ([]Entry, error) {
+	result := make([]Entry, 0)
+	for _, sub := range []string{"ids", "versions"} {
+		fileNames, err := filepath.Glob(filepath.Join(c.directory, sub, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, fileName := range fileNames {
+			if entry, ok := readEntry(fileName); ok && (sub == "versions" || entry.Subject == "") {
+				result = append(result, entry)
+			}
+		}
+	}
+	return result, nil
+}
+
+func writeFileAtomic(fileName string, data []byte) error {
+	tmp := fileName + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fileName)
+}
@@ -0,0 +1,314 @@
+package registry
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Client fetches schemas from confluent schema registry, raw schemas are looked up in caches
+// in the order they are passed before the registry is queried. Client without registry url
+// works only with cached schemas.
+type Client struct {
+	baseUrl string
+	client  *http.Client
+	caches  []Cache
+
+	lock         sync.Mutex
+	parsed       map[uint32]schema.ItemSchema
+	fingerprints map[uint64]schema.ItemSchema
+	// subjects are ids of schemas by subject and fingerprint
+	subjects map[string]uint32
+}
+
+func NewClient(baseUrl string, client *http.Client, caches ...Cache) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(caches) == 0 {
+		caches = []Cache{NewMemoryCache()}
+	}
+	return &Client{
+		baseUrl:      strings.TrimSuffix(baseUrl, "/"),
+		client:       client,
+		caches:       caches,
+		parsed:       make(map[uint32]schema.ItemSchema),
+		fingerprints: make(map[uint64]schema.ItemSchema),
+		subjects:     make(map[string]uint32),
+	}
+}
+
+type schemaResponse struct {
+	Subject    string      `json:"subject,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	Id         uint32      `json:"id,omitempty"`
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (c *Client) request(method, path string, body interface{}, result interface{}) error {
+	if c.baseUrl == "" {
+		return fmt.Errorf("registry url is not set")
+	}
+	var requestBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		requestBody = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.baseUrl+path, requestBody)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+	resp, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read registry response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		registryError := errorResponse{}
+		if json.Unmarshal(data, &registryError) == nil && registryError.Message != "" {
+			return fmt.Errorf("registry error %d: %s", registryError.ErrorCode, registryError.Message)
+		}
+		return fmt.Errorf("registry responded with status %s", resp.Status)
+	}
+	if err = json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to parse registry response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) putEntry(entry Entry) error {
+	for _, cache := range c.caches {
+		if err := cache.Put(entry); err != nil {
+			return fmt.Errorf("failed to cache schema with id %d: %w", entry.Id, err)
+		}
+	}
+	return nil
+}
+
+func checkSchemaType(response schemaResponse) error {
+	if response.SchemaType != "" && response.SchemaType != "AVRO" {
+		return fmt.Errorf("schema with id %d has unsupported type %s", response.Id, response.SchemaType)
+	}
+	return nil
+}
+
+func (c *Client) entryById(id uint32) (Entry, error) {
+	for _, cache := range c.caches {
+		if entry, found := cache.ById(id); found {
+			return entry, nil
+		}
+	}
+	response := schemaResponse{}
+	if err := c.request(http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &response); err != nil {
+		return Entry{}, fmt.Errorf("failed to fetch schema with id %d: %w", id, err)
+	}
+	response.Id = id
+	if err := checkSchemaType(response); err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Id: id, Schema: response.Schema, References: response.References}
+	return entry, c.putEntry(entry)
+}
+
+func (c *Client) entryByVersion(subject string, version int) (Entry, error) {
+	for _, cache := range c.caches {
+		if entry, found := cache.ByVersion(subject, version); found {
+			return entry, nil
+		}
+	}
+	response := schemaResponse{}
+	path := fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(subject), version)
+	if err := c.request(http.MethodGet, path, nil, &response); err != nil {
+		return Entry{}, fmt.Errorf("failed to fetch version %d of subject %s: %w", version, subject, err)
+	}
+	if err := checkSchemaType(response); err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Id: response.Id, Schema: response.Schema, References: response.References, Subject: subject, Version: version}
+	return entry, c.putEntry(entry)
+}
+
+// parse parses schema after all schemas it references, so named types from them are known
+func (c *Client) parse(entry Entry) (schema.ItemSchema, error) {
+	parser := schema.NewParser()
+	if err := c.parseReferences(parser, entry.References, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	roots, err := parser.ParseJSON([]byte(entry.Schema))
+	if err != nil {
+		return nil, err
+	}
+	return roots[0], nil
+}
+
+func (c *Client) parseReferences(parser *schema.Parser, references []Reference, parsed map[string]bool) error {
+	for _, reference := range references {
+		key := versionKey(reference.Subject, reference.Version)
+		if parsed[key] {
+			continue
+		}
+		parsed[key] = true
+		entry, err := c.entryByVersion(reference.Subject, reference.Version)
+		if err != nil {
+			return fmt.Errorf("failed to resolve reference %s: %w", reference.Name, err)
+		}
+		if err = c.parseReferences(parser, entry.References, parsed); err != nil {
+			return err
+		}
+		if _, err = parser.ParseJSON([]byte(entry.Schema)); err != nil {
+			return fmt.Errorf("failed to parse referenced schema %s: %w", reference.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) SchemaById(id uint32) (schema.ItemSchema, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if s, found := c.parsed[id]; found {
+		return s, nil
+	}
+	entry, err := c.entryById(id)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.parse(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema with id %d: %w", id, err)
+	}
+	c.parsed[id] = s
+	c.fingerprints[schema.Fingerprint64(s)] = s
+	return s, nil
+}
+
+// SchemaByFingerprint looks up schema by CRC-64-AVRO fingerprint among cached schemas
+func (c *Client) SchemaByFingerprint(fingerprint uint64) (schema.ItemSchema, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if s, found := c.fingerprints[fingerprint]; found {
+		return s, nil
+	}
+	for _, cache := range c.caches {
+		entries, err := cache.Entries()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if _, found := c.parsed[entry.Id]; found {
+				continue
+			}
+			if s, err := c.parse(entry); err == nil {
+				c.parsed[entry.Id] = s
+				c.fingerprints[schema.Fingerprint64(s)] = s
+			}
+		}
+	}
+	if s, found := c.fingerprints[fingerprint]; found {
+		return s, nil
+	}
+	return nil, fmt.Errorf("schema with fingerprint %016x is not found", fingerprint)
+}
+
+// SchemaId returns id of the schema registered under the subject, the schema is registered
+// if it is not found and autoRegister is set. Ids are looked up by subject and fingerprint
+// of the schema in the caches before the registry is queried.
+func (c *Client) SchemaId(subject, schemaText string, references []Reference, autoRegister bool) (uint32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s, err := c.parse(Entry{Schema: schemaText, References: references})
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse schema for subject %s: %w", subject, err)
+	}
+	fingerprint := schema.Fingerprint64(s)
+	key := subjectKey(subject, fingerprint)
+	if id, found := c.subjects[key]; found {
+		return id, nil
+	}
+	if id, found := c.cachedSchemaId(subject, fingerprint); found {
+		c.subjects[key] = id
+		return id, nil
+	}
+
+	request := schemaResponse{Schema: schemaText, References: references}
+	id, err := c.lookupSchemaId(subject, request)
+	if err != nil {
+		if !autoRegister {
+			return 0, fmt.Errorf("failed to look up schema under subject %s: %w", subject, err)
+		}
+		response := schemaResponse{}
+		if err = c.request(http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", request, &response); err != nil {
+			return 0, fmt.Errorf("failed to register schema under subject %s: %w", subject, err)
+		}
+		// registration returns only id, version of the subject is looked up for the caches
+		if id, err = c.lookupSchemaId(subject, request); err != nil {
+			return 0, fmt.Errorf("failed to look up registered schema under subject %s: %w", subject, err)
+		}
+	}
+	c.subjects[key] = id
+	return id, nil
+}
+
+// lookupSchemaId queries the registry for id of the schema under the subject
+func (c *Client) lookupSchemaId(subject string, request schemaResponse) (uint32, error) {
+	response := schemaResponse{}
+	if err := c.request(http.MethodPost, "/subjects/"+url.PathEscape(subject), request, &response); err != nil {
+		return 0, err
+	}
+	entry := Entry{Id: response.Id, Schema: response.Schema, References: response.References, Subject: subject, Version: response.Version}
+	return response.Id, c.putEntry(entry)
+}
+
+// cachedSchemaId finds cached version of the subject with schema of the fingerprint
+func (c *Client) cachedSchemaId(subject string, fingerprint uint64) (uint32, bool) {
+	for _, cache := range c.caches {
+		entries, err := cache.Entries()
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Subject != subject {
+				continue
+			}
+			s, found := c.parsed[entry.Id]
+			if !found {
+				if s, err = c.parse(entry); err != nil {
+					continue
+				}
+				c.parsed[entry.Id] = s
+				c.fingerprints[schema.Fingerprint64(s)] = s
+			}
+			if schema.Fingerprint64(s) == fingerprint {
+				return entry.Id, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func subjectKey(subject string, fingerprint uint64) string {
+	return fmt.Sprintf("%s@%016x", subject, fingerprint)
+}
@@ -0,0 +1,286 @@
+package registry
+
+import (
+	"avroparser/pkg/schema"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	moneySchema = `{"type": "record", "name": "Money", "namespace": "com.x", "fields": [{"name": "amount", "type": "long"}]}`
+	orderSchema = `{"type": "record", "name": "Order", "namespace": "com.x", "fields": [{"name": "id", "type": "long"}, {"name": "total", "type": "Money"}]}`
+)
+
+// fakeRegistry responds to registry requests with canned responses by method and path and
+// counts requests
+type fakeRegistry struct {
+	lock      sync.Mutex
+	responses map[string]fakeResponse
+	requests  map[string]int
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+func newFakeRegistry(t *testing.T, responses map[string]fakeResponse) (*fakeRegistry, string) {
+	registry := &fakeRegistry{responses: responses, requests: make(map[string]int)}
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	return registry, server.URL
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.EscapedPath()
+	f.lock.Lock()
+	f.requests[key]++
+	f.lock.Unlock()
+	if r.Header.Get("Accept") != "application/vnd.schemaregistry.v1+json" {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+	response, found := f.responses[key]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error_code": 404, "message": "HTTP 404 Not Found"}`))
+		return
+	}
+	w.WriteHeader(response.status)
+	_, _ = w.Write([]byte(response.body))
+}
+
+func (f *fakeRegistry) count(key string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.requests[key]
+}
+
+func ok(value interface{}) fakeResponse {
+	data, _ := json.Marshal(value)
+	return fakeResponse{status: http.StatusOK, body: string(data)}
+}
+
+func referenceResponses() map[string]fakeResponse {
+	return map[string]fakeResponse{
+		"GET /schemas/ids/2": ok(map[string]interface{}{
+			"schema":     orderSchema,
+			"references": []Reference{{Name: "com.x.Money", Subject: "money", Version: 1}},
+		}),
+		"GET /subjects/money/versions/1": ok(map[string]interface{}{
+			"subject": "money", "version": 1, "id": 1, "schema": moneySchema,
+		}),
+	}
+}
+
+func TestSchemaById(t *testing.T) {
+	registry, url := newFakeRegistry(t, map[string]fakeResponse{
+		"GET /schemas/ids/1": ok(map[string]interface{}{"schema": `"string"`}),
+	})
+	client := NewClient(url+"/", nil)
+	for i := 0; i < 2; i++ {
+		s, err := client.SchemaById(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, isString := s.(schema.AvroString); !isString {
+			t.Fatalf("expected string schema, got %T", s)
+		}
+	}
+	if count := registry.count("GET /schemas/ids/1"); count != 1 {
+		t.Fatalf("expected single request, got %d", count)
+	}
+	if s, err := client.SchemaByFingerprint(schema.Fingerprint64(schema.AvroString{})); err != nil || s == nil {
+		t.Fatalf("expected schema by fingerprint, got %v, %v", s, err)
+	}
+}
+
+func TestSchemaByIdErrors(t *testing.T) {
+	_, url := newFakeRegistry(t, map[string]fakeResponse{
+		"GET /schemas/ids/1": {status: http.StatusNotFound, body: `{"error_code": 40403, "message": "Schema 1 not found"}`},
+		"GET /schemas/ids/2": {status: http.StatusInternalServerError, body: `oops`},
+		"GET /schemas/ids/3": ok(map[string]interface{}{"schema": `{}`, "schemaType": "PROTOBUF"}),
+		"GET /schemas/ids/4": ok(map[string]interface{}{"schema": `{"type": "nope"}`}),
+		"GET /schemas/ids/5": {status: http.StatusOK, body: `not json`},
+	})
+	client := NewClient(url, nil)
+	tests := []struct {
+		id      uint32
+		message string
+	}{
+		{1, "failed to fetch schema with id 1: registry error 40403: Schema 1 not found"},
+		{2, "failed to fetch schema with id 2: registry responded with status 500 Internal Server Error"},
+		{3, "schema with id 3 has unsupported type PROTOBUF"},
+		{4, "failed to parse schema with id 4"},
+		{5, "failed to parse registry response"},
+	}
+	for _, test := range tests {
+		if _, err := client.SchemaById(test.id); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("schema %d: expected error %q, got %v", test.id, test.message, err)
+		}
+	}
+	if _, err := NewClient("", nil).SchemaById(1); err == nil || !strings.Contains(err.Error(), "registry url is not set") {
+		t.Errorf("expected error of client without url, got %v", err)
+	}
+}
+
+func TestSchemaReferences(t *testing.T) {
+	registry, url := newFakeRegistry(t, referenceResponses())
+	client := NewClient(url, nil)
+	s, err := client.SchemaById(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, found := s.(schema.AvroRecord).Field("total")
+	if !found || schema.TypeName(total.Type()) != "com.x.Money" {
+		t.Fatalf("expected total of type com.x.Money, got %v", total.Type())
+	}
+	if count := registry.count("GET /subjects/money/versions/1"); count != 1 {
+		t.Fatalf("expected single request of referenced schema, got %d", count)
+	}
+
+	delete(registry.responses, "GET /subjects/money/versions/1")
+	registry.responses["GET /schemas/ids/3"] = registry.responses["GET /schemas/ids/2"]
+	client = NewClient(url, nil)
+	if _, err = client.SchemaById(3); err == nil || !strings.Contains(err.Error(), "failed to resolve reference com.x.Money") {
+		t.Fatalf("expected error of missing reference, got %v", err)
+	}
+}
+
+func TestCaches(t *testing.T) {
+	registry, url := newFakeRegistry(t, referenceResponses())
+	directory := t.TempDir()
+	diskCache, err := NewDiskCache(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewClient(url, nil, NewMemoryCache(), diskCache).SchemaById(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// schemas are read from disk cache without registry
+	diskCache, err = NewDiskCache(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := NewClient("", nil, NewMemoryCache(), diskCache)
+	if _, err = offline.SchemaById(2); err != nil {
+		t.Fatalf("expected schema from disk cache, got %v", err)
+	}
+	snapshot := bytes.Buffer{}
+	if err = offline.Export(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := NewClient("", nil)
+	if err = imported.Import(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = imported.SchemaById(2); err != nil {
+		t.Fatalf("expected schema from snapshot, got %v", err)
+	}
+	if s, err := imported.SchemaByFingerprint(fingerprintOf(t, moneySchema)); err != nil || schema.TypeName(s) != "com.x.Money" {
+		t.Fatalf("expected referenced schema by fingerprint, got %v, %v", s, err)
+	}
+	if count := registry.count("GET /schemas/ids/2"); count != 1 {
+		t.Fatalf("expected single request to registry, got %d", count)
+	}
+}
+
+func TestSchemaId(t *testing.T) {
+	lookup := ok(map[string]interface{}{"subject": "money", "version": 1, "id": 1, "schema": moneySchema})
+	registry, url := newFakeRegistry(t, map[string]fakeResponse{
+		"POST /subjects/money":          lookup,
+		"POST /subjects/order/versions": ok(map[string]interface{}{"id": 7}),
+	})
+	directory := t.TempDir()
+	diskCache, err := NewDiskCache(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(url, nil, NewMemoryCache(), diskCache)
+	for i := 0; i < 2; i++ {
+		if id, err := client.SchemaId("money", moneySchema, nil, false); err != nil || id != 1 {
+			t.Fatalf("expected id 1, got %d, %v", id, err)
+		}
+	}
+	if count := registry.count("POST /subjects/money"); count != 1 {
+		t.Fatalf("expected single lookup, got %d", count)
+	}
+
+	// cached subject versions are matched by fingerprint, so formatting doesn't matter
+	diskCache, err = NewDiskCache(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := NewClient("", nil, diskCache)
+	reformatted := strings.ReplaceAll(moneySchema, " ", "")
+	if id, err := offline.SchemaId("money", reformatted, nil, false); err != nil || id != 1 {
+		t.Fatalf("expected id 1 from cache, got %d, %v", id, err)
+	}
+	if _, err = offline.SchemaId("other", moneySchema, nil, false); err == nil {
+		t.Fatal("expected error of subject that is not cached")
+	}
+
+	if _, err = client.SchemaId("order", orderSchema, nil, true); err == nil {
+		t.Fatal("expected error of unknown reference")
+	}
+	if _, err = client.SchemaId("order", `"string"`, nil, false); err == nil || !strings.Contains(err.Error(), "failed to look up schema under subject order") {
+		t.Fatalf("expected lookup error, got %v", err)
+	}
+	// registration succeeds, but registry doesn't find the schema after it
+	if _, err = client.SchemaId("order", `"string"`, nil, true); err == nil || !strings.Contains(err.Error(), "failed to look up registered schema") {
+		t.Fatalf("expected error of lookup after registration, got %v", err)
+	}
+	if count := registry.count("POST /subjects/order/versions"); count != 1 {
+		t.Fatalf("expected registration, got %d requests", count)
+	}
+}
+
+func TestSubjectNameStrategies(t *testing.T) {
+	record, err := schema.ParseSchemaJSON([]byte(moneySchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		strategy string
+		topic    string
+		isKey    bool
+		s        schema.ItemSchema
+		subject  string
+	}{
+		{"topic", "payments", false, record, "payments-value"},
+		{"TopicNameStrategy", "payments", true, record, "payments-key"},
+		{"record", "", false, record, "com.x.Money"},
+		{"topic-record", "payments", true, record, "payments-com.x.Money"},
+		{"topic", "", false, record, ""},
+		{"record", "payments", false, schema.AvroString{}, ""},
+	}
+	for _, test := range tests {
+		strategy, err := StrategyByName(test.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subject, err := strategy(test.topic, test.isKey, test.s)
+		if subject != test.subject || (err != nil) != (test.subject == "") {
+			t.Errorf("%s of topic %q: expected subject %q, got %q, %v", test.strategy, test.topic, test.subject, subject, err)
+		}
+	}
+	if _, err = StrategyByName("nope"); err == nil {
+		t.Error("expected error of unknown strategy")
+	}
+}
+
+func fingerprintOf(t *testing.T, data string) uint64 {
+	t.Helper()
+	s, err := schema.ParseSchemaJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema.Fingerprint64(s)
+}
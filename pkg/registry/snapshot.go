@@ -0,0 +1,55 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type snapshot struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Export writes all cached schemas, so that they can be imported to run without registry
+func (c *Client) Export(w io.Writer) error {
+	seen := make(map[string]bool)
+	result := snapshot{Entries: make([]Entry, 0)}
+	for _, cache := range c.caches {
+		entries, err := cache.Entries()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			key := fmt.Sprintf("%d/%s", entry.Id, versionKey(entry.Subject, entry.Version))
+			if !seen[key] {
+				seen[key] = true
+				result.Entries = append(result.Entries, entry)
+			}
+		}
+	}
+	sort.Slice(result.Entries, func(i, j int) bool {
+		if result.Entries[i].Id != result.Entries[j].Id {
+			return result.Entries[i].Id < result.Entries[j].Id
+		}
+		return versionKey(result.Entries[i].Subject, result.Entries[i].Version) <
+			versionKey(result.Entries[j].Subject, result.Entries[j].Version)
+	})
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// Import puts schemas from exported snapshot into the caches
+func (c *Client) Import(r io.Reader) error {
+	data := snapshot{}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return fmt.Errorf("failed to read registry snapshot: %w", err)
+	}
+	for _, entry := range data.Entries {
+		if err := c.putEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
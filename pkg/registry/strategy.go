@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"avroparser/pkg/schema"
+	"fmt"
+)
+
+// SubjectNameStrategy derives subject to register schema of message key or value under
+type SubjectNameStrategy func(topic string, isKey bool, itemSchema schema.ItemSchema) (string, error)
+
+func TopicNameStrategy(topic string, isKey bool, _ schema.ItemSchema) (string, error) {
+	if topic == "" {
+		return "", fmt.Errorf("topic name strategy requires topic")
+	}
+	if isKey {
+		return topic + "-key", nil
+	}
+	return topic + "-value", nil
+}
+
+func RecordNameStrategy(_ string, _ bool, itemSchema schema.ItemSchema) (string, error) {
+	return recordName(itemSchema)
+}
+
+func TopicRecordNameStrategy(topic string, _ bool, itemSchema schema.ItemSchema) (string, error) {
+	if topic == "" {
+		return "", fmt.Errorf("topic record name strategy requires topic")
+	}
+	name, err := recordName(itemSchema)
+	if err != nil {
+		return "", err
+	}
+	return topic + "-" + name, nil
+}
+
+func recordName(itemSchema schema.ItemSchema) (string, error) {
+	if record, ok := itemSchema.(schema.AvroRecord); ok {
+		return record.FullName(), nil
+	}
+	return "", fmt.Errorf("record name strategy requires record schema, got %T", itemSchema)
+}
+
+func StrategyByName(name string) (SubjectNameStrategy, error) {
+	switch name {
+	case "TopicNameStrategy", "topic":
+		return TopicNameStrategy, nil
+	case "RecordNameStrategy", "record":
+		return RecordNameStrategy, nil
+	case "TopicRecordNameStrategy", "topic-record":
+		return TopicRecordNameStrategy, nil
+	default:
+		return nil, fmt.Errorf("unknown subject name strategy %s", name)
+	}
+}
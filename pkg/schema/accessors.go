@@ -0,0 +1,185 @@
+package schema
+
+// Accessors return nested schemas with references resolved, so that code walking
+// schemas never sees references to named types.
+
+func (v AvroRecord) Name() string {
+	return v.name
+}
+
+func (v AvroRecord) Namespace() string {
+	return v.namespace
+}
+
+func (v AvroRecord) Doc() string {
+	return v.doc
+}
+
+func (v AvroRecord) Fields() []AvroRecordField {
+	return v.fields
+}
+
+// Field returns field of the record by its name
+func (v AvroRecord) Field(name string) (AvroRecordField, bool) {
+	for _, f := range v.fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return AvroRecordField{}, false
+}
+
+///////////////////////
+
+func (f AvroRecordField) Name() string {
+	return f.name
+}
+
+func (f AvroRecordField) Doc() string {
+	return f.doc
+}
+
+func (f AvroRecordField) Type() ItemSchema {
+	return resolve(f.fieldType)
+}
+
+// Default returns default value of the field as it is set in json schema definition
+func (f AvroRecordField) Default() (interface{}, bool) {
+	return f.defaultValue, f.hasDefault
+}
+
+func (f AvroRecordField) Order() AvroRecordFieldOrder {
+	return f.order
+}
+
+///////////////////////
+
+func (v AvroEnum) Name() string {
+	return v.name
+}
+
+func (v AvroEnum) Symbols() []string {
+	return v.symbols
+}
+
+///////////////////////
+
+func (v AvroArray) Items() ItemSchema {
+	return resolve(v.itemSchema)
+}
+
+func (v AvroMap) Values() ItemSchema {
+	return resolve(v.values)
+}
+
+///////////////////////
+
+func (v AvroFixed) Name() string {
+	return v.name
+}
+
+func (v AvroFixed) Size() int {
+	return v.size
+}
+
+///////////////////////
+
+func (v AvroUnion) Elements() []ItemSchema {
+	result := make([]ItemSchema, len(v.elements))
+	for idx, element := range v.elements {
+		result[idx] = resolve(element)
+	}
+	return result
+}
+
+// BranchIndex returns index of union element the value is written with, -1 if none matches
+func (v AvroUnion) BranchIndex(value interface{}) int {
+	idx, _ := v.Branch(value)
+	return idx
+}
+
+// Untagged returns value of union without UnionValue tag, other values are returned as is
+func Untagged(value interface{}) interface{} {
+	if tagged, ok := value.(UnionValue); ok {
+		return tagged.Value
+	}
+	return value
+}
+
+///////////////////////
+
+// TypeName returns name of the type as used in avro json encoding of unions: full name for
+// named types and type name for others
+func TypeName(s ItemSchema) string {
+	switch t := resolve(s).(type) {
+	case AvroNull:
+		return "null"
+	case AvroBoolean:
+		return "boolean"
+	case AvroInt:
+		return "int"
+	case AvroLong:
+		return "long"
+	case AvroFloat:
+		return "float"
+	case AvroDouble:
+		return "double"
+	case AvroBytes:
+		return "bytes"
+	case AvroString:
+		return "string"
+	case AvroArray:
+		return "array"
+	case AvroMap:
+		return "map"
+	case AvroUnion:
+		return "union"
+	default:
+		return namedTypeName(t)
+	}
+}
+
+// MinSize returns the smallest number of bytes value of the schema is encoded with
+func MinSize(s ItemSchema) int {
+	return minSize(s, nil)
+}
+
+// minSize skips records that are already being sized, they can't contain themselves
+// without a union or a collection
+func minSize(s ItemSchema, parents []string) int {
+	switch t := resolve(s).(type) {
+	case AvroNull:
+		return 0
+	case AvroFloat:
+		return 4
+	case AvroDouble:
+		return 8
+	case AvroFixed:
+		return t.Size()
+	case AvroRecord:
+		name := t.FullName()
+		for _, parent := range parents {
+			if parent == name {
+				return 0
+			}
+		}
+		parents = append(parents, name)
+		size := 0
+		for _, f := range t.Fields() {
+			size += minSize(f.Type(), parents)
+		}
+		return size
+	case AvroUnion:
+		size := -1
+		for _, element := range t.Elements() {
+			if elementSize := minSize(element, parents); size < 0 || elementSize < size {
+				size = elementSize
+			}
+		}
+		// branch index precedes the value
+		return 1 + size
+	}
+	// booleans, varints, lengths of bytes and strings, enum indexes and block counts of
+	// arrays and maps take at least a byte
+	return 1
+}
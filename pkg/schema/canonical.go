@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+const crc64Empty = uint64(0xc15d213aa4d7a795)
+
+var crc64Table = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (crc64Empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	return table
+}()
+
+// CanonicalForm returns parsing canonical form of the schema as defined by avro specification
+func CanonicalForm(s ItemSchema) string {
+	builder := strings.Builder{}
+	writeCanonical(&builder, s, make(map[string]bool))
+	return builder.String()
+}
+
+// Fingerprint64 returns CRC-64-AVRO fingerprint of parsing canonical form of the schema
+func Fingerprint64(s ItemSchema) uint64 {
+	return Crc64([]byte(CanonicalForm(s)))
+}
+
+func Crc64(data []byte) uint64 {
+	fp := crc64Empty
+	for _, b := range data {
+		fp = (fp >> 8) ^ crc64Table[byte(fp)^b]
+	}
+	return fp
+}
+
+func quoteJSON(value string) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func writeCanonical(builder *strings.Builder, s ItemSchema, written map[string]bool) {
+	switch t := resolve(s).(type) {
+	case AvroNull:
+		builder.WriteString(`"null"`)
+	case AvroBoolean:
+		builder.WriteString(`"boolean"`)
+	case AvroInt:
+		builder.WriteString(`"int"`)
+	case AvroLong:
+		builder.WriteString(`"long"`)
+	case AvroFloat:
+		builder.WriteString(`"float"`)
+	case AvroDouble:
+		builder.WriteString(`"double"`)
+	case AvroBytes:
+		builder.WriteString(`"bytes"`)
+	case AvroString:
+		builder.WriteString(`"string"`)
+	case AvroRecord:
+		if writeNamedReference(builder, t.FullName(), written) {
+			return
+		}
+		builder.WriteString(`{"name":` + quoteJSON(t.FullName()) + `,"type":"record","fields":[`)
+		for idx, f := range t.fields {
+			if idx > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(`{"name":` + quoteJSON(f.name) + `,"type":`)
+			writeCanonical(builder, f.fieldType, written)
+			builder.WriteString("}")
+		}
+		builder.WriteString("]}")
+	case AvroEnum:
+		if writeNamedReference(builder, t.FullName(), written) {
+			return
+		}
+		builder.WriteString(`{"name":` + quoteJSON(t.FullName()) + `,"type":"enum","symbols":[`)
+		for idx, symbol := range t.symbols {
+			if idx > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(quoteJSON(symbol))
+		}
+		builder.WriteString("]}")
+	case AvroFixed:
+		if writeNamedReference(builder, t.FullName(), written) {
+			return
+		}
+		builder.WriteString(`{"name":` + quoteJSON(t.FullName()) + `,"type":"fixed","size":` + strconv.Itoa(t.size) + "}")
+	case AvroArray:
+		builder.WriteString(`{"type":"array","items":`)
+		writeCanonical(builder, t.itemSchema, written)
+		builder.WriteString("}")
+	case AvroMap:
+		builder.WriteString(`{"type":"map","values":`)
+		writeCanonical(builder, t.values, written)
+		builder.WriteString("}")
+	case AvroUnion:
+		builder.WriteString("[")
+		for idx, element := range t.elements {
+			if idx > 0 {
+				builder.WriteString(",")
+			}
+			writeCanonical(builder, element, written)
+		}
+		builder.WriteString("]")
+	}
+}
+
+// writeNamedReference writes only the name of named type that was already written
+func writeNamedReference(builder *strings.Builder, name string, written map[string]bool) bool {
+	if written[name] {
+		builder.WriteString(quoteJSON(name))
+		return true
+	}
+	written[name] = true
+	return false
+}
@@ -0,0 +1,179 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CheckCompatibility verifies that data written with writer schema can be read
+// with reader schema according to avro schema resolution rules.
+func CheckCompatibility(reader, writer ItemSchema) error {
+	checker := compatibilityChecker{checked: make(map[string]bool)}
+	return checker.check(reader, writer, "")
+}
+
+type compatibilityChecker struct {
+	// pairs of named types that are already checked or being checked, used to stop recursion
+	checked map[string]bool
+}
+
+func unqualifiedName(name string) string {
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+func (c *compatibilityChecker) checkNames(kind, readerName, writerName, path string) error {
+	if unqualifiedName(readerName) != unqualifiedName(writerName) {
+		return fmt.Errorf("%s name mismatch at %s: reader %s, writer %s", kind, pathOrRoot(path), readerName, writerName)
+	}
+	return nil
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func isPromotable(reader, writer ItemSchema) bool {
+	switch reader.(type) {
+	case AvroLong:
+		_, ok := writer.(AvroInt)
+		return ok
+	case AvroFloat:
+		switch writer.(type) {
+		case AvroInt, AvroLong:
+			return true
+		}
+	case AvroDouble:
+		switch writer.(type) {
+		case AvroInt, AvroLong, AvroFloat:
+			return true
+		}
+	case AvroString:
+		_, ok := writer.(AvroBytes)
+		return ok
+	case AvroBytes:
+		_, ok := writer.(AvroString)
+		return ok
+	}
+	return false
+}
+
+func (c *compatibilityChecker) check(reader, writer ItemSchema, path string) error {
+	reader = resolve(reader)
+	writer = resolve(writer)
+
+	if writerUnion, ok := writer.(AvroUnion); ok {
+		for idx, element := range writerUnion.elements {
+			if err := c.check(reader, element, path); err != nil {
+				return fmt.Errorf("writer union element %d is not readable: %w", idx, err)
+			}
+		}
+		return nil
+	}
+	if readerUnion, ok := reader.(AvroUnion); ok {
+		for _, element := range readerUnion.elements {
+			if c.check(element, writer, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("no reader union element at %s matches writer schema %T", pathOrRoot(path), writer)
+	}
+
+	switch r := reader.(type) {
+	case AvroRecord:
+		w, ok := writer.(AvroRecord)
+		if !ok {
+			break
+		}
+		if err := c.checkNames("record", r.name, w.name, path); err != nil {
+			return err
+		}
+		key := "record:" + r.name + ":" + w.name
+		if c.checked[key] {
+			return nil
+		}
+		c.checked[key] = true
+		for _, readerField := range r.fields {
+			fieldPath := path + "/" + readerField.name
+			if writerField, found := w.findField(readerField); found {
+				if err := c.check(readerField.fieldType, writerField.fieldType, fieldPath); err != nil {
+					return err
+				}
+			} else if !readerField.hasDefault {
+				return fmt.Errorf("reader field %s has no default and is missing in writer schema", fieldPath)
+			}
+		}
+		return nil
+	case AvroEnum:
+		w, ok := writer.(AvroEnum)
+		if !ok {
+			break
+		}
+		if err := c.checkNames("enum", r.name, w.name, path); err != nil {
+			return err
+		}
+		if r.defaultValue != nil {
+			return nil
+		}
+		for _, symbol := range w.symbols {
+			if !containsString(r.symbols, symbol) {
+				return fmt.Errorf("enum %s at %s is missing writer symbol %s", r.name, pathOrRoot(path), symbol)
+			}
+		}
+		return nil
+	case AvroFixed:
+		w, ok := writer.(AvroFixed)
+		if !ok {
+			break
+		}
+		if err := c.checkNames("fixed", r.name, w.name, path); err != nil {
+			return err
+		}
+		if r.size != w.size {
+			return fmt.Errorf("fixed %s at %s size mismatch: reader %d, writer %d", r.name, pathOrRoot(path), r.size, w.size)
+		}
+		return nil
+	case AvroArray:
+		if w, ok := writer.(AvroArray); ok {
+			return c.check(r.itemSchema, w.itemSchema, path+"/[]")
+		}
+	case AvroMap:
+		if w, ok := writer.(AvroMap); ok {
+			return c.check(r.values, w.values, path+"/{}")
+		}
+	default:
+		if reflect.TypeOf(reader) == reflect.TypeOf(writer) || isPromotable(reader, writer) {
+			return nil
+		}
+	}
+	return fmt.Errorf("type mismatch at %s: reader %T, writer %T", pathOrRoot(path), reader, writer)
+}
+
+func (v AvroRecord) findField(field AvroRecordField) (AvroRecordField, bool) {
+	for _, f := range v.fields {
+		if f.name == field.name {
+			return f, true
+		}
+	}
+	for _, f := range v.fields {
+		if containsString(field.aliases, f.name) {
+			return f, true
+		}
+	}
+	return AvroRecordField{}, false
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
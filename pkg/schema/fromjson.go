@@ -0,0 +1,315 @@
+package schema
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PathError is error of converting value at the path of record fields, map keys and array indexes
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// FromJSON converts value decoded from json to the value that can be written with the schema.
+// In avro json encoding unions are objects with single key naming the type and bytes are
+// ISO-8859-1 strings. Otherwise union element is chosen by the value and bytes are base64
+// strings. Missing record fields are filled with defaults. Values of union elements that
+// can't be told by the value are UnionValue.
+func FromJSON(s ItemSchema, value interface{}, avroEncoding bool) (interface{}, error) {
+	c := jsonConverter{avroEncoding: avroEncoding}
+	result, err := c.convert(s, value, "")
+	if err != nil {
+		if pathErr, ok := err.(*PathError); ok {
+			return nil, pathErr
+		}
+		return nil, &PathError{Err: err}
+	}
+	return result, nil
+}
+
+// DefaultValue returns default value of the field converted to the value that can be written
+// with the field type
+func (f AvroRecordField) DefaultValue() (interface{}, error) {
+	if !f.hasDefault {
+		return nil, fmt.Errorf("field %s has no default value", f.name)
+	}
+	return DefaultFromJSON(f.fieldType, f.defaultValue)
+}
+
+// DefaultFromJSON converts default value of the schema decoded from json to the value that
+// can be written with the schema. Defaults of unions are values of their first element.
+func DefaultFromJSON(s ItemSchema, value interface{}) (interface{}, error) {
+	converted, err := jsonConverter{avroEncoding: true, defaults: true}.convert(s, value, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid default value: %w", err)
+	}
+	return converted, nil
+}
+
+type jsonConverter struct {
+	avroEncoding bool
+	// strict disables reading longs from strings, used to prefer exact matches of union elements
+	strict bool
+	// defaults are always in avro json encoding except unions, which use the first element
+	defaults bool
+}
+
+func (c jsonConverter) convert(s ItemSchema, value interface{}, path string) (interface{}, error) {
+	switch t := resolve(s).(type) {
+	case AvroNull:
+		if value != nil {
+			return nil, &PathError{Path: path, Err: fmt.Errorf("expected null, got %v", value)}
+		}
+		return nil, nil
+	case AvroBoolean:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case AvroInt:
+		if i, ok := toInt64(value); ok && i >= math.MinInt32 && i <= math.MaxInt32 {
+			return int32(i), nil
+		}
+	case AvroLong:
+		if i, ok := toInt64(value); ok {
+			return i, nil
+		}
+		// longs may be written as strings to avoid precision loss
+		if str, ok := value.(string); ok && !c.avroEncoding && !c.strict {
+			if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+				return i, nil
+			}
+		}
+	case AvroFloat:
+		if f, ok := jsonFloat(value); ok {
+			return float32(f), nil
+		}
+	case AvroDouble:
+		if f, ok := jsonFloat(value); ok {
+			return f, nil
+		}
+	case AvroString:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+	case AvroBytes, AvroFixed:
+		if str, ok := value.(string); ok {
+			data, err := c.bytes(str)
+			if err != nil {
+				return nil, &PathError{Path: path, Err: err}
+			}
+			if fixed, ok := t.(AvroFixed); ok && len(data) != fixed.size {
+				return nil, &PathError{Path: path, Err: fmt.Errorf("expected %d bytes of fixed %s, got %d", fixed.size, fixed.FullName(), len(data))}
+			}
+			return data, nil
+		}
+	case AvroEnum:
+		if str, ok := value.(string); ok && containsString(t.symbols, str) {
+			return str, nil
+		}
+		return nil, &PathError{Path: path, Err: fmt.Errorf("expected symbol of enum %s, got %v", t.FullName(), value)}
+	case AvroArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		result := make([]interface{}, len(items))
+		for idx, item := range items {
+			converted, err := c.convert(t.itemSchema, item, fmt.Sprintf("%s[%d]", path, idx))
+			if err != nil {
+				return nil, err
+			}
+			result[idx] = converted
+		}
+		return result, nil
+	case AvroMap:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		result := make(map[string]interface{}, len(m))
+		for key, item := range m {
+			converted, err := c.convert(t.values, item, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = converted
+		}
+		return result, nil
+	case AvroRecord:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		return c.convertRecord(t, m, path)
+	case AvroUnion:
+		return c.convertUnion(t, value, path)
+	}
+	return nil, &PathError{Path: path, Err: fmt.Errorf("expected %s, got %s", TypeName(s), jsonTypeName(value))}
+}
+
+func (c jsonConverter) convertRecord(t AvroRecord, m map[string]interface{}, path string) (interface{}, error) {
+	result := make(map[string]interface{}, len(t.fields))
+	for _, f := range t.fields {
+		fieldPath := joinPath(path, f.name)
+		if fieldValue, found := m[f.name]; found {
+			converted, err := c.convert(f.fieldType, fieldValue, fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			result[f.name] = converted
+		} else if f.hasDefault {
+			converted, err := f.DefaultValue()
+			if err != nil {
+				return nil, &PathError{Path: fieldPath, Err: err}
+			}
+			result[f.name] = converted
+		} else {
+			return nil, &PathError{Path: fieldPath, Err: fmt.Errorf("value is missing and no default is set")}
+		}
+	}
+	if !c.defaults {
+		for key := range m {
+			if _, found := t.Field(key); !found {
+				return nil, &PathError{Path: joinPath(path, key), Err: fmt.Errorf("field is not declared in record %s", t.FullName())}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (c jsonConverter) convertUnion(t AvroUnion, value interface{}, path string) (interface{}, error) {
+	if len(t.elements) == 0 {
+		return nil, &PathError{Path: path, Err: fmt.Errorf("empty union can't have value")}
+	}
+	if c.defaults {
+		converted, err := c.convert(t.elements[0], value, path)
+		if err != nil {
+			return nil, err
+		}
+		return t.tag(0, converted), nil
+	}
+	if !c.avroEncoding {
+		// the first element accepting the value is used, exact matches are tried first
+		strict := c
+		strict.strict = true
+		var nestedErr error
+		for _, converter := range []jsonConverter{strict, c} {
+			for idx, element := range t.elements {
+				converted, err := converter.convert(element, value, path)
+				if err == nil {
+					return t.tag(idx, converted), nil
+				}
+				// error inside of nested value is more helpful than type mismatch of the union
+				if pathErr, ok := err.(*PathError); ok && pathErr.Path != path && nestedErr == nil {
+					nestedErr = err
+				}
+			}
+		}
+		if nestedErr != nil {
+			return nil, nestedErr
+		}
+		names := make([]string, len(t.elements))
+		for idx, element := range t.elements {
+			names[idx] = TypeName(element)
+		}
+		return nil, &PathError{Path: path, Err: fmt.Errorf("%s doesn't match any of union types %s", jsonTypeName(value), strings.Join(names, ", "))}
+	}
+
+	if value == nil {
+		for _, element := range t.elements {
+			if _, ok := resolve(element).(AvroNull); ok {
+				return nil, nil
+			}
+		}
+		return nil, &PathError{Path: path, Err: fmt.Errorf("union has no null type")}
+	}
+	wrapped, ok := value.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return nil, &PathError{Path: path, Err: fmt.Errorf("union value should be object with single key naming the type, got %v", value)}
+	}
+	for typeName, typeValue := range wrapped {
+		for idx, element := range t.elements {
+			name := TypeName(element)
+			if name == typeName || unqualifiedName(name) == typeName {
+				converted, err := c.convert(element, typeValue, joinPath(path, typeName))
+				if err != nil {
+					return nil, err
+				}
+				return t.tag(idx, converted), nil
+			}
+		}
+		return nil, &PathError{Path: path, Err: fmt.Errorf("type %s is not in union", typeName)}
+	}
+	return nil, nil
+}
+
+func (c jsonConverter) bytes(value string) ([]byte, error) {
+	if c.avroEncoding {
+		for _, r := range value {
+			if r > 0xff {
+				return nil, fmt.Errorf("bytes string has character %q outside of ISO-8859-1", r)
+			}
+		}
+		return latin1Bytes(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("bytes should be base64 encoded: %w", err)
+	}
+	return data, nil
+}
+
+// jsonFloat reads number or string for NaN and infinities
+func jsonFloat(value interface{}) (float64, bool) {
+	switch value {
+	case "NaN":
+		return math.NaN(), true
+	case "Infinity":
+		return math.Inf(1), true
+	case "-Infinity":
+		return math.Inf(-1), true
+	}
+	return toFloat64(value)
+}
+
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return fmt.Sprintf("number %v", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
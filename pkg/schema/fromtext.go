@@ -0,0 +1,213 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	timestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02",
+	}
+	timeLayout = "15:04:05.999999999"
+	dateLayout = "2006-01-02"
+	uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// FromText parses textual representation of the value, like a cell of csv file. Values of
+// logical types are read in their usual formats: dates as 2006-01-02, times as 15:04:05.000,
+// timestamps as RFC 3339 with optional zone and decimals as decimal numbers. Numbers of
+// underlying types are accepted too. Bytes and fixed are base64, records, arrays and maps
+// are json. Union value is read with the first non-null element that accepts the text.
+func FromText(s ItemSchema, text string) (interface{}, error) {
+	logical := LogicalTypeOf(s)
+	switch t := resolve(s).(type) {
+	case AvroNull:
+		return nil, fmt.Errorf("null type has no value")
+	case AvroBoolean:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("expected boolean, got %q", text)
+		}
+		return b, nil
+	case AvroInt:
+		if i, err := strconv.ParseInt(text, 10, 32); err == nil {
+			return int32(i), nil
+		}
+		if logical != nil {
+			switch logical.Name {
+			case LogicalDate:
+				if date, err := time.Parse(dateLayout, text); err == nil {
+					return int32(date.Unix() / (24 * 60 * 60)), nil
+				}
+				return nil, fmt.Errorf("expected date as %s, got %q", dateLayout, text)
+			case LogicalTimeMillis:
+				if timeOfDay, err := parseTimeOfDay(text); err == nil {
+					return int32(timeOfDay / time.Millisecond), nil
+				}
+				return nil, fmt.Errorf("expected time as 15:04:05.000, got %q", text)
+			}
+		}
+		return nil, fmt.Errorf("expected int, got %q", text)
+	case AvroLong:
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, nil
+		}
+		if logical != nil {
+			if logical.Name == LogicalTimeMicros {
+				if timeOfDay, err := parseTimeOfDay(text); err == nil {
+					return int64(timeOfDay / time.Microsecond), nil
+				}
+				return nil, fmt.Errorf("expected time as 15:04:05.000000, got %q", text)
+			}
+			if timestamp, ok := ParseTimestamp(text); ok {
+				switch logical.Name {
+				case LogicalTimestampMillis, LogicalLocalTimestampMillis:
+					return timestamp.UnixMilli(), nil
+				case LogicalTimestampMicros, LogicalLocalTimestampMicros:
+					return timestamp.UnixMicro(), nil
+				case LogicalTimestampNanos, LogicalLocalTimestampNanos:
+					return timestamp.UnixNano(), nil
+				}
+			} else {
+				return nil, fmt.Errorf("expected %s as RFC 3339 timestamp, got %q", logical.Name, text)
+			}
+		}
+		return nil, fmt.Errorf("expected long, got %q", text)
+	case AvroFloat:
+		f, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return nil, fmt.Errorf("expected float, got %q", text)
+		}
+		return float32(f), nil
+	case AvroDouble:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected double, got %q", text)
+		}
+		return f, nil
+	case AvroString:
+		if logical != nil && logical.Name == LogicalUUID && !uuidRegexp.MatchString(text) {
+			return nil, fmt.Errorf("expected uuid, got %q", text)
+		}
+		return text, nil
+	case AvroBytes:
+		if logical != nil && logical.Name == LogicalDecimal {
+			return decimalBytes(text, logical, 0)
+		}
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("bytes should be base64 encoded: %w", err)
+		}
+		return data, nil
+	case AvroFixed:
+		if logical != nil && logical.Name == LogicalDecimal {
+			return decimalBytes(text, logical, t.size)
+		}
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("fixed should be base64 encoded: %w", err)
+		}
+		if len(data) != t.size {
+			return nil, fmt.Errorf("expected %d bytes of fixed %s, got %d", t.size, t.FullName(), len(data))
+		}
+		return data, nil
+	case AvroEnum:
+		if !containsString(t.symbols, text) {
+			return nil, fmt.Errorf("expected symbol of enum %s, got %q", t.FullName(), text)
+		}
+		return text, nil
+	case AvroUnion:
+		var firstErr error
+		for idx, element := range t.elements {
+			if _, isNull := resolve(element).(AvroNull); isNull {
+				continue
+			}
+			value, err := FromText(element, text)
+			if err == nil {
+				return t.tag(idx, value), nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr == nil {
+			return nil, fmt.Errorf("union has no types except null")
+		}
+		return nil, firstErr
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("expected %s as json: %w", TypeName(s), err)
+	}
+	return FromJSON(s, value, false)
+}
+
+func parseTimeOfDay(text string) (time.Duration, error) {
+	parsed, err := time.Parse(timeLayout, text)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute +
+		time.Duration(parsed.Second())*time.Second + time.Duration(parsed.Nanosecond()), nil
+}
+
+// ParseTimestamp parses timestamp with or without zone, timestamps without zone are in UTC
+func ParseTimestamp(text string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if timestamp, err := time.Parse(layout, text); err == nil {
+			return timestamp, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// decimalBytes encodes decimal number as big-endian two's complement unscaled value,
+// value is sign-extended to size of fixed or takes as few bytes as possible if size is 0
+func decimalBytes(text string, logical *LogicalType, size int) ([]byte, error) {
+	value, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil, fmt.Errorf("expected decimal, got %q", text)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(logical.Scale)), nil)
+	value.Mul(value, new(big.Rat).SetInt(scale))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("decimal %s has more than %d digits after decimal point", text, logical.Scale)
+	}
+	unscaled := value.Num()
+	if digits := len(new(big.Int).Abs(unscaled).String()); digits > logical.Precision && unscaled.Sign() != 0 {
+		return nil, fmt.Errorf("decimal %s has more than %d digits", text, logical.Precision)
+	}
+
+	// the smallest number of bytes keeping the sign bit
+	length := unscaled.BitLen()/8 + 1
+	if unscaled.Sign() < 0 {
+		// -2^(8n-1) fits in n bytes
+		length = new(big.Int).Sub(new(big.Int).Neg(unscaled), big.NewInt(1)).BitLen()/8 + 1
+	}
+	if size == 0 {
+		size = length
+	} else if length > size {
+		return nil, fmt.Errorf("decimal %s doesn't fit in %d bytes", text, size)
+	}
+	result := make([]byte, size)
+	if unscaled.Sign() < 0 {
+		// two's complement is 2^(8*size) + value
+		unscaled = new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(8*size)), unscaled)
+	}
+	unscaled.FillBytes(result)
+	return result, nil
+}
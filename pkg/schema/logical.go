@@ -0,0 +1,92 @@
+package schema
+
+import "math"
+
+const (
+	LogicalDecimal              = "decimal"
+	LogicalUUID                 = "uuid"
+	LogicalDate                 = "date"
+	LogicalTimeMillis           = "time-millis"
+	LogicalTimeMicros           = "time-micros"
+	LogicalTimestampMillis      = "timestamp-millis"
+	LogicalTimestampMicros      = "timestamp-micros"
+	LogicalTimestampNanos       = "timestamp-nanos"
+	LogicalLocalTimestampMillis = "local-timestamp-millis"
+	LogicalLocalTimestampMicros = "local-timestamp-micros"
+	LogicalLocalTimestampNanos  = "local-timestamp-nanos"
+	LogicalDuration             = "duration"
+)
+
+// LogicalType annotates primitive or fixed type, values are read and written as values
+// of the annotated type
+type LogicalType struct {
+	Name string
+	// Precision and Scale are set for decimals
+	Precision int
+	Scale     int
+}
+
+// logicalTypeBases are types each logical type may annotate
+var logicalTypeBases = map[string][]string{
+	LogicalDecimal:              {"bytes", "fixed"},
+	LogicalUUID:                 {"string", "fixed"},
+	LogicalDate:                 {"int"},
+	LogicalTimeMillis:           {"int"},
+	LogicalTimeMicros:           {"long"},
+	LogicalTimestampMillis:      {"long"},
+	LogicalTimestampMicros:      {"long"},
+	LogicalTimestampNanos:       {"long"},
+	LogicalLocalTimestampMillis: {"long"},
+	LogicalLocalTimestampMicros: {"long"},
+	LogicalLocalTimestampNanos:  {"long"},
+	LogicalDuration:             {"fixed"},
+}
+
+// readLogicalType reads logical type of the type with given name, unknown and invalid
+// logical types are ignored as the specification requires. Size is set for fixed types.
+func readLogicalType(data map[string]interface{}, typeName string, size int) *LogicalType {
+	name, ok := data["logicalType"].(string)
+	if !ok || !containsString(logicalTypeBases[name], typeName) {
+		return nil
+	}
+	result := &LogicalType{Name: name}
+	switch name {
+	case LogicalDecimal:
+		var err error
+		if result.Precision, err = getIntValue(data, "precision", true); err != nil || result.Precision <= 0 {
+			return nil
+		}
+		if result.Scale, err = getIntValue(data, "scale", false); err != nil || result.Scale < 0 || result.Scale > result.Precision {
+			return nil
+		}
+		if typeName == "fixed" && float64(result.Precision) > math.Floor(math.Log10(2)*float64(8*size-1)) {
+			return nil
+		}
+	case LogicalUUID:
+		if typeName == "fixed" && size != 16 {
+			return nil
+		}
+	case LogicalDuration:
+		if size != 12 {
+			return nil
+		}
+	}
+	return result
+}
+
+// LogicalTypeOf returns logical type annotating the schema, nil if there is none
+func LogicalTypeOf(s ItemSchema) *LogicalType {
+	switch t := resolve(s).(type) {
+	case AvroInt:
+		return t.logicalType
+	case AvroLong:
+		return t.logicalType
+	case AvroBytes:
+		return t.logicalType
+	case AvroString:
+		return t.logicalType
+	case AvroFixed:
+		return t.logicalType
+	}
+	return nil
+}
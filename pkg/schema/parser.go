@@ -1,40 +1,146 @@
 package schema
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
+	"strings"
 )
 
 type avroReferenceSchema struct {
 	name string
+	// namespace of the type where the reference is used
+	namespace string
+	// root is index of the parsed schema the reference is used in
+	root int
 	ref  ItemSchema
 }
 
-func (v avroReferenceSchema) Read(reader io.Reader) (interface{}, error) {
+func (v *avroReferenceSchema) Read(reader io.Reader) (interface{}, error) {
 	return v.ref.Read(reader)
 }
 
+func (v *avroReferenceSchema) Write(writer io.Writer, value interface{}) error {
+	return v.ref.Write(writer, value)
+}
+
+// ReferenceError is returned by parser when a schema references named type that is not
+// defined, parsing may succeed when the type is known
+type ReferenceError struct {
+	Name string
+	// Root is index of the parsed schema with the reference
+	Root int
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("failed to find reference to schema with name %s", e.Name)
+}
+
+///////////////////////
+
 type schemaBuilder struct {
-	references   []avroReferenceSchema
+	references   []*avroReferenceSchema
 	namedSchemas map[string]ItemSchema
+	// named types defined more than once, allowed only if definitions are the same
+	redefinitions []ItemSchema
+	// namespace of enclosing named type
+	namespace string
+	// root is index of the schema being read
+	root int
 }
 
-func (builder *schemaBuilder) read(schema interface{}) (ItemSchema, error) {
+// read reads schemas, namespace is used for named types without namespace in root schemas
+func (builder *schemaBuilder) read(namespace string, schemas ...interface{}) ([]ItemSchema, error) {
+	builder.references = make([]*avroReferenceSchema, 0)
+	builder.redefinitions = make([]ItemSchema, 0)
 	// step 1. read all schema elements
-	root, err := builder.readSchemaElement(schema)
-	if err != nil {
-		return nil, err
+	roots := make([]ItemSchema, len(schemas))
+	for idx, schema := range schemas {
+		builder.namespace, builder.root = namespace, idx
+		root, err := builder.readSchemaElement(schema)
+		if err != nil {
+			return nil, err
+		}
+		roots[idx] = root
 	}
 	// step 2. resolve reference to schema elements
 	for _, reference := range builder.references {
-		if actualSchema, found := builder.namedSchemas[reference.name]; found {
+		if actualSchema, found := builder.lookup(reference.name, reference.namespace); found {
 			reference.ref = actualSchema
 		} else {
-			return nil, fmt.Errorf("failed to find reference to schema with name %s", reference.name)
+			return nil, &ReferenceError{Name: reference.name, Root: reference.root}
+		}
+	}
+	for _, redefinition := range builder.redefinitions {
+		name := namedTypeName(redefinition)
+		if CanonicalForm(redefinition) != CanonicalForm(builder.namedSchemas[name]) {
+			return nil, fmt.Errorf("type %s is defined more than once", name)
 		}
 	}
-	return root, nil
+	for idx, root := range roots {
+		roots[idx] = resolve(root)
+	}
+	return roots, nil
+}
+
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// lookup finds named type by full name, falling back to types without namespace
+func (builder *schemaBuilder) lookup(name, namespace string) (ItemSchema, bool) {
+	if actualSchema, found := builder.namedSchemas[fullName(name, namespace)]; found {
+		return actualSchema, true
+	}
+	actualSchema, found := builder.namedSchemas[name]
+	return actualSchema, found
+}
+
+// readName reads name and namespace of a named type. Namespace is taken from the full name
+// if it is set, otherwise from namespace attribute or from enclosing type.
+func (builder *schemaBuilder) readName(data map[string]interface{}) (string, string, error) {
+	name, err := getStringValue(data, "name", true)
+	if err != nil {
+		return "", "", err
+	}
+	namespace, err := getStringValue(data, "namespace", false)
+	if err != nil {
+		return "", "", err
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[idx+1:], name[:idx], nil
+	}
+	if _, present := data["namespace"]; !present {
+		namespace = builder.namespace
+	}
+	return name, namespace, nil
+}
+
+func (builder *schemaBuilder) register(name, namespace string, schema ItemSchema) error {
+	key := fullName(name, namespace)
+	if _, exists := builder.namedSchemas[key]; exists {
+		builder.redefinitions = append(builder.redefinitions, schema)
+	} else {
+		builder.namedSchemas[key] = schema
+	}
+	return nil
+}
+
+func namedTypeName(schema ItemSchema) string {
+	switch t := schema.(type) {
+	case AvroRecord:
+		return t.FullName()
+	case AvroEnum:
+		return t.FullName()
+	case AvroFixed:
+		return t.FullName()
+	default:
+		return ""
+	}
 }
 
 func (builder *schemaBuilder) readUnion(schemaItems []interface{}) (ItemSchema, error) {
@@ -65,10 +171,21 @@ func (builder *schemaBuilder) readRecordField(data map[string]interface{}) (Avro
 			return result, err
 		}
 	}
-	result.defaultValue, _ = data["default"]
+	result.defaultValue, result.hasDefault = data["default"]
 	if result.aliases, err = readStringArray(data, "aliases", false); err != nil {
 		return result, err
 	}
+	order, err := getStringValue(data, "order", false)
+	if err != nil {
+		return result, err
+	}
+	switch result.order = AvroRecordFieldOrder(order); result.order {
+	case "":
+		result.order = AvroRecordFieldOrderAscending
+	case AvroRecordFieldOrderAscending, AvroRecordFieldOrderDescending, AvroRecordFieldOrderIgnore:
+	default:
+		return result, fmt.Errorf("unknown order %s of field %s, expected ascending, descending or ignore", order, result.name)
+	}
 	return result, nil
 }
 
@@ -78,10 +195,7 @@ func (builder *schemaBuilder) readRecord(data map[string]interface{}) (ItemSchem
 		fields:  make([]AvroRecordField, 0),
 	}
 	var err error
-	if result.name, err = getStringValue(data, "name", true); err != nil {
-		return nil, err
-	}
-	if result.namespace, err = getStringValue(data, "namespace", false); err != nil {
+	if result.name, result.namespace, err = builder.readName(data); err != nil {
 		return nil, err
 	}
 	if result.doc, err = getStringValue(data, "doc", false); err != nil {
@@ -90,6 +204,9 @@ func (builder *schemaBuilder) readRecord(data map[string]interface{}) (ItemSchem
 	if result.aliases, err = readStringArray(data, "aliases", false); err != nil {
 		return nil, err
 	}
+	enclosingNamespace := builder.namespace
+	builder.namespace = result.namespace
+	defer func() { builder.namespace = enclosingNamespace }()
 	if fields, exists := data["fields"]; exists {
 		if kind := reflect.TypeOf(fields).Kind(); kind != reflect.Array && kind != reflect.Slice {
 			return nil, fmt.Errorf("fields should be an array in type %v", result)
@@ -104,17 +221,13 @@ func (builder *schemaBuilder) readRecord(data map[string]interface{}) (ItemSchem
 			}
 		}
 	}
-	builder.namedSchemas[result.name] = result
-	return result, err
+	return result, builder.register(result.name, result.namespace, result)
 }
 
 func (builder *schemaBuilder) readEnum(data map[string]interface{}) (ItemSchema, error) {
 	result := AvroEnum{}
 	var err error
-	if result.name, err = getStringValue(data, "name", true); err != nil {
-		return result, err
-	}
-	if result.namespace, err = getStringValue(data, "namespace", false); err != nil {
+	if result.name, result.namespace, err = builder.readName(data); err != nil {
 		return nil, err
 	}
 	if result.doc, err = getStringValue(data, "doc", false); err != nil {
@@ -133,8 +246,7 @@ func (builder *schemaBuilder) readEnum(data map[string]interface{}) (ItemSchema,
 	if result.symbols, err = readStringArray(data, "symbols", true); err != nil {
 		return result, err
 	}
-	builder.namedSchemas[result.name] = result
-	return result, nil
+	return result, builder.register(result.name, result.namespace, result)
 }
 
 func (builder *schemaBuilder) readArray(data map[string]interface{}) (ItemSchema, error) {
@@ -164,10 +276,7 @@ func (builder *schemaBuilder) readMap(data map[string]interface{}) (ItemSchema,
 func (builder *schemaBuilder) readFixed(data map[string]interface{}) (ItemSchema, error) {
 	result := AvroFixed{}
 	var err error
-	if result.name, err = getStringValue(data, "name", true); err != nil {
-		return result, err
-	}
-	if result.namespace, err = getStringValue(data, "namespace", false); err != nil {
+	if result.name, result.namespace, err = builder.readName(data); err != nil {
 		return nil, err
 	}
 	if result.doc, err = getStringValue(data, "doc", false); err != nil {
@@ -179,8 +288,8 @@ func (builder *schemaBuilder) readFixed(data map[string]interface{}) (ItemSchema
 	if result.size, err = getIntValue(data, "size", true); err != nil {
 		return nil, err
 	}
-	builder.namedSchemas[result.name] = result
-	return result, nil
+	result.logicalType = readLogicalType(data, "fixed", result.size)
+	return result, builder.register(result.name, result.namespace, result)
 }
 
 func (builder *schemaBuilder) readSchemaElement(schema interface{}) (ItemSchema, error) {
@@ -215,18 +324,18 @@ func (builder *schemaBuilder) readSchemaElement(schema interface{}) (ItemSchema,
 	case "boolean":
 		return AvroBoolean{}, nil
 	case "int":
-		return AvroInt{}, nil
+		return AvroInt{logicalType: readLogicalType(typeData, "int", 0)}, nil
 	case "long":
-		return AvroLong{}, nil
+		return AvroLong{logicalType: readLogicalType(typeData, "long", 0)}, nil
 	case "float":
 		return AvroFloat{}, nil
 	case "double":
 		return AvroDouble{}, nil
 	case "bytes":
-		return AvroBytes{}, nil
+		return AvroBytes{logicalType: readLogicalType(typeData, "bytes", 0)}, nil
 	case "string":
-		return AvroString{}, nil
-	case "record":
+		return AvroString{logicalType: readLogicalType(typeData, "string", 0)}, nil
+	case "record", "error":
 		return builder.readRecord(typeData)
 	case "enum":
 		return builder.readEnum(typeData)
@@ -237,7 +346,7 @@ func (builder *schemaBuilder) readSchemaElement(schema interface{}) (ItemSchema,
 	case "fixed":
 		return builder.readFixed(typeData)
 	default:
-		fake := avroReferenceSchema{name: typeName}
+		fake := &avroReferenceSchema{name: typeName, namespace: builder.namespace, root: builder.root}
 		builder.references = append(builder.references, fake)
 		return fake, nil
 	}
@@ -245,8 +354,66 @@ func (builder *schemaBuilder) readSchemaElement(schema interface{}) (ItemSchema,
 
 func ParseSchema(schema interface{}) (ItemSchema, error) {
 	builder := schemaBuilder{
-		references:   make([]avroReferenceSchema, 0),
 		namedSchemas: make(map[string]ItemSchema),
 	}
-	return builder.read(schema)
+	if roots, err := builder.read("", schema); err != nil {
+		return nil, err
+	} else {
+		return roots[0], nil
+	}
+}
+
+func ParseSchemaJSON(data []byte) (ItemSchema, error) {
+	var jsonSchema interface{}
+	if err := json.Unmarshal(data, &jsonSchema); err != nil {
+		return nil, fmt.Errorf("failed to parse json %w", err)
+	}
+	return ParseSchema(jsonSchema)
+}
+
+// Parser keeps named types between calls, so that schemas can reference types defined
+// in schemas parsed earlier. References are resolved after all schemas passed to single
+// Parse call are read, so these schemas may reference each other in any order.
+type Parser struct {
+	builder schemaBuilder
+}
+
+func NewParser() *Parser {
+	return &Parser{builder: schemaBuilder{namedSchemas: make(map[string]ItemSchema)}}
+}
+
+func (p *Parser) Parse(schemas ...interface{}) ([]ItemSchema, error) {
+	return p.ParseInNamespace("", schemas...)
+}
+
+// ParseInNamespace parses schemas as if they were nested in named type with given namespace,
+// like types and messages of avro protocol are
+func (p *Parser) ParseInNamespace(namespace string, schemas ...interface{}) ([]ItemSchema, error) {
+	knownSchemas := make(map[string]ItemSchema, len(p.builder.namedSchemas))
+	for name, schema := range p.builder.namedSchemas {
+		knownSchemas[name] = schema
+	}
+	roots, err := p.builder.read(namespace, schemas...)
+	if err != nil {
+		// forget types from failed schemas
+		p.builder.namedSchemas = knownSchemas
+		return nil, err
+	}
+	return roots, nil
+}
+
+func (p *Parser) ParseJSON(data ...[]byte) ([]ItemSchema, error) {
+	jsonSchemas := make([]interface{}, len(data))
+	for idx, item := range data {
+		if err := json.Unmarshal(item, &jsonSchemas[idx]); err != nil {
+			return nil, fmt.Errorf("failed to parse json %w", err)
+		}
+	}
+	return p.Parse(jsonSchemas...)
+}
+
+// NamedType returns named type by its full name
+func (p *Parser) NamedType(name string) (ItemSchema, bool) {
+	schema, found := p.builder.namedSchemas[name]
+	return schema, found
 }
@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 
 type ItemSchema interface {
 	Read(reader io.Reader) (interface{}, error)
+	Write(writer io.Writer, value interface{}) error
 }
 
 ///////////////////////
@@ -20,6 +22,13 @@ func (v AvroNull) Read(_ io.Reader) (interface{}, error) {
 	return nil, nil
 }
 
+func (v AvroNull) Write(_ io.Writer, value interface{}) error {
+	if value != nil {
+		return fmt.Errorf("value %v is not null", value)
+	}
+	return nil
+}
+
 ///////////////////////
 
 type AvroBoolean struct {
@@ -38,30 +47,59 @@ func (v AvroBoolean) Read(r io.Reader) (interface{}, error) {
 	}
 }
 
+func (v AvroBoolean) Write(w io.Writer, value interface{}) error {
+	if b, ok := value.(bool); !ok {
+		return fmt.Errorf("value %v is not boolean", value)
+	} else if b {
+		_, err := w.Write([]byte{1})
+		return err
+	} else {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+}
+
 ///////////////////////
 
 type AvroInt struct {
+	logicalType *LogicalType
 }
 
 func readInt(r io.Reader) (int32, error) {
 	rr := lowOverheadReader{r: r}
+	// binary.ReadVarint already applies zig-zag decoding
 	if result, err := binary.ReadVarint(rr); err != nil {
 		return 0, fmt.Errorf("failed to read value: %w", err)
-	} else if result >= math.MaxUint32 || result < 0 {
+	} else if result > math.MaxInt32 || result < math.MinInt32 {
 		return 0, fmt.Errorf("number %d is out of range for int32", result)
 	} else {
-		int32Val := int32((result >> 1) ^ (-(result & 1)))
-		return int32Val, nil
+		return int32(result), nil
 	}
 }
 
+func writeLong(w io.Writer, value int64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, value)
+	_, err := w.Write(buf[:n])
+	return err
+}
+
 func (v AvroInt) Read(r io.Reader) (interface{}, error) {
 	return readInt(r)
 }
 
+func (v AvroInt) Write(w io.Writer, value interface{}) error {
+	if i, ok := toInt64(value); !ok || i > math.MaxInt32 || i < math.MinInt32 {
+		return fmt.Errorf("value %v is not int", value)
+	} else {
+		return writeLong(w, i)
+	}
+}
+
 ///////////////////////
 
 type AvroLong struct {
+	logicalType *LogicalType
 }
 
 func readLong(r io.Reader) (int64, error) {
@@ -80,6 +118,14 @@ func (v AvroLong) Read(r io.Reader) (interface{}, error) {
 	return readLong(r)
 }
 
+func (v AvroLong) Write(w io.Writer, value interface{}) error {
+	if i, ok := toInt64(value); !ok {
+		return fmt.Errorf("value %v is not long", value)
+	} else {
+		return writeLong(w, i)
+	}
+}
+
 ///////////////////////
 
 type AvroFloat struct {
@@ -94,6 +140,14 @@ func (v AvroFloat) Read(r io.Reader) (interface{}, error) {
 	}
 }
 
+func (v AvroFloat) Write(w io.Writer, value interface{}) error {
+	if f, ok := toFloat64(value); !ok {
+		return fmt.Errorf("value %v is not float", value)
+	} else {
+		return binary.Write(w, binary.LittleEndian, math.Float32bits(float32(f)))
+	}
+}
+
 ///////////////////////
 
 type AvroDouble struct {
@@ -108,19 +162,56 @@ func (v AvroDouble) Read(r io.Reader) (interface{}, error) {
 	}
 }
 
+func (v AvroDouble) Write(w io.Writer, value interface{}) error {
+	if f, ok := toFloat64(value); !ok {
+		return fmt.Errorf("value %v is not double", value)
+	} else {
+		return binary.Write(w, binary.LittleEndian, math.Float64bits(f))
+	}
+}
+
 ///////////////////////
 
 type AvroBytes struct {
+	logicalType *LogicalType
 }
 
 func (v AvroBytes) Read(r io.Reader) (interface{}, error) {
+	return readBytes(r)
+}
+
+func (v AvroBytes) Write(w io.Writer, value interface{}) error {
+	if b, ok := value.([]byte); !ok {
+		return fmt.Errorf("value %v is not bytes", value)
+	} else {
+		return writeBytes(w, b)
+	}
+}
+
+const maxPreallocatedLength = 1 << 20
+
+func readBytes(r io.Reader) ([]byte, error) {
 	length, err := readLong(r)
 	if nil != err {
 		return nil, err
 	}
+	if length < 0 {
+		return nil, fmt.Errorf("negative length %d of bytes contents", length)
+	}
+	if length > maxPreallocatedLength {
+		// length of corrupt data may be huge, so memory is allocated as data is read
+		buffer := bytes.Buffer{}
+		countRead, err := io.CopyN(&buffer, r, length)
+		if nil != err && countRead == 0 {
+			return nil, fmt.Errorf("failed to read bytes contents: %w", err)
+		} else if countRead != length {
+			return nil, fmt.Errorf("not enough bytes (%d) to read contents (%d)", countRead, length)
+		}
+		return buffer.Bytes(), nil
+	}
 	result := make([]byte, length)
-	countRead, err := r.Read(result)
-	if nil != err {
+	countRead, err := io.ReadFull(r, result)
+	if nil != err && countRead == 0 {
 		return nil, fmt.Errorf("failed to read bytes contents: %w", err)
 	} else if int64(countRead) != length {
 		return nil, fmt.Errorf("not enough bytes (%d) to read contents (%d)", countRead, length)
@@ -128,27 +219,37 @@ func (v AvroBytes) Read(r io.Reader) (interface{}, error) {
 	return result, nil
 }
 
+func writeBytes(w io.Writer, value []byte) error {
+	if err := writeLong(w, int64(len(value))); err != nil {
+		return err
+	}
+	_, err := w.Write(value)
+	return err
+}
+
 ///////////////////////
 
 type AvroString struct {
+	logicalType *LogicalType
 }
 
 func (v AvroString) Read(r io.Reader) (interface{}, error) {
 	return readString(r)
 }
 
+func (v AvroString) Write(w io.Writer, value interface{}) error {
+	if s, ok := value.(string); !ok {
+		return fmt.Errorf("value %v is not string", value)
+	} else {
+		return writeBytes(w, []byte(s))
+	}
+}
+
 func readString(r io.Reader) (string, error) {
-	length, err := readLong(r)
-	if nil != err {
+	result, err := readBytes(r)
+	if err != nil {
 		return "", err
 	}
-	result := make([]byte, length)
-	countRead, err := r.Read(result)
-	if nil != err {
-		return "", fmt.Errorf("failed to read bytes contents: %w", err)
-	} else if int64(countRead) != length {
-		return "", fmt.Errorf("not enough bytes (%d) to read contents (%d)", countRead, length)
-	}
 	return string(result), nil
 }
 
@@ -167,6 +268,7 @@ type AvroRecordField struct {
 	doc          string
 	fieldType    ItemSchema
 	defaultValue interface{}
+	hasDefault   bool
 	order        AvroRecordFieldOrder
 	aliases      []string
 }
@@ -179,6 +281,10 @@ type AvroRecord struct {
 	fields    []AvroRecordField
 }
 
+func (v AvroRecord) FullName() string {
+	return fullName(v.name, v.namespace)
+}
+
 func (v AvroRecord) Read(r io.Reader) (interface{}, error) {
 	result := make(map[string]interface{})
 	for _, f := range v.fields {
@@ -194,6 +300,27 @@ func (v AvroRecord) Read(r io.Reader) (interface{}, error) {
 	return result, nil
 }
 
+func (v AvroRecord) Write(w io.Writer, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("value %v is not a record %s", value, v.name)
+	}
+	for _, f := range v.fields {
+		var err error
+		if fieldValue, found := m[f.name]; found {
+			err = f.fieldType.Write(w, fieldValue)
+		} else if f.hasDefault {
+			err = writeDefault(w, f.fieldType, f.defaultValue)
+		} else {
+			err = fmt.Errorf("value is missing and no default is set")
+		}
+		if err != nil {
+			return fmt.Errorf("failed writing %s in type %s: %w", f.name, v.name, err)
+		}
+	}
+	return nil
+}
+
 ///////////////////////
 
 type AvroEnum struct {
@@ -205,6 +332,10 @@ type AvroEnum struct {
 	defaultValue *string
 }
 
+func (v AvroEnum) FullName() string {
+	return fullName(v.name, v.namespace)
+}
+
 func (v AvroEnum) Read(r io.Reader) (interface{}, error) {
 	value, err := readInt(r)
 	if err != nil {
@@ -212,7 +343,7 @@ func (v AvroEnum) Read(r io.Reader) (interface{}, error) {
 	}
 	if value >= int32(len(v.symbols)) || value < 0 {
 		if nil != v.defaultValue && value >= 0 {
-			return v.defaultValue, nil
+			return *v.defaultValue, nil
 		} else {
 			return nil, fmt.Errorf("no enum constant defined for %d, enum %s", value, v.name)
 		}
@@ -221,6 +352,17 @@ func (v AvroEnum) Read(r io.Reader) (interface{}, error) {
 	}
 }
 
+func (v AvroEnum) Write(w io.Writer, value interface{}) error {
+	if s, ok := value.(string); ok {
+		for idx, symbol := range v.symbols {
+			if symbol == s {
+				return writeLong(w, int64(idx))
+			}
+		}
+	}
+	return fmt.Errorf("value %v is not a symbol of enum %s", value, v.name)
+}
+
 ///////////////////////
 
 type AvroArray struct {
@@ -229,7 +371,7 @@ type AvroArray struct {
 
 func (v AvroArray) Read(r io.Reader) (interface{}, error) {
 	hasRecords := true
-	var result []interface{} = nil
+	result := make([]interface{}, 0)
 	for hasRecords {
 		count, err := readLong(r)
 		if err != nil {
@@ -245,19 +387,35 @@ func (v AvroArray) Read(r io.Reader) (interface{}, error) {
 				return nil, fmt.Errorf("failed to read array fast skip section %w", err)
 			}
 		}
-		if result == nil {
-			result = make([]interface{}, count)
-		}
 		for idx := 0; idx < int(count); idx++ {
-			result[idx], err = v.itemSchema.Read(r)
+			item, err := v.itemSchema.Read(r)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read item at idx %d: %w", idx, err)
+				return nil, fmt.Errorf("failed to read item at idx %d: %w", len(result), err)
 			}
+			result = append(result, item)
 		}
 	}
 	return result, nil
 }
 
+func (v AvroArray) Write(w io.Writer, value interface{}) error {
+	items, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("value %v is not an array", value)
+	}
+	if len(items) > 0 {
+		if err := writeLong(w, int64(len(items))); err != nil {
+			return err
+		}
+		for idx, item := range items {
+			if err := v.itemSchema.Write(w, item); err != nil {
+				return fmt.Errorf("failed to write item at idx %d: %w", idx, err)
+			}
+		}
+	}
+	return writeLong(w, 0)
+}
+
 ///////////////////////
 
 type AvroFixed struct {
@@ -266,12 +424,18 @@ type AvroFixed struct {
 	aliases   []string
 	doc       string
 	size      int
+	// logicalType is a decimal or duration stored in fixed
+	logicalType *LogicalType
+}
+
+func (v AvroFixed) FullName() string {
+	return fullName(v.name, v.namespace)
 }
 
 func (v AvroFixed) Read(r io.Reader) (interface{}, error) {
 	result := make([]byte, v.size)
 
-	if read, err := r.Read(result); err != nil {
+	if read, err := io.ReadFull(r, result); err != nil && read == 0 {
 		return 0, fmt.Errorf("failed to read fixed value: %w", err)
 	} else if read != v.size {
 		return 0, fmt.Errorf("number of fixed bytes read %d is not equal to expected: %d", read, v.size)
@@ -280,20 +444,82 @@ func (v AvroFixed) Read(r io.Reader) (interface{}, error) {
 	}
 }
 
+func (v AvroFixed) Write(w io.Writer, value interface{}) error {
+	if b, ok := value.([]byte); !ok || len(b) != v.size {
+		return fmt.Errorf("value %v is not fixed %s of size %d", value, v.name, v.size)
+	} else {
+		_, err := w.Write(b)
+		return err
+	}
+}
+
 ///////////////////////
 
 type AvroUnion struct {
 	elements []ItemSchema
 }
 
+// UnionValue is value of union tagged with index of the union element. Values are tagged
+// only if the element can't be told by the value itself, like records of one of several
+// record types or symbols of enum in union with string.
+type UnionValue struct {
+	Index int
+	Value interface{}
+}
+
 func (v AvroUnion) Read(r io.Reader) (interface{}, error) {
 	if idx, err := readInt(r); err != nil {
 		return nil, err
 	} else if idx < 0 || int(idx) >= len(v.elements) {
 		return nil, fmt.Errorf("union doesn't have element with index %d", idx)
+	} else if value, err := v.elements[idx].Read(r); err != nil {
+		return nil, err
+	} else {
+		return v.tag(int(idx), value), nil
+	}
+}
+
+func (v AvroUnion) Write(w io.Writer, value interface{}) error {
+	if idx, untagged := v.Branch(value); idx < 0 {
+		return fmt.Errorf("value %v doesn't match any union element", value)
+	} else if err := writeLong(w, int64(idx)); err != nil {
+		return err
 	} else {
-		return v.elements[idx].Read(r)
+		return v.elements[idx].Write(w, untagged)
+	}
+}
+
+// Branch returns index of union element the value is written with and the value without
+// the tag, index of untagged value is chosen by the value. Index is -1 if none matches.
+func (v AvroUnion) Branch(value interface{}) (int, interface{}) {
+	if tagged, ok := value.(UnionValue); ok {
+		if tagged.Index < 0 || tagged.Index >= len(v.elements) {
+			return -1, tagged.Value
+		}
+		return tagged.Index, tagged.Value
+	}
+	return v.branchIndex(value), value
+}
+
+// tag wraps value of the element in UnionValue if the element can't be told by the value
+func (v AvroUnion) tag(idx int, value interface{}) interface{} {
+	if value == nil || v.branchIndex(value) == idx {
+		return value
 	}
+	return UnionValue{Index: idx, Value: value}
+}
+
+// branchIndex picks the union element for a value, preferring exact go type
+// matches (int32 for int, float64 for double) over convertible ones.
+func (v AvroUnion) branchIndex(value interface{}) int {
+	for _, strict := range []bool{true, false} {
+		for idx, element := range v.elements {
+			if matchesValue(element, value, strict) {
+				return idx
+			}
+		}
+	}
+	return -1
 }
 
 ///////////////////////
@@ -333,3 +559,24 @@ func (v AvroMap) Read(r io.Reader) (interface{}, error) {
 	}
 	return result, nil
 }
+
+func (v AvroMap) Write(w io.Writer, value interface{}) error {
+	items, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("value %v is not a map", value)
+	}
+	if len(items) > 0 {
+		if err := writeLong(w, int64(len(items))); err != nil {
+			return err
+		}
+		for name, item := range items {
+			if err := writeBytes(w, []byte(name)); err != nil {
+				return err
+			}
+			if err := v.values.Write(w, item); err != nil {
+				return fmt.Errorf("failed to write item with name %s: %w", name, err)
+			}
+		}
+	}
+	return writeLong(w, 0)
+}
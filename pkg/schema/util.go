@@ -1,8 +1,10 @@
 package schema
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 )
 
@@ -44,10 +46,10 @@ func getIntValue(items map[string]interface{}, name string, required bool) (int,
 	if !exists {
 		return 0, nil
 	}
-	if intValue, ok := value.(int); !ok {
-		return 0, fmt.Errorf("field %s expected to be of type string in %v", name, items)
+	if intValue, ok := toInt64(value); !ok {
+		return 0, fmt.Errorf("field %s expected to be of type int in %v", name, items)
 	} else {
-		return intValue, nil
+		return int(intValue), nil
 	}
 }
 
@@ -75,3 +77,221 @@ func readStringArray(data map[string]interface{}, name string, required bool) ([
 	}
 	return resultValues, nil
 }
+
+func resolve(s ItemSchema) ItemSchema {
+	if ref, ok := s.(*avroReferenceSchema); ok && ref.ref != nil {
+		return resolve(ref.ref)
+	}
+	return s
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case float64:
+		return int64(v), v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64
+	case float32:
+		return int64(v), float64(v) == math.Trunc(float64(v))
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		i, ok := toInt64(value)
+		return float64(i), ok
+	}
+}
+
+// matchesValue checks whether value can be written with the schema. In strict mode
+// only go types produced by Read are accepted for numbers.
+func matchesValue(s ItemSchema, value interface{}, strict bool) bool {
+	switch t := resolve(s).(type) {
+	case AvroNull:
+		return value == nil
+	case AvroBoolean:
+		_, ok := value.(bool)
+		return ok
+	case AvroInt:
+		if strict {
+			_, ok := value.(int32)
+			return ok
+		}
+		i, ok := toInt64(value)
+		return ok && i >= math.MinInt32 && i <= math.MaxInt32
+	case AvroLong:
+		if strict {
+			_, ok := value.(int64)
+			return ok
+		}
+		_, ok := toInt64(value)
+		return ok
+	case AvroFloat:
+		if strict {
+			_, ok := value.(float32)
+			return ok
+		}
+		_, ok := toFloat64(value)
+		return ok
+	case AvroDouble:
+		if strict {
+			_, ok := value.(float64)
+			return ok
+		}
+		_, ok := toFloat64(value)
+		return ok
+	case AvroBytes:
+		_, ok := value.([]byte)
+		return ok
+	case AvroString:
+		_, ok := value.(string)
+		return ok
+	case AvroEnum:
+		if str, ok := value.(string); ok {
+			for _, symbol := range t.symbols {
+				if symbol == str {
+					return true
+				}
+			}
+		}
+		return false
+	case AvroFixed:
+		b, ok := value.([]byte)
+		return ok && len(b) == t.size
+	case AvroArray:
+		_, ok := value.([]interface{})
+		return ok
+	case AvroMap:
+		_, ok := value.(map[string]interface{})
+		return ok
+	case AvroRecord:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for _, f := range t.fields {
+			if _, found := m[f.name]; !found && !f.hasDefault {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// writeDefault writes default value from json schema definition. Defaults for unions
+// always correspond to the first element, bytes and fixed are encoded as ISO-8859-1 strings.
+func writeDefault(w io.Writer, s ItemSchema, value interface{}) error {
+	switch t := resolve(s).(type) {
+	case AvroUnion:
+		if len(t.elements) == 0 {
+			return fmt.Errorf("default value can't be set for empty union")
+		}
+		if err := writeLong(w, 0); err != nil {
+			return err
+		}
+		return writeDefault(w, t.elements[0], value)
+	case AvroBytes, AvroFixed:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("default value %v for bytes should be string", value)
+		}
+		return t.Write(w, latin1Bytes(str))
+	case AvroRecord:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("default value %v for record %s should be object", value, t.name)
+		}
+		for _, f := range t.fields {
+			var err error
+			if fieldValue, found := m[f.name]; found {
+				err = writeDefault(w, f.fieldType, fieldValue)
+			} else if f.hasDefault {
+				err = writeDefault(w, f.fieldType, f.defaultValue)
+			} else {
+				err = fmt.Errorf("value is missing and no default is set")
+			}
+			if err != nil {
+				return fmt.Errorf("failed writing default %s in type %s: %w", f.name, t.name, err)
+			}
+		}
+		return nil
+	case AvroArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("default value %v for array should be array", value)
+		}
+		if len(items) > 0 {
+			if err := writeLong(w, int64(len(items))); err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := writeDefault(w, t.itemSchema, item); err != nil {
+					return err
+				}
+			}
+		}
+		return writeLong(w, 0)
+	case AvroMap:
+		items, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("default value %v for map should be object", value)
+		}
+		if len(items) > 0 {
+			if err := writeLong(w, int64(len(items))); err != nil {
+				return err
+			}
+			for name, item := range items {
+				if err := writeBytes(w, []byte(name)); err != nil {
+					return err
+				}
+				if err := writeDefault(w, t.values, item); err != nil {
+					return err
+				}
+			}
+		}
+		return writeLong(w, 0)
+	default:
+		return t.Write(w, value)
+	}
+}
+
+func latin1Bytes(value string) []byte {
+	result := make([]byte, 0, len(value))
+	for _, r := range value {
+		result = append(result, byte(r))
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	maxOffset    = 1 << 15
+	hashTableLog = 14
+)
+
+var errCorrupt = errors.New("snappy: corrupt input")
+
+func DecodedLen(src []byte) (int, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 0xffffffff {
+		return 0, errCorrupt
+	}
+	return int(length), nil
+}
+
+func Decode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 0xffffffff {
+		return nil, errCorrupt
+	}
+	dst := make([]byte, 0, length)
+	for s := n; s < len(src); {
+		tag := src[s]
+		var size, offset int
+		switch tag & 0x03 {
+		case tagLiteral:
+			size = int(tag >> 2)
+			s++
+			if size >= 60 {
+				extra := size - 59
+				if s+extra > len(src) {
+					return nil, errCorrupt
+				}
+				size = 0
+				for i := extra - 1; i >= 0; i-- {
+					size = size<<8 | int(src[s+i])
+				}
+				s += extra
+			}
+			size++
+			if size <= 0 || s+size > len(src) {
+				return nil, errCorrupt
+			}
+			dst = append(dst, src[s:s+size]...)
+			s += size
+			continue
+		case tagCopy1:
+			if s+2 > len(src) {
+				return nil, errCorrupt
+			}
+			size = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[s+1])
+			s += 2
+		case tagCopy2:
+			if s+3 > len(src) {
+				return nil, errCorrupt
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s+1:]))
+			s += 3
+		case tagCopy4:
+			if s+5 > len(src) {
+				return nil, errCorrupt
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s+1:]))
+			s += 5
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, fmt.Errorf("snappy: copy offset %d is out of range %d", offset, len(dst))
+		}
+		// copies may overlap with the produced output, so go byte by byte
+		start := len(dst) - offset
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, fmt.Errorf("snappy: decoded length %d differs from declared %d", len(dst), length)
+	}
+	return dst, nil
+}
+
+func Encode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen32, len(src)+len(src)/6+32)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+
+	var table [1 << hashTableLog]int32
+	literalStart := 0
+	for i := 0; i+4 <= len(src); {
+		current := binary.LittleEndian.Uint32(src[i:])
+		h := (current * 0x1e35a7bd) >> (32 - hashTableLog)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if candidate < 0 || i-candidate >= maxOffset || binary.LittleEndian.Uint32(src[candidate:]) != current {
+			i++
+			continue
+		}
+		dst = emitLiteral(dst, src[literalStart:i])
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = emitCopy(dst, i-candidate, length)
+		i += length
+		literalStart = i
+	}
+	return emitLiteral(dst, src[literalStart:])
+}
+
+func emitLiteral(dst, literal []byte) []byte {
+	if len(literal) == 0 {
+		return dst
+	}
+	n := len(literal) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, literal...)
+}
+
+func emitCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		chunk := length
+		if chunk > 64 {
+			chunk = 64
+		}
+		dst = append(dst, byte(chunk-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= chunk
+	}
+	return dst
+}
@@ -0,0 +1,57 @@
+package snappy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestDecode decodes testdata/sample.jsonl encoded by github.com/golang/snappy Encode
+func TestDecode(t *testing.T) {
+	sample := readFile(t, "sample.jsonl")
+	data := readFile(t, "block.snappy")
+	if size, err := DecodedLen(data); err != nil || size != len(sample) {
+		t.Fatalf("expected decoded length %d, got %d, %v", len(sample), size, err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, sample) {
+		t.Fatalf("decoded %d bytes don't match %d bytes of sample", len(decoded), len(sample))
+	}
+	if _, err = Decode(data[:len(data)/2]); err == nil {
+		t.Error("expected error of truncated block")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	sample := readFile(t, "sample.jsonl")
+	for _, data := range [][]byte{nil, []byte("a"), sample, bytes.Repeat([]byte{0}, 100000)} {
+		decoded, err := Decode(Encode(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("round trip of %d bytes returned %d bytes", len(data), len(decoded))
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(readFile(f, "block.snappy"))
+	f.Add(Encode(bytes.Repeat([]byte("abcd"), 100)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = Decode(data)
+	})
+}
@@ -0,0 +1,85 @@
+package zstd
+
+import "math/bits"
+
+// forwardBitReader reads bits of little-endian data starting from the lowest bit
+type forwardBitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *forwardBitReader) readBits(n int) (uint64, error) {
+	if r.pos+n > len(r.data)*8 {
+		return 0, errCorrupt
+	}
+	value := peekBits(r.data, r.pos, n)
+	r.pos += n
+	return value, nil
+}
+
+// bytesRead returns number of bytes with bits read so far
+func (r *forwardBitReader) bytesRead() int {
+	return (r.pos + 7) / 8
+}
+
+///////////////////////
+
+// backwardBitReader reads bits from the end of data, highest set bit of the last byte
+// marks the start of the stream. Reading past the beginning of data yields zero bits.
+type backwardBitReader struct {
+	data []byte
+	pos  int
+}
+
+func newBackwardBitReader(data []byte) (*backwardBitReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errCorrupt
+	}
+	last := data[len(data)-1]
+	return &backwardBitReader{data: data, pos: len(data)*8 - 8 + bits.Len8(last) - 1}, nil
+}
+
+func (r *backwardBitReader) readBits(n int) uint64 {
+	r.pos -= n
+	return peekBits(r.data, r.pos, n)
+}
+
+func (r *backwardBitReader) peekBits(n int) uint64 {
+	return peekBits(r.data, r.pos-n, n)
+}
+
+func (r *backwardBitReader) skipBits(n int) {
+	r.pos -= n
+}
+
+// overflow reports whether more bits were read than the stream has
+func (r *backwardBitReader) overflow() bool {
+	return r.pos < 0
+}
+
+// finished reports whether all bits of the stream were read exactly
+func (r *backwardBitReader) finished() bool {
+	return r.pos == 0
+}
+
+// peekBits returns n bits (up to 56) of little-endian data starting at bit pos,
+// bits at negative positions are zero
+func peekBits(data []byte, pos, n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+	shift := 0
+	if pos < 0 {
+		shift = -pos
+		if shift >= n {
+			return 0
+		}
+		pos = 0
+	}
+	var word uint64
+	for idx, start := 0, pos/8; idx < 8 && start+idx < len(data); idx++ {
+		word |= uint64(data[start+idx]) << (8 * idx)
+	}
+	count := n - shift
+	return ((word >> (pos % 8)) & (1<<count - 1)) << shift
+}
@@ -0,0 +1,153 @@
+package zstd
+
+import "math/bits"
+
+type fseEntry struct {
+	symbol   uint8
+	nbBits   uint8
+	newState uint16
+}
+
+type fseTable struct {
+	accuracyLog int
+	entries     []fseEntry
+}
+
+// readFSETable reads normalized symbol counts and builds decoding table, number of
+// bytes of the description is returned along with the table
+func readFSETable(data []byte, maxSymbol int, maxAccuracyLog int) (*fseTable, int, error) {
+	r := forwardBitReader{data: data}
+	value, err := r.readBits(4)
+	if err != nil {
+		return nil, 0, err
+	}
+	accuracyLog := int(value) + 5
+	if accuracyLog > maxAccuracyLog {
+		return nil, 0, errCorrupt
+	}
+	counts := make([]int, 0, maxSymbol+1)
+	remaining := 1<<accuracyLog + 1
+	threshold := 1 << accuracyLog
+	nbBits := accuracyLog + 1
+	previousZero := false
+	for remaining > 1 && len(counts) <= maxSymbol {
+		if previousZero {
+			// repeat flags tell how many more symbols have zero count
+			for {
+				repeat, err := r.readBits(2)
+				if err != nil {
+					return nil, 0, err
+				}
+				for idx := uint64(0); idx < repeat; idx++ {
+					counts = append(counts, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+			if len(counts) > maxSymbol {
+				return nil, 0, errCorrupt
+			}
+		}
+		max := 2*threshold - 1 - remaining
+		var count int
+		low := int(peekBits(r.data, r.pos, nbBits-1))
+		if r.pos+nbBits-1 > len(data)*8 {
+			return nil, 0, errCorrupt
+		}
+		if low < max {
+			count = low
+			r.pos += nbBits - 1
+		} else {
+			if count64, err := r.readBits(nbBits); err != nil {
+				return nil, 0, err
+			} else {
+				count = int(count64)
+			}
+			if count >= threshold {
+				count -= max
+			}
+		}
+		// value 0 stands for "less than 1" probability
+		count--
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		counts = append(counts, count)
+		previousZero = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 {
+		return nil, 0, errCorrupt
+	}
+	table, err := buildFSETable(counts, accuracyLog)
+	return table, r.bytesRead(), err
+}
+
+func buildFSETable(counts []int, accuracyLog int) (*fseTable, error) {
+	tableSize := 1 << accuracyLog
+	table := &fseTable{accuracyLog: accuracyLog, entries: make([]fseEntry, tableSize)}
+	next := make([]int, len(counts))
+	highThreshold := tableSize - 1
+	for symbol, count := range counts {
+		if count == -1 {
+			table.entries[highThreshold].symbol = uint8(symbol)
+			highThreshold--
+			next[symbol] = 1
+		} else {
+			next[symbol] = count
+		}
+	}
+	step := tableSize>>1 + tableSize>>3 + 3
+	position := 0
+	for symbol, count := range counts {
+		for idx := 0; idx < count; idx++ {
+			table.entries[position].symbol = uint8(symbol)
+			position = (position + step) & (tableSize - 1)
+			for position > highThreshold {
+				position = (position + step) & (tableSize - 1)
+			}
+		}
+	}
+	if position != 0 {
+		return nil, errCorrupt
+	}
+	for idx := range table.entries {
+		entry := &table.entries[idx]
+		state := next[entry.symbol]
+		next[entry.symbol]++
+		entry.nbBits = uint8(accuracyLog - (bits.Len(uint(state)) - 1))
+		entry.newState = uint16(state<<entry.nbBits - tableSize)
+	}
+	return table, nil
+}
+
+// rleFSETable returns table always decoding the same symbol without reading bits
+func rleFSETable(symbol uint8) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+///////////////////////
+
+type fseState struct {
+	table *fseTable
+	state int
+}
+
+func (s *fseState) init(r *backwardBitReader) {
+	s.state = int(r.readBits(s.table.accuracyLog))
+}
+
+func (s *fseState) symbol() uint8 {
+	return s.table.entries[s.state].symbol
+}
+
+func (s *fseState) update(r *backwardBitReader) {
+	entry := s.table.entries[s.state]
+	s.state = int(entry.newState) + int(r.readBits(int(entry.nbBits)))
+}
@@ -0,0 +1,146 @@
+package zstd
+
+import "math/bits"
+
+const (
+	maxHuffmanBits    = 11
+	maxHuffmanSymbols = 255
+)
+
+type huffmanEntry struct {
+	symbol uint8
+	nbBits uint8
+}
+
+type huffmanTable struct {
+	maxBits int
+	entries []huffmanEntry
+}
+
+// readHuffmanTable reads huffman tree description, number of its bytes is returned
+// along with the table
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	header := int(data[0])
+	weights := make([]int, 0, maxHuffmanSymbols+1)
+	var size int
+	if header < 128 {
+		// weights are compressed with fse
+		size = 1 + header
+		if len(data) < size {
+			return nil, 0, errCorrupt
+		}
+		compressed := data[1:size]
+		table, tableSize, err := readFSETable(compressed, maxHuffmanSymbols, 6)
+		if err != nil {
+			return nil, 0, err
+		}
+		r, err := newBackwardBitReader(compressed[tableSize:])
+		if err != nil {
+			return nil, 0, err
+		}
+		if weights, err = decodeWeights(weights, table, r); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		// weights are stored as 4-bit values
+		count := header - 127
+		size = 1 + (count+1)/2
+		if len(data) < size {
+			return nil, 0, errCorrupt
+		}
+		for idx := 0; idx < count; idx++ {
+			packed := data[1+idx/2]
+			if idx%2 == 0 {
+				weights = append(weights, int(packed>>4))
+			} else {
+				weights = append(weights, int(packed&0x0f))
+			}
+		}
+	}
+	table, err := buildHuffmanTable(weights)
+	return table, size, err
+}
+
+func decodeWeights(weights []int, table *fseTable, r *backwardBitReader) ([]int, error) {
+	states := []fseState{{table: table}, {table: table}}
+	states[0].init(r)
+	states[1].init(r)
+	// states alternate until the stream is overrun, then the other state gives last weight
+	for current := 0; ; current = 1 - current {
+		if len(weights) >= maxHuffmanSymbols {
+			return nil, errCorrupt
+		}
+		weights = append(weights, int(states[current].symbol()))
+		states[current].update(r)
+		if r.overflow() {
+			return append(weights, int(states[1-current].symbol())), nil
+		}
+	}
+}
+
+func buildHuffmanTable(weights []int) (*huffmanTable, error) {
+	sum := 0
+	for _, weight := range weights {
+		if weight > maxHuffmanBits {
+			return nil, errCorrupt
+		}
+		if weight > 0 {
+			sum += 1 << (weight - 1)
+		}
+	}
+	if sum == 0 {
+		return nil, errCorrupt
+	}
+	// weight of the last symbol is implied, it completes the sum to the power of 2
+	maxBits := bits.Len(uint(sum))
+	rest := 1<<maxBits - sum
+	if maxBits > maxHuffmanBits || rest&(rest-1) != 0 {
+		return nil, errCorrupt
+	}
+	weights = append(weights, bits.Len(uint(rest)))
+	if len(weights) > maxHuffmanSymbols+1 {
+		return nil, errCorrupt
+	}
+
+	rankCount := make([]int, maxBits+1)
+	for _, weight := range weights {
+		rankCount[weight]++
+	}
+	rankStart := make([]int, maxBits+1)
+	for weight, next := 1, 0; weight <= maxBits; weight++ {
+		rankStart[weight] = next
+		next += rankCount[weight] << (weight - 1)
+	}
+	table := &huffmanTable{maxBits: maxBits, entries: make([]huffmanEntry, 1<<maxBits)}
+	for symbol, weight := range weights {
+		if weight == 0 {
+			continue
+		}
+		length := 1 << (weight - 1)
+		entry := huffmanEntry{symbol: uint8(symbol), nbBits: uint8(maxBits + 1 - weight)}
+		for idx := rankStart[weight]; idx < rankStart[weight]+length; idx++ {
+			table.entries[idx] = entry
+		}
+		rankStart[weight] += length
+	}
+	return table, nil
+}
+
+func (t *huffmanTable) decodeStream(dst []byte, data []byte, count int) ([]byte, error) {
+	r, err := newBackwardBitReader(data)
+	if err != nil {
+		return nil, err
+	}
+	for idx := 0; idx < count; idx++ {
+		entry := t.entries[r.peekBits(t.maxBits)]
+		r.skipBits(int(entry.nbBits))
+		dst = append(dst, entry.symbol)
+	}
+	if !r.finished() {
+		return nil, errCorrupt
+	}
+	return dst, nil
+}
@@ -0,0 +1,428 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	frameMagic         = 0xFD2FB528
+	skippableMagicMask = 0xFFFFFFF0
+	skippableMagic     = 0x184D2A50
+
+	blockTypeRaw        = 0
+	blockTypeRLE        = 1
+	blockTypeCompressed = 2
+
+	literalsTypeRaw        = 0
+	literalsTypeRLE        = 1
+	literalsTypeCompressed = 2
+	literalsTypeTreeless   = 3
+
+	// maxBlockSize is the largest size of decoded block, blocks of small windows are smaller
+	maxBlockSize = 128 << 10
+
+	modePredefined = 0
+	modeRLE        = 1
+	modeCompressed = 2
+	modeRepeat     = 3
+)
+
+var errCorrupt = errors.New("zstd: corrupt input")
+
+// Decode decodes concatenated zstd frames, skippable frames are ignored. Dictionaries are
+// not supported and content checksums are not verified.
+func Decode(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(src)*4)
+	for len(src) > 0 {
+		if len(src) < 4 {
+			return nil, errCorrupt
+		}
+		magic := binary.LittleEndian.Uint32(src)
+		if magic&skippableMagicMask == skippableMagic {
+			if len(src) < 8 {
+				return nil, errCorrupt
+			}
+			size := int(binary.LittleEndian.Uint32(src[4:]))
+			if len(src)-8 < size {
+				return nil, errCorrupt
+			}
+			src = src[8+size:]
+			continue
+		}
+		if magic != frameMagic {
+			return nil, fmt.Errorf("zstd: unknown frame magic %x", magic)
+		}
+		var err error
+		if dst, src, err = decodeFrame(dst, src[4:]); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// frameDecoder keeps state shared by blocks of a frame
+type frameDecoder struct {
+	frameStart int
+	// blockSize is the largest size of decoded block of the frame
+	blockSize int
+	huffman   *huffmanTable
+	tables    [3]*fseTable
+	offsets   [3]int
+}
+
+func decodeFrame(dst, src []byte) ([]byte, []byte, error) {
+	if len(src) < 1 {
+		return nil, nil, errCorrupt
+	}
+	descriptor := src[0]
+	if descriptor&0x08 != 0 {
+		return nil, nil, errCorrupt
+	}
+	singleSegment := descriptor&0x20 != 0
+	pos := 1
+	windowSize := -1
+	if !singleSegment {
+		if len(src) < 2 {
+			return nil, nil, errCorrupt
+		}
+		windowLog := 10 + uint(src[1]>>3)
+		windowSize = 1<<windowLog + (1<<windowLog)/8*int(src[1]&0x07)
+		pos++
+	}
+	dictIdSize := []int{0, 1, 2, 4}[descriptor&0x03]
+	if len(src) < pos+dictIdSize {
+		return nil, nil, errCorrupt
+	}
+	for idx := 0; idx < dictIdSize; idx++ {
+		if src[pos+idx] != 0 {
+			return nil, nil, fmt.Errorf("zstd: dictionaries are not supported")
+		}
+	}
+	pos += dictIdSize
+	contentSizeSize := []int{0, 2, 4, 8}[descriptor>>6]
+	if contentSizeSize == 0 && singleSegment {
+		contentSizeSize = 1
+	}
+	if len(src) < pos+contentSizeSize {
+		return nil, nil, errCorrupt
+	}
+	contentSize := uint64(0)
+	for idx := contentSizeSize - 1; idx >= 0; idx-- {
+		contentSize = contentSize<<8 | uint64(src[pos+idx])
+	}
+	if contentSizeSize == 2 {
+		contentSize += 256
+	}
+	pos += contentSizeSize
+	if singleSegment {
+		windowSize = int(contentSize)
+		if contentSize > maxBlockSize {
+			windowSize = maxBlockSize
+		}
+	}
+	blockSize := maxBlockSize
+	if windowSize < blockSize {
+		blockSize = windowSize
+	}
+
+	d := frameDecoder{frameStart: len(dst), blockSize: blockSize, offsets: [3]int{1, 4, 8}}
+	for last := false; !last; {
+		if len(src) < pos+3 {
+			return nil, nil, errCorrupt
+		}
+		header := int(src[pos]) | int(src[pos+1])<<8 | int(src[pos+2])<<16
+		pos += 3
+		last = header&1 != 0
+		size := header >> 3
+		switch (header >> 1) & 0x03 {
+		case blockTypeRaw:
+			if len(src) < pos+size || size > blockSize {
+				return nil, nil, errCorrupt
+			}
+			dst = append(dst, src[pos:pos+size]...)
+			pos += size
+		case blockTypeRLE:
+			if len(src) < pos+1 || size > blockSize {
+				return nil, nil, errCorrupt
+			}
+			for idx := 0; idx < size; idx++ {
+				dst = append(dst, src[pos])
+			}
+			pos++
+		case blockTypeCompressed:
+			if len(src) < pos+size || size > blockSize {
+				return nil, nil, errCorrupt
+			}
+			var err error
+			if dst, err = d.decodeBlock(dst, src[pos:pos+size]); err != nil {
+				return nil, nil, err
+			}
+			pos += size
+		default:
+			return nil, nil, errCorrupt
+		}
+		if contentSizeSize > 0 && uint64(len(dst)-d.frameStart) > contentSize {
+			return nil, nil, fmt.Errorf("zstd: frame is larger than its content size %d", contentSize)
+		}
+	}
+	if contentSizeSize > 0 && uint64(len(dst)-d.frameStart) != contentSize {
+		return nil, nil, fmt.Errorf("zstd: frame is smaller than its content size %d", contentSize)
+	}
+	if descriptor&0x04 != 0 {
+		// content checksum
+		pos += 4
+	}
+	if len(src) < pos {
+		return nil, nil, errCorrupt
+	}
+	return dst, src[pos:], nil
+}
+
+func (d *frameDecoder) decodeBlock(dst, block []byte) ([]byte, error) {
+	literals, pos, err := d.readLiterals(block)
+	if err != nil {
+		return nil, err
+	}
+	block = block[pos:]
+	if len(block) == 0 {
+		return nil, errCorrupt
+	}
+	var count int
+	switch first := int(block[0]); {
+	case first < 128:
+		count, pos = first, 1
+	case first < 255:
+		if len(block) < 2 {
+			return nil, errCorrupt
+		}
+		count, pos = (first-128)<<8+int(block[1]), 2
+	default:
+		if len(block) < 3 {
+			return nil, errCorrupt
+		}
+		count, pos = int(block[1])+int(block[2])<<8+0x7F00, 3
+	}
+	if count == 0 {
+		if len(literals) > d.blockSize {
+			return nil, errCorrupt
+		}
+		return append(dst, literals...), nil
+	}
+	if len(block) < pos+1 {
+		return nil, errCorrupt
+	}
+	modes := block[pos]
+	pos++
+	for idx, kind := range sequenceKinds {
+		mode := int(modes>>(6-2*idx)) & 0x03
+		table, size, err := d.readSequenceTable(block[pos:], mode, kind, d.tables[idx])
+		if err != nil {
+			return nil, err
+		}
+		d.tables[idx] = table
+		pos += size
+	}
+	return d.executeSequences(dst, literals, block[pos:], count)
+}
+
+func (d *frameDecoder) readLiterals(block []byte) ([]byte, int, error) {
+	if len(block) == 0 {
+		return nil, 0, errCorrupt
+	}
+	literalsType := block[0] & 0x03
+	sizeFormat := (block[0] >> 2) & 0x03
+	switch literalsType {
+	case literalsTypeRaw, literalsTypeRLE:
+		var size, headerSize int
+		switch sizeFormat {
+		case 0, 2:
+			size, headerSize = int(block[0]>>3), 1
+		case 1:
+			if len(block) < 2 {
+				return nil, 0, errCorrupt
+			}
+			size, headerSize = int(block[0]>>4)+int(block[1])<<4, 2
+		default:
+			if len(block) < 3 {
+				return nil, 0, errCorrupt
+			}
+			size, headerSize = int(block[0]>>4)+int(block[1])<<4+int(block[2])<<12, 3
+		}
+		if literalsType == literalsTypeRaw {
+			if len(block) < headerSize+size {
+				return nil, 0, errCorrupt
+			}
+			return block[headerSize : headerSize+size], headerSize + size, nil
+		}
+		if len(block) < headerSize+1 {
+			return nil, 0, errCorrupt
+		}
+		literals := make([]byte, size)
+		for idx := range literals {
+			literals[idx] = block[headerSize]
+		}
+		return literals, headerSize + 1, nil
+	}
+
+	streams, headerSize, sizeBits := 4, 3, 10
+	switch sizeFormat {
+	case 0:
+		streams = 1
+	case 2:
+		headerSize, sizeBits = 4, 14
+	case 3:
+		headerSize, sizeBits = 5, 18
+	}
+	if len(block) < headerSize {
+		return nil, 0, errCorrupt
+	}
+	var header uint64
+	for idx := 0; idx < headerSize; idx++ {
+		header |= uint64(block[idx]) << (8 * idx)
+	}
+	size := int(header>>4) & (1<<sizeBits - 1)
+	compressedSize := int(header>>(4+sizeBits)) & (1<<sizeBits - 1)
+	if len(block) < headerSize+compressedSize {
+		return nil, 0, errCorrupt
+	}
+	data := block[headerSize : headerSize+compressedSize]
+	if literalsType == literalsTypeCompressed {
+		table, tableSize, err := readHuffmanTable(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.huffman = table
+		data = data[tableSize:]
+	} else if d.huffman == nil {
+		return nil, 0, errCorrupt
+	}
+
+	literals := make([]byte, 0, size)
+	var err error
+	if streams == 1 {
+		literals, err = d.huffman.decodeStream(literals, data, size)
+		return literals, headerSize + compressedSize, err
+	}
+	if len(data) < 6 {
+		return nil, 0, errCorrupt
+	}
+	sizes := []int{
+		int(binary.LittleEndian.Uint16(data)),
+		int(binary.LittleEndian.Uint16(data[2:])),
+		int(binary.LittleEndian.Uint16(data[4:])),
+	}
+	sizes = append(sizes, len(data)-6-sizes[0]-sizes[1]-sizes[2])
+	segment := (size + 3) / 4
+	data = data[6:]
+	for idx, streamSize := range sizes {
+		count := segment
+		if idx == 3 {
+			count = size - 3*segment
+		}
+		if streamSize < 0 || count < 0 || len(data) < streamSize {
+			return nil, 0, errCorrupt
+		}
+		if literals, err = d.huffman.decodeStream(literals, data[:streamSize], count); err != nil {
+			return nil, 0, err
+		}
+		data = data[streamSize:]
+	}
+	return literals, headerSize + compressedSize, nil
+}
+
+func (d *frameDecoder) readSequenceTable(data []byte, mode int, kind sequenceKind, previous *fseTable) (*fseTable, int, error) {
+	switch mode {
+	case modePredefined:
+		return kind.predefined, 0, nil
+	case modeRLE:
+		if len(data) < 1 || int(data[0]) > kind.maxSymbol {
+			return nil, 0, errCorrupt
+		}
+		return rleFSETable(data[0]), 1, nil
+	case modeCompressed:
+		return readFSETable(data, kind.maxSymbol, kind.maxAccuracyLog)
+	}
+	if previous == nil {
+		return nil, 0, errCorrupt
+	}
+	return previous, 0, nil
+}
+
+func (d *frameDecoder) executeSequences(dst, literals, data []byte, count int) ([]byte, error) {
+	r, err := newBackwardBitReader(data)
+	if err != nil {
+		return nil, err
+	}
+	literalsLength := fseState{table: d.tables[0]}
+	offset := fseState{table: d.tables[1]}
+	matchLength := fseState{table: d.tables[2]}
+	literalsLength.init(r)
+	offset.init(r)
+	matchLength.init(r)
+
+	blockStart := len(dst)
+	for idx := 0; idx < count; idx++ {
+		offsetCode := int(offset.symbol())
+		matchCode := int(matchLength.symbol())
+		literalsCode := int(literalsLength.symbol())
+		if offsetCode > 31 {
+			return nil, errCorrupt
+		}
+		offsetValue := 1<<offsetCode + int(r.readBits(offsetCode))
+		matchSize := matchLengthCodes[matchCode].baseline + int(r.readBits(matchLengthCodes[matchCode].bits))
+		literalsSize := literalsLengthCodes[literalsCode].baseline + int(r.readBits(literalsLengthCodes[literalsCode].bits))
+		if idx != count-1 {
+			literalsLength.update(r)
+			matchLength.update(r)
+			offset.update(r)
+		}
+		if r.overflow() {
+			return nil, errCorrupt
+		}
+
+		if len(literals) < literalsSize || len(dst)-blockStart+literalsSize+matchSize > d.blockSize {
+			return nil, errCorrupt
+		}
+		dst = append(dst, literals[:literalsSize]...)
+		literals = literals[literalsSize:]
+		distance := d.matchOffset(offsetValue, literalsSize)
+		if distance <= 0 || distance > len(dst)-d.frameStart {
+			return nil, errCorrupt
+		}
+		// match may overlap with bytes being copied
+		for start := len(dst) - distance; matchSize > 0; matchSize-- {
+			dst = append(dst, dst[start])
+			start++
+		}
+	}
+	if !r.finished() || len(dst)-blockStart+len(literals) > d.blockSize {
+		return nil, errCorrupt
+	}
+	return append(dst, literals...), nil
+}
+
+// matchOffset converts offset value to match offset updating repeated offsets
+func (d *frameDecoder) matchOffset(offsetValue int, literalsSize int) int {
+	if offsetValue > 3 {
+		d.offsets = [3]int{offsetValue - 3, d.offsets[0], d.offsets[1]}
+		return d.offsets[0]
+	}
+	// repeat codes are shifted by one when there are no literals
+	repeat := offsetValue - 1
+	if literalsSize == 0 {
+		repeat++
+	}
+	switch repeat {
+	case 0:
+		return d.offsets[0]
+	case 1:
+		d.offsets = [3]int{d.offsets[1], d.offsets[0], d.offsets[2]}
+	case 2:
+		d.offsets = [3]int{d.offsets[2], d.offsets[0], d.offsets[1]}
+	default:
+		d.offsets = [3]int{d.offsets[0] - 1, d.offsets[0], d.offsets[1]}
+	}
+	return d.offsets[0]
+}
@@ -0,0 +1,77 @@
+package zstd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// Fixtures are testdata/sample.jsonl compressed by zstd 1.5 cli:
+//
+//	zstd -1 sample.jsonl -o level1.zst
+//	zstd -19 sample.jsonl -o level19.zst
+//	zstd --long=27 -3 sample.jsonl -o long.zst
+//	zstd -1 --no-check sample.jsonl -o nocheck.zst && cat level1.zst nocheck.zst > frames.zst
+//	head -c 200000 /dev/zero | zstd -19 > zeros.zst
+var fixtures = []string{"level1.zst", "level19.zst", "long.zst", "frames.zst"}
+
+func readFile(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	sample := readFile(t, "sample.jsonl")
+	for _, name := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			expected := sample
+			if name == "frames.zst" {
+				expected = append(append([]byte{}, sample...), sample...)
+			}
+			decoded, err := Decode(readFile(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, expected) {
+				t.Fatalf("decoded %d bytes don't match %d bytes of sample", len(decoded), len(expected))
+			}
+		})
+	}
+	t.Run("zeros.zst", func(t *testing.T) {
+		decoded, err := Decode(readFile(t, "zeros.zst"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, make([]byte, 200000)) {
+			t.Fatalf("expected 200000 zeros, got %d bytes", len(decoded))
+		}
+	})
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	data := readFile(t, "level1.zst")
+	// last 4 bytes are content checksum, it is not verified, but it should be present
+	if _, err := Decode(data[:len(data)-2]); err == nil {
+		t.Error("expected error of truncated checksum")
+	}
+	if _, err := Decode(data[:len(data)/2]); err == nil {
+		t.Error("expected error of truncated frame")
+	}
+	if _, err := Decode([]byte("not zstd")); err == nil {
+		t.Error("expected error of unknown magic")
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, name := range append(fixtures, "zeros.zst") {
+		f.Add(readFile(f, name))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = Decode(data)
+	})
+}